@@ -11,13 +11,9 @@ import (
 	"github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
 
-	// pkg "github.com/parinithshekar/gitsink/pkg/v1"
 	config "github.com/parinithshekar/gitsink/common/config"
-	bbcloud "github.com/parinithshekar/gitsink/plugins/input/bitbucket/cloud"
-	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
-	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
 	git "github.com/parinithshekar/gitsink/plugins/output/git"
-	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
 	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
 	profile "github.com/parinithshekar/gitsink/wrap/profile/v1"
 	// runtime "github.com/go-openapi/runtime"
@@ -30,13 +26,16 @@ func Execute() { // hello
 	// Start the profiler and defer stopping it until the program exits.
 	defer profile.Start().Stop()
 
-	// Logger object
-	log := logger.New()
-
 	var (
 		// Main git-migration command
-		app         = kingpin.New("github-migration", "The Github-Migration CLI")
-		appLogLevel = app.Flag("log-level", "Set log-level (trace|debug|info|warn|error|fatal|panic).").Default("info").OverrideDefaultFromEnvar("MERAKI_LOG_LEVEL").String()
+		app                 = kingpin.New("github-migration", "The Github-Migration CLI")
+		appLogLevel         = app.Flag("log-level", "Set log-level (trace|debug|info|warn|error|fatal|panic).").Default("info").OverrideDefaultFromEnvar("MERAKI_LOG_LEVEL").String()
+		appLogFormat        = app.Flag("log-format", "Set log format (json|text|logfmt).").Default(logger.FormatJSON).OverrideDefaultFromEnvar("GITSINK_LOG_FORMAT").Enum(logger.Formats...)
+		appLogFile          = app.Flag("log-file", "Write logs to this file instead of stderr, rotating it by size.").OverrideDefaultFromEnvar("GITSINK_LOG_FILE").String()
+		appLogFileMaxSize   = app.Flag("log-file-max-size", "Size in megabytes the log file is rotated at.").Default("100").Int()
+		appLogFileMaxAge    = app.Flag("log-file-max-age", "Days rotated log files are kept, 0 keeps them all.").Default("28").Int()
+		appLogFileMaxBackup = app.Flag("log-file-max-backups", "Number of rotated log files kept, 0 keeps them all.").Default("5").Int()
+		appMetricsFile      = app.Flag("metrics-textfile", "File to write the metrics of a one-shot run to, for the node exporter textfile collector").String()
 
 		//////////
 		// sync
@@ -46,8 +45,35 @@ func Execute() { // hello
 		appSyncBlockNewMigrations = appSync.Flag("block-new-migrations", "Block new migrations and sync only existing repos on GitHub").Bool()
 
 		/////////
-		// interactive - Can leave it out, does not make sense if supporting multiple sources for integrations
-		appInteractive = app.Command("interactive", "Select the projects and repositories to migrate/sync")
+		// interactive
+		appInteractive            = app.Command("interactive", "Select the projects and repositories to migrate/sync")
+		appInteractiveIntegration = appInteractive.Flag("integration", "Name of the integration in config, every enabled integration by default").String()
+
+		/////////
+		// users
+		appUsers               = app.Command("users", "Map source users to target logins")
+		appUsersMap            = appUsers.Command("map", "Report the source users of an integration without a target login")
+		appUsersMapIntegration = appUsersMap.Flag("integration", "Name of the integration in config").Required().String()
+
+		/////////
+		// migrate
+		appMigrate                    = app.Command("migrate", "Complete the migration of an integration")
+		appMigrateFinalize            = appMigrate.Command("finalize", "Run a last sync, verify the target and make the source repositories read-only")
+		appMigrateFinalizeIntegration = appMigrateFinalize.Flag("integration", "Name of the integration in config").Required().String()
+		appMigrateFinalizeReport      = appMigrateFinalize.Flag("report", "File to write the cut-over report to, stdout by default").String()
+
+		/////////
+		// verify
+		appVerify            = app.Command("verify", "Compare the branches and tags of source and target repositories")
+		appVerifyIntegration = appVerify.Flag("integration", "Name of the integration in config, every enabled integration by default").String()
+		appVerifyReport      = appVerify.Flag("report", "File to write the verification report to, stdout by default").String()
+
+		/////////
+		// serve
+		appServe         = app.Command("serve", "Sync the integrations on their schedule and serve an API to manage them")
+		appServeHost     = appServe.Flag("host", "Address to serve the API on").Default("127.0.0.1").OverrideDefaultFromEnvar("GITSINK_SERVER_HOST").String()
+		appServePort     = appServe.Flag("port", "Port to serve the API on").Default("8080").OverrideDefaultFromEnvar("GITSINK_SERVER_PORT").Int()
+		appServeTokenEnv = appServe.Flag("auth-token-env", "Environment variable with the bearer token of the API").Default("GITSINK_AUTH_TOKEN").String()
 
 		/////////
 		// test
@@ -56,7 +82,29 @@ func Execute() { // hello
 
 	p := kingpin.MustParse(app.Parse(os.Args[1:]))
 
+	// Every logger writes through the root logger, so its level, format and file apply everywhere
+	log.SetLevel(*appLogLevel)
+	logger.SetFormat(*appLogFormat)
+	if *appLogFile != "" {
+		logFile := logger.SetFile(*appLogFile, logger.Rotation{
+			MaxSizeMB:  *appLogFileMaxSize,
+			MaxAgeDays: *appLogFileMaxAge,
+			MaxBackups: *appLogFileMaxBackup,
+		})
+		defer logFile.Close()
+	}
+
 	config := config.Parse()
+	if err := config.CheckTransports(); err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Errorf("Invalid config")
+		os.Exit(1)
+	}
+
+	// Runs stop starting repositories on SIGINT or SIGTERM, and still report what they did
+	ctx, stop := signalContext()
+	defer stop()
 
 	switch p {
 
@@ -68,99 +116,138 @@ func Execute() { // hello
 		fmt.Printf("Block New: %v\n", *appSyncBlockNewMigrations)
 
 	case appInteractive.FullCommand():
-		fmt.Println("INTERACTIVE")
-		fmt.Printf("App Log Level: %v\n", *appLogLevel)
+		integrations, err := selectIntegrations(config.Integrations, *appInteractiveIntegration)
+		if err == nil {
+			err = interactive(ctx, integrations)
+		}
+		writeMetrics(*appMetricsFile)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"integration": *appInteractiveIntegration,
+				"error":       err.Error(),
+			}).Errorf("Interactive selection failed")
+			os.Exit(1)
+		}
 
-	case appTest.FullCommand():
-		fmt.Printf("TEST")
-		var input plugins.Input
-		var output plugins.Output
-		for _, integration := range config.Integrations {
-			fmt.Println(integration.Name)
+	case appUsersMap.FullCommand():
+		integration, err := findIntegration(config.Integrations, *appUsersMapIntegration)
+		if err == nil {
+			err = mapUsers(ctx, integration, os.Stdout)
+		}
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"integration": *appUsersMapIntegration,
+				"error":       err.Error(),
+			}).Errorf("Failed to map users")
+			os.Exit(1)
+		}
 
-			var err error
-			// INPUT PLUGIN
-			// get input plugin based on input type
-			switch integration.Source.Type {
-			case "bitbucket-cloud":
-				input, err = bbcloud.New(integration.Source)
+	case appMigrateFinalize.FullCommand():
+		integration, err := findIntegration(config.Integrations, *appMigrateFinalizeIntegration)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"integration": *appMigrateFinalizeIntegration,
+				"error":       err.Error(),
+			}).Errorf("Failed to finalize migration")
+			os.Exit(1)
+		}
+		report, err := finalize(ctx, integration)
+		writeMetrics(*appMetricsFile)
+		if err != nil {
+			os.Exit(1)
+		}
+		err = writeReport(report, *appMigrateFinalizeReport, os.Stdout)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"integration": integration.Name,
+				"error":       err.Error(),
+			}).Errorf("Writing cut-over report failed")
+			os.Exit(1)
+		}
+		if len(report.Failed()) > 0 || report.Canceled {
+			os.Exit(1)
+		}
 
-			case "bitbucket-server":
-				input, err = bbserver.New(integration.Source)
+	case appVerify.FullCommand():
+		integrations, err := selectIntegrations(config.Integrations, *appVerifyIntegration)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"integration": *appVerifyIntegration,
+				"error":       err.Error(),
+			}).Errorf("Failed to verify integration")
+			os.Exit(1)
+		}
 
-			default:
-				log.WithFields(logrus.Fields{
-					"integration": integration.Name,
-					"source":      integration.Source.Type,
-				}).Errorf("Unsupported source type")
+		drifted := false
+		reports := []git.VerifyReport{}
+		for _, integration := range integrations {
+			if ctx.Err() != nil {
+				break
 			}
-			// Error during initializing source input plugin
+			report, err := verify(ctx, integration)
 			if err != nil {
 				log.WithFields(logrus.Fields{
-					"error":       err.Error(),
 					"integration": integration.Name,
-					"targetType":  integration.Source.Type,
-				}).Errorf("Initializing source failed")
-			}
-
-			// Authenticate credentials for reading from input
-			_, err = input.Authenticate()
-			if err != nil {
-				log.WithFields(logrus.Fields{
 					"error":       err.Error(),
-					"integration": integration.Name,
-					"source":      integration.Source.Type,
-				}).Errorf("Source authentication failed")
-			}
-			// Get repositories to sync
-			repos, err := input.Repositories(true)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"error":       err.Error(),
-					"integration": integration.Name,
-					"source":      integration.Source.Type,
-				}).Errorf("Fetching repository list failed")
+				}).Errorf("Failed to verify integration")
+				drifted = true
+				continue
 			}
+			drifted = drifted || len(report.Drifted()) > 0 || report.Canceled
+			reports = append(reports, report)
+		}
 
-			// OUTPUT PLUGIN
-			// get output plugin based on output type
-			switch integration.Target.Type {
-			case "github-public":
-				output, err = ghpublic.New(integration.Target)
-				if err != nil {
-					log.WithFields(logrus.Fields{
-						"error":       err.Error(),
-						"integration": integration.Name,
-						"targetType":  integration.Target.Type,
-					}).Errorf("Initializing target failed")
-				}
-
-			default:
-				log.WithFields(logrus.Fields{
-					"integration": integration.Name,
-					"targetType":  integration.Target.Type,
-				}).Errorf("Unsupported target type")
+		err = writeReport(reports, *appVerifyReport, os.Stdout)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Errorf("Writing verification report failed")
+			os.Exit(1)
+		}
+		// Drift fails the command so it can gate a cut-over, as does a canceled verification
+		if drifted || ctx.Err() != nil {
+			os.Exit(1)
+		}
+
+	case appServe.FullCommand():
+		gitsink := pkg.App{
+			Config: &pkg.AppConfig{
+				LogLevel:   *appLogLevel,
+				ServerHost: *appServeHost,
+				ServerPort: *appServePort,
+			},
+			Secrets: &pkg.AppSecrets{
+				AuthToken: os.Getenv(*appServeTokenEnv),
+			},
+		}
+		err := serve(ctx, gitsink, config.Integrations)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Errorf("Serving API failed")
+			os.Exit(1)
+		}
+
+	case appTest.FullCommand():
+		fmt.Printf("TEST")
+		for _, integration := range config.Integrations {
+			if ctx.Err() != nil {
+				break
 			}
-			// Authenticate credentials for pushing to output
-			_, err = output.Authenticate()
+			fmt.Println(integration.Name)
+
+			ctx, runID := runContext(ctx, integration)
+			input, output, repos, err := prepare(ctx, integration)
 			if err != nil {
-				log.WithFields(logrus.Fields{
-					"error":       err.Error(),
-					"integration": integration.Name,
-					"source":      integration.Target.Type,
-				}).Errorf("Target authentication failed")
-			} else {
-				log.Infof("GITHUB PUBLIC SUCCESS")
+				continue
 			}
 
 			// SYNC REPOS
-			// Check if repos need to by synced or migrated
-			// Makes new repo on target if there doesn't already exist one
-			repos = output.SyncCheck(repos)
-
-			// Start syncing repos
-			gitClient := git.New(input, output, integration.Name)
-			gitClient.SyncRepos(repos)
+			report := newGitClient(ctx, integration, input, output).SyncRepos(ctx, repos)
+			report.RunID = runID
+			logFailed(ctx, integration, report)
+			sendNotifications(ctx, integration, report)
 		}
+		writeMetrics(*appMetricsFile)
 	}
 }
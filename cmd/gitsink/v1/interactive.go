@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	logrus "github.com/sirupsen/logrus"
+	terminal "golang.org/x/crypto/ssh/terminal"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	picker "github.com/parinithshekar/gitsink/common/picker"
+	utils "github.com/parinithshekar/gitsink/common/utils"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// pickerChrome is the number of terminal rows the picker uses besides its items
+const pickerChrome = 4
+
+// targetStatus describes whether each repository is already on the target, by slug
+// Targets that cannot be looked up without creating repositories have no status
+func targetStatus(ctx context.Context, output plugins.Output, repos []common.Repository) map[string]string {
+	status := map[string]string{}
+
+	found, err := lookupTargets(ctx, output, repos)
+	if errors.Is(err, v2.ErrUnsupported) {
+		return status
+	}
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Warningf("Looking up target repositories failed")
+		return status
+	}
+	for _, repo := range found {
+		if repo.Target != "" {
+			status[repo.Slug] = "on target"
+		} else {
+			status[repo.Slug] = "new"
+		}
+	}
+	return status
+}
+
+// pick shows the picker on the terminal in raw mode and gives the action that ended it
+func pick(list *picker.Picker) (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer terminal.Restore(fd, state)
+
+	action, err := list.Run(os.Stdin, os.Stdout)
+	// Clear the picker off the screen before logs are written again
+	fmt.Print("\x1b[H\x1b[2J")
+	return action, err
+}
+
+// interactive lets the repositories of each integration be picked on the terminal, saving the
+// selection to the config file or syncing the selected repositories right away
+// Runs started from it stop starting repositories once ctx is canceled
+func interactive(ctx context.Context, integrations []config.Integration) error {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return fmt.Errorf("Interactive mode needs a terminal")
+	}
+	height := 20
+	if _, rows, err := terminal.GetSize(fd); err == nil && rows > pickerChrome+1 {
+		height = rows - pickerChrome
+	}
+
+	for _, integration := range integrations {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ctx, runID := runContext(ctx, integration)
+		input, output, repos, err := connect(ctx, integration, false)
+		if err != nil {
+			continue
+		}
+
+		// Repositories the config already syncs start selected
+		included := map[string]bool{}
+		for _, repo := range utils.FilterRepos(repos, integration.Source.Repositories.Include, integration.Source.Repositories.Exclude) {
+			included[repo.Slug] = true
+		}
+		status := targetStatus(ctx, output, repos)
+
+		var items []picker.Item
+		for _, repo := range repos {
+			items = append(items, picker.Item{Label: repo.Slug, Status: status[repo.Slug], Selected: included[repo.Slug]})
+		}
+		list := picker.New(fmt.Sprintf("%v: %v to %v", integration.Name, integration.Source.Type, integration.Target.Type), items, height)
+
+		action, err := pick(list)
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case picker.ActionSave:
+			repositories := config.Repositories{Include: list.Selected()}
+			if len(list.Items) > 0 && len(repositories.Include) == len(list.Items) {
+				repositories.Include = []string{"/.*/"}
+			}
+			err = config.SaveRepositories(integration.Name, repositories)
+			if err != nil {
+				return err
+			}
+			log.WithFields(logrus.Fields{
+				"integration":  integration.Name,
+				"repositories": len(list.Selected()),
+			}).Infof("Repository selection saved to config")
+
+		case picker.ActionRun:
+			selected := map[string]bool{}
+			for _, slug := range list.Selected() {
+				selected[slug] = true
+			}
+			var syncRepos []common.Repository
+			for _, repo := range repos {
+				if selected[repo.Slug] {
+					syncRepos = append(syncRepos, repo)
+				}
+			}
+
+			// Orphans are not reconciled, the repositories left out are not gone from the source
+			syncRepos, err = readyTargets(ctx, integration, input, output, syncRepos)
+			if err != nil {
+				continue
+			}
+			report := newGitClient(ctx, integration, input, output).SyncRepos(ctx, syncRepos)
+			report.RunID = runID
+			logFailed(ctx, integration, report)
+			sendNotifications(ctx, integration, report)
+
+		case picker.ActionQuit:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"fmt"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	users "github.com/parinithshekar/gitsink/common/users"
+	bbcloud "github.com/parinithshekar/gitsink/plugins/input/bitbucket/cloud"
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	ghsource "github.com/parinithshekar/gitsink/plugins/input/github/public"
+	gitlab "github.com/parinithshekar/gitsink/plugins/input/gitlab"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
+)
+
+// newInput gives the input plugin for the source type of the integration
+func newInput(integration config.Integration) (plugins.Input, error) {
+	switch integration.Source.Type {
+	case "bitbucket-cloud":
+		return bbcloud.New(integration.Source)
+
+	case "bitbucket-server":
+		return bbserver.New(integration.Source)
+
+	case "github-public":
+		return ghsource.New(integration.Source)
+
+	case "gitlab":
+		return gitlab.New(integration.Source)
+
+	default:
+		return nil, fmt.Errorf("Unsupported source type")
+	}
+}
+
+// newOutput gives the output plugin for the target type of the integration
+func newOutput(integration config.Integration) (plugins.Output, error) {
+	switch integration.Target.Type {
+	case "github-public":
+		return ghpublic.New(integration.Target)
+
+	default:
+		return nil, fmt.Errorf("Unsupported target type")
+	}
+}
+
+// newUserMapper loads the user mapping of the integration and hands it to the output plugin
+// Email lookup uses the plugins that can find users by email address
+func newUserMapper(integration config.Integration, input plugins.Input, output plugins.Output) (*users.Mapper, error) {
+	mapper, err := users.New(integration.UserMapping.File)
+	if err != nil {
+		return nil, err
+	}
+
+	if integration.UserMapping.EmailLookup {
+		finder, ok := output.(users.LoginFinder)
+		if !ok {
+			return nil, fmt.Errorf("Target does not support email lookup")
+		}
+		// Sources without email lookup can still be mapped by the emails they give with users
+		source, _ := input.(users.EmailSource)
+		mapper.EnableLookup(source, finder)
+	}
+
+	if consumer, ok := output.(plugins.UserMapperConsumer); ok {
+		consumer.SetUserMapper(mapper)
+	}
+	return mapper, nil
+}
+
+// findIntegration looks up an integration in config by its name
+func findIntegration(integrations []config.Integration, name string) (config.Integration, error) {
+	for _, integration := range integrations {
+		if integration.Name == name {
+			return integration, nil
+		}
+	}
+	return config.Integration{}, fmt.Errorf("Integration not found")
+}
+
+// selectIntegrations gives the named integration, or every enabled integration without a name
+func selectIntegrations(integrations []config.Integration, name string) ([]config.Integration, error) {
+	if name != "" {
+		integration, err := findIntegration(integrations, name)
+		if err != nil {
+			return nil, err
+		}
+		return []config.Integration{integration}, nil
+	}
+
+	var enabled []config.Integration
+	for _, integration := range integrations {
+		if integration.Enabled {
+			enabled = append(enabled, integration)
+		}
+	}
+	return enabled, nil
+}
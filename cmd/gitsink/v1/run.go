@@ -0,0 +1,443 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	notify "github.com/parinithshekar/gitsink/notify"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
+)
+
+var (
+	log = logger.Root()
+)
+
+// signalContext is canceled on the first SIGINT or SIGTERM, so runs start no more repositories
+// and report what they did. A second signal exits right away
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		log.WithFields(logrus.Fields{
+			"signal": received.String(),
+		}).Warningf("Shutting down once the repositories being synced are done")
+		cancel()
+
+		received = <-signals
+		log.WithFields(logrus.Fields{
+			"signal": received.String(),
+		}).Errorf("Exiting without waiting for the repositories being synced")
+		os.Exit(1)
+	}()
+	return ctx, cancel
+}
+
+// runContext starts a run of the integration under ctx, with a logger carrying its run ID and name
+func runContext(ctx context.Context, integration config.Integration) (context.Context, string) {
+	runID := logger.NewRunID()
+	runLog := logger.Root().WithFields(logrus.Fields{
+		"runId":       runID,
+		"integration": integration.Name,
+	})
+	return logger.NewContext(ctx, runLog), runID
+}
+
+// bindRun has the plugin log through the logger of the run, if it can
+func bindRun(ctx context.Context, plugin interface{}) {
+	if consumer, ok := plugin.(plugins.LoggerConsumer); ok {
+		consumer.SetLogger(logger.FromContext(ctx))
+	}
+}
+
+// newGitClient gives the git client of the integration, logging through the logger of the run
+func newGitClient(ctx context.Context, integration config.Integration, input plugins.Input, output plugins.Output) *git.Client {
+	gitClient := git.New(input, output, integration.Name, gitOptions(integration))
+	gitClient.SetLogger(logger.FromContext(ctx))
+	return gitClient
+}
+
+// connect authenticates both sides of the integration and gives the source repositories that
+// match the config, with their topics when metadata is set. Failures are logged before being returned
+func connect(ctx context.Context, integration config.Integration, metadata bool) (plugins.Input, plugins.Output, []common.Repository, error) {
+	runLog := logger.FromContext(ctx)
+
+	// INPUT PLUGIN
+	// get input plugin based on input type
+	input, err := newInput(integration)
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"error":       err.Error(),
+			"integration": integration.Name,
+			"source":      integration.Source.Type,
+		}).Errorf("Initializing source failed")
+		return nil, nil, nil, err
+	}
+	bindRun(ctx, input)
+
+	// Authenticate credentials for reading from input
+	source := v2.FromInput(input)
+	err = source.Authenticate(ctx)
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"error":       err.Error(),
+			"errorKind":   errorKind(err),
+			"integration": integration.Name,
+			"source":      integration.Source.Type,
+		}).Errorf("Source authentication failed")
+		return nil, nil, nil, err
+	}
+	// Get repositories to sync
+	repos, err := source.Repositories(ctx, v2.ListOptions{Metadata: metadata})
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"error":       err.Error(),
+			"errorKind":   errorKind(err),
+			"integration": integration.Name,
+			"source":      integration.Source.Type,
+		}).Errorf("Fetching repository list failed")
+		return nil, nil, nil, err
+	}
+
+	// OUTPUT PLUGIN
+	// get output plugin based on output type
+	output, err := newOutput(integration)
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"error":       err.Error(),
+			"integration": integration.Name,
+			"targetType":  integration.Target.Type,
+		}).Errorf("Initializing target failed")
+		return nil, nil, nil, err
+	}
+	bindRun(ctx, output)
+	// Authenticate credentials for pushing to output
+	err = v2.FromOutput(output).Authenticate(ctx)
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"error":       err.Error(),
+			"errorKind":   errorKind(err),
+			"integration": integration.Name,
+			"source":      integration.Target.Type,
+		}).Errorf("Target authentication failed")
+		return nil, nil, nil, err
+	}
+
+	return input, output, repos, nil
+}
+
+// prepare authenticates both sides of the integration and gives the repositories to sync,
+// with their target repositories created. Failures are logged before being returned
+func prepare(ctx context.Context, integration config.Integration) (plugins.Input, plugins.Output, []common.Repository, error) {
+	runLog := logger.FromContext(ctx)
+
+	input, output, repos, err := connect(ctx, integration, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Retire mirrors on the target whose source repository is gone
+	if reconciler, ok := output.(plugins.OrphanTarget); ok && integration.Target.Orphans.Action != "" {
+		orphans, err := reconciler.ReconcileOrphans(ctx, repos)
+		if err != nil {
+			runLog.WithFields(logrus.Fields{
+				"error":       err.Error(),
+				"integration": integration.Name,
+				"orphans":     orphans,
+			}).Warningf("Reconciling orphaned repositories failed")
+		} else if len(orphans) > 0 {
+			runLog.WithFields(logrus.Fields{
+				"integration": integration.Name,
+				"orphans":     orphans,
+			}).Infof("Orphaned repositories retired")
+		}
+	}
+
+	repos, err = readyTargets(ctx, integration, input, output, repos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return input, output, repos, nil
+}
+
+// readyTargets sets up the target for syncing the repositories and gives them with their
+// target repositories created
+func readyTargets(ctx context.Context, integration config.Integration, input plugins.Input, output plugins.Output, repos []common.Repository) ([]common.Repository, error) {
+	runLog := logger.FromContext(ctx)
+
+	// Attribute source users on the target with their mapped logins
+	_, err := newUserMapper(integration, input, output)
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"error":       err.Error(),
+			"integration": integration.Name,
+		}).Errorf("Loading user mapping failed")
+		return nil, err
+	}
+
+	source, target := v2.FromInput(input), v2.FromOutput(output)
+	warnUnsupported(ctx, integration, source.Capabilities(), target.Capabilities())
+
+	// Makes new repo on target if there doesn't already exist one
+	// Repositories whose target cannot be created are skipped, unless the target rejects the
+	// credentials, which fails them all
+	var ready []common.Repository
+	for i, repo := range repos {
+		if ctx.Err() != nil {
+			// The run starts no more repositories, which lists the rest as skipped
+			ready = append(ready, repos[i:]...)
+			break
+		}
+		created, err := target.CreateTarget(ctx, repo)
+		if errors.Is(err, v2.ErrUnauthorized) {
+			runLog.WithFields(logrus.Fields{
+				"error":       err.Error(),
+				"integration": integration.Name,
+			}).Errorf("Target rejected the credentials")
+			return nil, err
+		}
+		if err != nil {
+			runLog.WithFields(logrus.Fields{
+				"error":       err.Error(),
+				"errorKind":   errorKind(err),
+				"integration": integration.Name,
+				"repository":  repo.Slug,
+			}).Warningf("Target repository not ready, skipping repository")
+			continue
+		}
+		ready = append(ready, created)
+	}
+	return ready, nil
+}
+
+// lookupTargets gives the repositories with the URLs of their target repositories, without
+// creating them. Repositories missing on the target have no target URL
+func lookupTargets(ctx context.Context, output plugins.Output, repos []common.Repository) ([]common.Repository, error) {
+	target := v2.FromOutput(output)
+	var found []common.Repository
+	for _, repo := range repos {
+		targetRepo, err := target.LookupTarget(ctx, repo)
+		switch {
+		case errors.Is(err, v2.ErrNotFound):
+			repo.Target = ""
+		case err != nil:
+			return nil, err
+		default:
+			repo = targetRepo
+		}
+		found = append(found, repo)
+	}
+	return found, nil
+}
+
+// warnUnsupported warns about the migrations in config that the source or target cannot do
+func warnUnsupported(ctx context.Context, integration config.Integration, source, target v2.Capabilities) {
+	var unsupported []string
+	if integration.Migrate.PullRequests.Enabled && !(source.PullRequests && target.PullRequests) {
+		unsupported = append(unsupported, "pull_requests")
+	}
+	if integration.Migrate.Issues.Enabled && !(source.Issues && target.Issues) {
+		unsupported = append(unsupported, "issues")
+	}
+	// Releases made from tags need no releases on the source
+	if integration.Migrate.Releases.Enabled && !(target.Releases && (source.Releases || integration.Migrate.Releases.FromTags)) {
+		unsupported = append(unsupported, "releases")
+	}
+	if integration.SyncWiki && !(source.Wiki && target.Wiki) {
+		unsupported = append(unsupported, "sync_wiki")
+	}
+	if len(unsupported) > 0 {
+		logger.FromContext(ctx).WithFields(logrus.Fields{
+			"integration": integration.Name,
+			"unsupported": unsupported,
+		}).Warningf("Source or target does not support some migrations in config")
+	}
+}
+
+// errorKind gives the class of failure of a plugin error, empty when it has none
+func errorKind(err error) string {
+	var opError *v2.OpError
+	if errors.As(err, &opError) {
+		return string(opError.Kind)
+	}
+	return ""
+}
+
+// gitOptions gives the options of the git client for the integration
+func gitOptions(integration config.Integration) git.Options {
+	options := git.Options{
+		PushChunkSize:     integration.Target.PushChunkSize,
+		Issues:            integration.Migrate.Issues.Enabled,
+		Wiki:              integration.SyncWiki,
+		TwoWay:            integration.Sync.TwoWay(),
+		BranchSides:       integration.Sync.Branches,
+		Releases:          integration.Migrate.Releases.Enabled,
+		ReleasesFromTags:  integration.Migrate.Releases.FromTags,
+		RepositoryTimeout: time.Duration(integration.Sync.RepositoryTimeout) * time.Second,
+	}
+	if integration.Migrate.PullRequests.Enabled {
+		options.PullRequestStates = integration.Migrate.PullRequests.States
+		if len(options.PullRequestStates) == 0 {
+			options.PullRequestStates = []string{common.PullRequestOpen}
+		}
+	}
+	return options
+}
+
+// logFailed warns about every repository of the report that was not completely synced
+func logFailed(ctx context.Context, integration config.Integration, report git.Report) {
+	for _, repoReport := range report.Failed() {
+		fields := logrus.Fields{
+			"integration":    integration.Name,
+			"repository":     repoReport.Slug,
+			"failedTags":     repoReport.FailedTags,
+			"failedBranches": repoReport.FailedBranches,
+			"rejections":     repoReport.Rejections,
+			"error":          repoReport.Error,
+		}
+		if repoReport.Cutover != nil {
+			fields["cutoverError"] = repoReport.Cutover.Error
+			fields["mismatches"] = repoReport.Cutover.Mismatches
+		}
+		logger.FromContext(ctx).WithFields(fields).Warningf("Repository not completely synced")
+	}
+}
+
+// sendNotifications sends the digest of the run to the notifications of the integration
+// The failures of the run are kept in its sync directory to tell new failures apart
+func sendNotifications(ctx context.Context, integration config.Integration, report git.Report) {
+	if len(integration.Notifications) == 0 {
+		return
+	}
+	notifier, err := notify.New(integration, filepath.Join(git.Directory(integration.Name), ".notified.json"))
+	if err != nil {
+		logger.FromContext(ctx).WithFields(logrus.Fields{
+			"integration": integration.Name,
+			"error":       err.Error(),
+		}).Errorf("Initializing notifications failed")
+		return
+	}
+	// Failed notifications are logged by the notifier
+	notifier.Notify(ctx, report)
+}
+
+// writeReport writes the report as JSON to the file, or to out when no file is given
+func writeReport(report interface{}, file string, out io.Writer) error {
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeMetrics exports the metrics of a one-shot run to the textfile, when one is given
+func writeMetrics(file string) {
+	if file == "" {
+		return
+	}
+	err := metrics.WriteTextfile(file)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"file":  file,
+			"error": err.Error(),
+		}).Errorf("Writing metrics failed")
+	}
+}
+
+// finalize runs the cut-over of the integration and gives its report, after logging and
+// notifying its failures through the logger of the run
+func finalize(ctx context.Context, integration config.Integration) (git.Report, error) {
+	ctx, runID := runContext(ctx, integration)
+	input, output, repos, err := prepare(ctx, integration)
+	if err != nil {
+		return git.Report{}, err
+	}
+	report := newGitClient(ctx, integration, input, output).Finalize(ctx, repos)
+	report.RunID = runID
+	logFailed(ctx, integration, report)
+	sendNotifications(ctx, integration, report)
+	return report, nil
+}
+
+// verify compares the source and target refs of the integration without syncing or creating
+// target repositories
+func verify(ctx context.Context, integration config.Integration) (git.VerifyReport, error) {
+	ctx, _ = runContext(ctx, integration)
+	input, output, repos, err := connect(ctx, integration, true)
+	if err != nil {
+		return git.VerifyReport{}, err
+	}
+
+	repos, err = lookupTargets(ctx, output, repos)
+	if err != nil {
+		return git.VerifyReport{}, err
+	}
+
+	return newGitClient(ctx, integration, input, output).Verify(ctx, repos), nil
+}
+
+// syncIntegration syncs the repositories of the integration, or only the repository with the
+// slug when one is given. Orphans are only reconciled when every repository is synced
+// Repositories are no longer started once ctx is canceled
+func syncIntegration(ctx context.Context, integration config.Integration, slug string) (git.Report, error) {
+	var (
+		input  plugins.Input
+		output plugins.Output
+		repos  []common.Repository
+		err    error
+	)
+	ctx, runID := runContext(ctx, integration)
+	if slug == "" {
+		input, output, repos, err = prepare(ctx, integration)
+		if err != nil {
+			return git.Report{}, err
+		}
+	} else {
+		input, output, repos, err = connect(ctx, integration, true)
+		if err != nil {
+			return git.Report{}, err
+		}
+		var selected []common.Repository
+		for _, repo := range repos {
+			if repo.Slug == slug {
+				selected = append(selected, repo)
+			}
+		}
+		if len(selected) == 0 {
+			return git.Report{}, fmt.Errorf("Repository %v not found in integration", slug)
+		}
+		repos, err = readyTargets(ctx, integration, input, output, selected)
+		if err != nil {
+			return git.Report{}, err
+		}
+	}
+
+	report := newGitClient(ctx, integration, input, output).SyncRepos(ctx, repos)
+	report.RunID = runID
+	logFailed(ctx, integration, report)
+	sendNotifications(ctx, integration, report)
+	return report, nil
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	daemon "github.com/parinithshekar/gitsink/daemon"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+)
+
+// shutdownTimeout is how long API requests get to finish once the daemon stops
+const shutdownTimeout = 10 * time.Second
+
+// serve runs the integrations on their schedule and serves the API of the daemon until ctx is
+// canceled. The API keeps answering while the running sync finishes its started repositories
+func serve(ctx context.Context, app pkg.App, integrations []config.Integration) error {
+	if app.Secrets.AuthToken == "" {
+		return fmt.Errorf("Auth token not set, the API would be open to anyone")
+	}
+
+	syncDaemon := daemon.New(integrations, syncIntegration)
+	stopped := make(chan struct{})
+	go func() {
+		syncDaemon.Run(ctx)
+		close(stopped)
+	}()
+
+	address := fmt.Sprintf("%v:%v", app.Config.ServerHost, app.Config.ServerPort)
+	server := &http.Server{Addr: address, Handler: syncDaemon.Handler(app.Secrets.AuthToken)}
+	go func() {
+		<-stopped
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.WithFields(logrus.Fields{
+		"address":      address,
+		"integrations": len(integrations),
+	}).Infof("Serving API")
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		log.Infof("Daemon stopped")
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// sourceUsers collects the distinct users taking part in the pull requests of the repositories
+func sourceUsers(ctx context.Context, input plugins.Input, repos []common.Repository) ([]common.User, error) {
+	source, ok := input.(plugins.PullRequestSource)
+	if !ok {
+		return nil, fmt.Errorf("Source does not support listing users")
+	}
+
+	allStates := []string{common.PullRequestOpen, common.PullRequestMerged, common.PullRequestDeclined}
+	found := map[string]common.User{}
+	add := func(user common.User) {
+		key := strings.ToLower(user.Name)
+		if key == "" {
+			return
+		}
+		// Keep the most complete record of the user
+		if existing, ok := found[key]; !ok || existing.Email == "" {
+			found[key] = user
+		}
+	}
+
+	for _, repo := range repos {
+		pullRequests, err := source.PullRequests(ctx, repo, allStates)
+		if err != nil {
+			return nil, err
+		}
+		for _, pullRequest := range pullRequests {
+			add(pullRequest.Author)
+			for _, reviewer := range pullRequest.Reviewers {
+				add(reviewer)
+			}
+			for _, comment := range pullRequest.Comments {
+				add(comment.Author)
+			}
+		}
+	}
+
+	sourceUsers := []common.User{}
+	for _, user := range found {
+		sourceUsers = append(sourceUsers, user)
+	}
+	sort.Slice(sourceUsers, func(i, j int) bool {
+		return strings.ToLower(sourceUsers[i].Name) < strings.ToLower(sourceUsers[j].Name)
+	})
+	return sourceUsers, nil
+}
+
+// mapUsers prints the target login of every source user of the integration and the users left unmapped
+func mapUsers(ctx context.Context, integration config.Integration, out io.Writer) error {
+	input, err := newInput(integration)
+	if err != nil {
+		return err
+	}
+	source := v2.FromInput(input)
+	err = source.Authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	output, err := newOutput(integration)
+	if err != nil {
+		return err
+	}
+	mapper, err := newUserMapper(integration, input, output)
+	if err != nil {
+		return err
+	}
+
+	repos, err := source.Repositories(ctx, v2.ListOptions{})
+	if err != nil {
+		return err
+	}
+	sourceUsers, err := sourceUsers(ctx, input, repos)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SOURCE USER\tNAME\tEMAIL\tTARGET LOGIN")
+	for _, user := range sourceUsers {
+		login, ok := mapper.TargetUser(ctx, user)
+		if !ok {
+			login = "-"
+		}
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\n", user.Name, user.DisplayName, user.Email, login)
+	}
+	table.Flush()
+
+	unmapped := mapper.Unmapped(ctx, sourceUsers)
+	fmt.Fprintf(out, "\n%v of %v users unmapped\n", len(unmapped), len(sourceUsers))
+	for _, user := range unmapped {
+		fmt.Fprintf(out, "  %v\n", user.Name)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// sameTransport checks if two sets of HTTP options build the same transport
+// The timeout only applies to API calls, so it may differ
+func sameTransport(a, b HTTP) bool {
+	return a.CABundle == b.CABundle && a.ClientCert == b.ClientCert && a.ClientKey == b.ClientKey && a.Proxy == b.Proxy
+}
+
+// sourceHost gives the host the git traffic of a source goes to
+// Sources without a base URL use the public host of their type
+func sourceHost(source Source) string {
+	if source.BaseURL != "" {
+		if baseURL, err := url.Parse(source.BaseURL); err == nil && baseURL.Host != "" {
+			return baseURL.Host
+		}
+	}
+	return source.Type
+}
+
+// CheckTransports fails if integrations with the same source host have different transport options
+// git traffic is routed by host for the whole process, so only one set of options can be used per host
+func (config Config) CheckTransports() error {
+	first := map[string]Integration{}
+	for _, integration := range config.Integrations {
+		host := sourceHost(integration.Source)
+		other, exists := first[host]
+		if !exists {
+			first[host] = integration
+			continue
+		}
+		if !sameTransport(other.Source.HTTP, integration.Source.HTTP) {
+			return fmt.Errorf("Integrations %v and %v have different http options for %v", other.Name, integration.Name, host)
+		}
+	}
+	return nil
+}
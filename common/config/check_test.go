@@ -0,0 +1,42 @@
+package config_test
+
+import (
+	"testing"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+)
+
+func TestCheckTransports(t *testing.T) {
+	proxy := config.HTTP{Proxy: "http://proxy.company.com:3128"}
+	integration := func(name, baseURL string, options config.HTTP) config.Integration {
+		return config.Integration{
+			Name:   name,
+			Source: config.Source{Type: "bitbucket-server", BaseURL: baseURL, HTTP: options},
+		}
+	}
+
+	cases := map[string]struct {
+		Integrations  []config.Integration
+		ExpectedError bool
+	}{
+		"Same options": {[]config.Integration{
+			integration("first", "https://bitbucket.company.com", proxy),
+			integration("second", "https://bitbucket.company.com/bitbucket", config.HTTP{Proxy: proxy.Proxy, Timeout: 30}),
+		}, false},
+		"Different hosts": {[]config.Integration{
+			integration("first", "https://bitbucket.company.com", proxy),
+			integration("second", "https://git.company.com", config.HTTP{CABundle: "company-ca.pem"}),
+		}, false},
+		"Different options": {[]config.Integration{
+			integration("first", "https://bitbucket.company.com", proxy),
+			integration("second", "https://bitbucket.company.com", config.HTTP{}),
+		}, true},
+	}
+
+	for tcName, tc := range cases {
+		err := config.Config{Integrations: tc.Integrations}.CheckTransports()
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+		}
+	}
+}
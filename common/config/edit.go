@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// integrationName matches the first line of an integration, capturing its indent and name
+	integrationName = regexp.MustCompile(`^(\s*)-\s+name:\s*["']?([^"'#]*?)["']?\s*(#.*)?$`)
+)
+
+// indent gives the number of leading spaces of a line, or -1 for blank and comment lines
+func indent(line string) int {
+	trimmed := strings.TrimLeft(line, " ")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return -1
+	}
+	return len(line) - len(trimmed)
+}
+
+// blockEnd gives the line after the block that starts at line start, where the block holds
+// every following line indented deeper than limit. Trailing blank and comment lines are left out
+func blockEnd(lines []string, start, limit int) int {
+	end := start + 1
+	for i := start + 1; i < len(lines); i++ {
+		lineIndent := indent(lines[i])
+		if lineIndent == -1 {
+			continue
+		}
+		if lineIndent <= limit {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// findKey gives the line of key directly in the block between start and end, or -1
+func findKey(lines []string, start, end int, key string) int {
+	keyIndent := -1
+	for i := start; i < end; i++ {
+		lineIndent := indent(lines[i])
+		if lineIndent == -1 {
+			continue
+		}
+		if keyIndent == -1 {
+			keyIndent = lineIndent
+		}
+		if lineIndent == keyIndent && strings.HasPrefix(strings.TrimSpace(lines[i]), key+":") {
+			return i
+		}
+	}
+	return -1
+}
+
+// repositoriesBlock writes the repos key of a source in the style of the config file
+func repositoriesBlock(repos Repositories, keyIndent int) []string {
+	pad := strings.Repeat(" ", keyIndent)
+	block := []string{pad + "repos:", pad + "  include:"}
+	for _, pattern := range repos.Include {
+		value, _ := yaml.Marshal(pattern)
+		block = append(block, pad+"    - "+strings.TrimSpace(string(value)))
+	}
+	if len(repos.Include) == 0 {
+		block[1] += " []"
+	}
+	if len(repos.Exclude) == 0 {
+		return append(block, pad+"  exclude: []")
+	}
+	block = append(block, pad+"  exclude:")
+	for _, pattern := range repos.Exclude {
+		value, _ := yaml.Marshal(pattern)
+		block = append(block, pad+"    - "+strings.TrimSpace(string(value)))
+	}
+	return block
+}
+
+// samePatterns compares two filter lists, where nil and empty lists are the same
+func samePatterns(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// SetRepositories replaces the repository filters of the named integration in a config file
+// Only the repos key of its source is rewritten, so the comments in the rest of the file are kept
+func SetRepositories(file []byte, name string, repos Repositories) ([]byte, error) {
+	lines := strings.Split(string(file), "\n")
+
+	// Find the integration and the end of its list item
+	start, itemIndent := -1, -1
+	for i, line := range lines {
+		match := integrationName.FindStringSubmatch(line)
+		if match != nil && match[2] == name {
+			start, itemIndent = i, len(match[1])
+			break
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("Integration %v not found in config", name)
+	}
+	end := blockEnd(lines, start, itemIndent)
+
+	// The keys of the item are indented past the list dash
+	afterDash := strings.TrimLeft(lines[start], " ")[1:]
+	keyIndent := itemIndent + 1 + len(afterDash) - len(strings.TrimLeft(afterDash, " "))
+	source := -1
+	for i := start + 1; i < end; i++ {
+		if indent(lines[i]) == keyIndent && strings.HasPrefix(strings.TrimSpace(lines[i]), "source:") {
+			source = i
+			break
+		}
+	}
+	if source == -1 {
+		return nil, fmt.Errorf("Integration %v has no source in config", name)
+	}
+	sourceEnd := blockEnd(lines, source, keyIndent)
+
+	var updated []string
+	reposLine := findKey(lines, source+1, sourceEnd, "repos")
+	if reposLine == -1 {
+		// Add the filters at the end of the source
+		reposIndent := keyIndent + 2
+		for i := source + 1; i < sourceEnd; i++ {
+			if lineIndent := indent(lines[i]); lineIndent != -1 {
+				reposIndent = lineIndent
+				break
+			}
+		}
+		updated = append(updated, lines[:sourceEnd]...)
+		updated = append(updated, repositoriesBlock(repos, reposIndent)...)
+		updated = append(updated, lines[sourceEnd:]...)
+	} else {
+		reposIndent := indent(lines[reposLine])
+		reposEnd := blockEnd(lines, reposLine, reposIndent)
+		block := repositoriesBlock(repos, reposIndent)
+
+		// Comments heading the filters are kept
+		var comments []string
+		for i := reposLine + 1; i < reposEnd && strings.HasPrefix(strings.TrimSpace(lines[i]), "#"); i++ {
+			comments = append(comments, lines[i])
+		}
+
+		updated = append(updated, lines[:reposLine]...)
+		updated = append(updated, block[0])
+		updated = append(updated, comments...)
+		updated = append(updated, block[1:]...)
+		updated = append(updated, lines[reposEnd:]...)
+	}
+	result := []byte(strings.Join(updated, "\n"))
+
+	// Make sure the edit gives the filters it was asked for
+	config := Config{}
+	err := yaml.Unmarshal(result, &config)
+	if err != nil {
+		return nil, err
+	}
+	for _, integration := range config.Integrations {
+		if integration.Name != name {
+			continue
+		}
+		actual := integration.Source.Repositories
+		if !samePatterns(actual.Include, repos.Include) || !samePatterns(actual.Exclude, repos.Exclude) {
+			return nil, fmt.Errorf("Rewriting repositories of integration %v failed", name)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("Rewriting repositories of integration %v failed", name)
+}
+
+// SaveRepositories replaces the repository filters of the named integration in config.yml
+func SaveRepositories(name string, repos Repositories) error {
+	file, err := ioutil.ReadFile("config.yml")
+	if err != nil {
+		return err
+	}
+	updated, err := SetRepositories(file, name, repos)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile("config.yml", updated, 0644)
+}
@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+)
+
+const file = `integrations:
+  # First integration
+  - name: team-bb-to-gh
+    enabled: true
+    source:
+      type: bitbucket-server
+      repos:
+        # Regexes are wrapped in '/'
+        include:
+          - /.*/
+        exclude:
+          - old-repo
+      # Transport options
+      http:
+        timeout_seconds: 15
+    target:
+      type: github-public
+
+  - name: "personal-bb-to-gh"
+    enabled: false
+    source:
+      type: bitbucket-cloud
+      kind: user/roger
+    target:
+      type: github-public
+`
+
+func TestSetRepositories(t *testing.T) {
+	cases := map[string]struct {
+		Name          string
+		Repos         config.Repositories
+		Expected      string
+		ExpectedError bool
+	}{
+		"Replace filters": {
+			Name:  "team-bb-to-gh",
+			Repos: config.Repositories{Include: []string{"app", "/^lib-.*$/"}},
+			Expected: `    source:
+      type: bitbucket-server
+      repos:
+        # Regexes are wrapped in '/'
+        include:
+          - app
+          - /^lib-.*$/
+        exclude: []
+      # Transport options
+      http:`,
+		},
+		"Add filters": {
+			Name:  "personal-bb-to-gh",
+			Repos: config.Repositories{Include: []string{"yes"}, Exclude: []string{"docs"}},
+			Expected: `      kind: user/roger
+      repos:
+        include:
+          - "yes"
+        exclude:
+          - docs
+    target:`,
+		},
+		"Unknown integration": {
+			Name:          "missing",
+			ExpectedError: true,
+		},
+	}
+
+	for tcName, tc := range cases {
+		actual, err := config.SetRepositories([]byte(file), tc.Name, tc.Repos)
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(string(actual), tc.Expected) {
+			t.Errorf("%v - Expected to contain:\n%v\nActual:\n%v", tcName, tc.Expected, string(actual))
+		}
+		// Comments of the rest of the file are kept
+		if !strings.Contains(string(actual), "# First integration") || !strings.Contains(string(actual), "# Transport options") {
+			t.Errorf("%v - Comments lost:\n%v", tcName, string(actual))
+		}
+	}
+}
@@ -1,9 +1,30 @@
 package config
 
+// Sync directions, with one-way pushing the source to the target only
+const (
+	SyncOneWay = "one-way"
+	SyncTwoWay = "two-way"
+)
+
+// BranchSide names the side whose changes win for the matching source branches in a two-way sync
+// Side is source or target. Branches matching no entry take fast-forwards from both sides
+type BranchSide struct {
+	Match             string `yaml:"match"`
+	AuthoritativeSide string `yaml:"authoritative_side"`
+}
+
 // Sync defines the type and period of the auto sync in config
 type Sync struct {
-	Type   string `yaml:"type"`
-	Period int    `yaml:"period_seconds"`
+	Type              string       `yaml:"type"`
+	Period            int          `yaml:"period_seconds"`
+	Direction         string       `yaml:"direction,omitempty"`
+	Branches          []BranchSide `yaml:"branches,omitempty"`
+	RepositoryTimeout int          `yaml:"repository_timeout_seconds,omitempty"`
+}
+
+// TwoWay checks if changes on the target are synced back to the source
+func (sync Sync) TwoWay() bool {
+	return sync.Direction == SyncTwoWay
 }
 
 // Filters are regexes or strings to include or exclude repositories
@@ -19,14 +40,25 @@ type Repositories struct {
 	Exclude []string `yaml:"exclude,omitempty"`
 }
 
+// HTTP has the transport options for API calls and git operations against a server
+type HTTP struct {
+	Timeout    int    `yaml:"timeout_seconds,omitempty"`
+	CABundle   string `yaml:"ca_bundle,omitempty"`
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+	Proxy      string `yaml:"proxy,omitempty"`
+}
+
 // Source has the fields that describe a source for the sync
 type Source struct {
 	Type         string       `yaml:"type"`
 	BaseURL      string       `yaml:"base_url,omitempty"`
+	ContextPath  string       `yaml:"context_path,omitempty"`
 	AccountID    string       `yaml:"account_id"`
 	AccessToken  string       `yaml:"access_token"`
 	Kind         string       `yaml:"kind"`
 	Repositories Repositories `yaml:"repos"`
+	HTTP         HTTP         `yaml:"http,omitempty"`
 }
 
 // BranchModifier gives options to modify the branch upon sync
@@ -37,6 +69,15 @@ type BranchModifier struct {
 	Rename string `yaml:"rename,omitempty"`
 }
 
+// Orphans handles target repositories whose source repository was deleted or filtered out
+// Action is topic, which adds Topic to them, or archive, which also archives them. Mirrors are told apart from other
+// repositories of the target by ManagedTopic, which is added to every synced repository
+type Orphans struct {
+	Action       string `yaml:"action,omitempty"`
+	Topic        string `yaml:"topic,omitempty"`
+	ManagedTopic string `yaml:"managed_topic,omitempty"`
+}
+
 // Target has teh fields that describe a target for the sync
 type Target struct {
 	Type            string           `yaml:"type"`
@@ -44,16 +85,91 @@ type Target struct {
 	AccountID       string           `yaml:"account_id"`
 	AccessToken     string           `yaml:"access_token"`
 	Kind            string           `yaml:"kind"`
+	Visibility      string           `yaml:"visibility,omitempty"`
+	PushChunkSize   int              `yaml:"push_chunk_size,omitempty"`
 	BranchModifiers []BranchModifier `yaml:"branch_modifiers,omitempty"`
+	Orphans         Orphans          `yaml:"orphans,omitempty"`
+}
+
+// PullRequests selects the pull requests migrated to the target
+// States are open, merged and declined, with only open ones migrated by default
+type PullRequests struct {
+	Enabled bool     `yaml:"enabled"`
+	States  []string `yaml:"states,omitempty"`
+}
+
+// Issues turns on migration of issues, labels and milestones to the target
+type Issues struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// Releases turns on migration of releases and their assets to the target
+// FromTags also makes releases of annotated tags that have none on the source
+type Releases struct {
+	Enabled  bool `yaml:"enabled"`
+	FromTags bool `yaml:"from_tags,omitempty"`
+}
+
+// Migrate has the optional steps that copy more than git data to the target
+type Migrate struct {
+	PullRequests PullRequests `yaml:"pull_requests,omitempty"`
+	Issues       Issues       `yaml:"issues,omitempty"`
+	Releases     Releases     `yaml:"releases,omitempty"`
+}
+
+// UserMapping points to the file that maps source users to target logins
+// With email lookup, users missing from the file are found by their email address
+type UserMapping struct {
+	File        string `yaml:"file,omitempty"`
+	EmailLookup bool   `yaml:"email_lookup,omitempty"`
+}
+
+// Webhook lets pushes on the source trigger a sync of the repository in server mode
+// Secret is the environment variable with the secret that signs the webhook payloads
+type Webhook struct {
+	Secret string `yaml:"secret,omitempty"`
+}
+
+// When notifications are sent
+const (
+	NotifyAlways      = "always"
+	NotifyNewFailures = "new_failures"
+)
+
+// SMTP has the mail server and addresses of an smtp notification
+// Username and Password are the environment variables with the credentials, if the server needs them
+type SMTP struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// Notification sends a digest of every run of the integration
+// Type is webhook, slack, teams or smtp. URL is the environment variable with the URL of the
+// webhook, as incoming webhook URLs hold their secret. When is always or new_failures
+type Notification struct {
+	Type string `yaml:"type"`
+	URL  string `yaml:"url,omitempty"`
+	When string `yaml:"when,omitempty"`
+	SMTP SMTP   `yaml:"smtp,omitempty"`
 }
 
 // Integration defines one integration with all information for sync
 type Integration struct {
-	Name    string `yaml:"name"`
-	Enabled bool   `yaml:"enabled"`
-	Sync    Sync   `yaml:"sync"`
-	Source  Source `yaml:"source"`
-	Target  Target `yaml:"target"`
+	Name        string      `yaml:"name"`
+	Enabled     bool        `yaml:"enabled"`
+	Sync        Sync        `yaml:"sync"`
+	Source      Source      `yaml:"source"`
+	Target      Target      `yaml:"target"`
+	SyncWiki    bool        `yaml:"sync_wiki,omitempty"`
+	Migrate     Migrate     `yaml:"migrate,omitempty"`
+	UserMapping UserMapping `yaml:"user_mapping,omitempty"`
+	Webhook     Webhook     `yaml:"webhook,omitempty"`
+
+	Notifications []Notification `yaml:"notifications,omitempty"`
 }
 
 // Config is the parent that defines the config file format
@@ -0,0 +1,208 @@
+package picker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Actions that end a pick
+const (
+	ActionSave = "save"
+	ActionRun  = "run"
+	ActionSkip = "skip"
+	ActionQuit = "quit"
+)
+
+// help lists the keys of the picker
+const help = "↑/↓ move  space toggle  a all  enter save to config  r sync now  n next  q quit"
+
+// Item is one line of the picker with its checkbox
+type Item struct {
+	Label    string
+	Status   string
+	Selected bool
+}
+
+// Picker is a terminal list whose items are picked with checkboxes
+// It is drawn for terminals in raw mode, so lines end with a carriage return
+type Picker struct {
+	Title  string
+	Items  []Item
+	Height int
+
+	cursor int
+	offset int
+}
+
+// New gives a picker that shows height items at a time
+func New(title string, items []Item, height int) *Picker {
+	if height < 1 {
+		height = 1
+	}
+	return &Picker{Title: title, Items: items, Height: height}
+}
+
+// Selected gives the labels of the selected items
+func (picker *Picker) Selected() []string {
+	var labels []string
+	for _, item := range picker.Items {
+		if item.Selected {
+			labels = append(labels, item.Label)
+		}
+	}
+	return labels
+}
+
+// move puts the cursor at index, scrolling the window of items to keep it visible
+func (picker *Picker) move(index int) {
+	if index >= len(picker.Items) {
+		index = len(picker.Items) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	picker.cursor = index
+
+	if picker.cursor < picker.offset {
+		picker.offset = picker.cursor
+	}
+	if picker.cursor >= picker.offset+picker.Height {
+		picker.offset = picker.cursor - picker.Height + 1
+	}
+}
+
+// Handle applies a key to the picker, returning the action when the key ends the pick
+func (picker *Picker) Handle(key string) string {
+	switch key {
+	case "up", "k":
+		picker.move(picker.cursor - 1)
+	case "down", "j":
+		picker.move(picker.cursor + 1)
+	case "pgup":
+		picker.move(picker.cursor - picker.Height)
+	case "pgdown":
+		picker.move(picker.cursor + picker.Height)
+	case "home", "g":
+		picker.move(0)
+	case "end", "G":
+		picker.move(len(picker.Items) - 1)
+	case " ":
+		if len(picker.Items) > 0 {
+			picker.Items[picker.cursor].Selected = !picker.Items[picker.cursor].Selected
+		}
+	case "a":
+		// Select everything, or nothing when everything is selected
+		all := len(picker.Selected()) == len(picker.Items)
+		for i := range picker.Items {
+			picker.Items[i].Selected = !all
+		}
+	case "enter":
+		return ActionSave
+	case "r":
+		return ActionRun
+	case "n":
+		return ActionSkip
+	case "q", "ctrl-c", "esc":
+		return ActionQuit
+	}
+	return ""
+}
+
+// Render draws the picker over the whole terminal
+func (picker *Picker) Render(out io.Writer) {
+	width := 0
+	for _, item := range picker.Items {
+		if len(item.Label) > width {
+			width = len(item.Label)
+		}
+	}
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&screen, "%v (%v of %v selected)\r\n%v\r\n\r\n", picker.Title, len(picker.Selected()), len(picker.Items), help)
+
+	end := picker.offset + picker.Height
+	if end > len(picker.Items) {
+		end = len(picker.Items)
+	}
+	for i := picker.offset; i < end; i++ {
+		item := picker.Items[i]
+		cursor, check := " ", " "
+		if i == picker.cursor {
+			cursor = ">"
+		}
+		if item.Selected {
+			check = "x"
+		}
+		fmt.Fprintf(&screen, "%v [%v] %-*v  %v\r\n", cursor, check, width, item.Label, item.Status)
+	}
+	if len(picker.Items) == 0 {
+		screen.WriteString("  No repositories\r\n")
+	}
+
+	io.WriteString(out, screen.String())
+}
+
+// readKey reads one key press, naming the keys that are not printable
+func readKey(reader *bufio.Reader) (string, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	switch b {
+	case '\r', '\n':
+		return "enter", nil
+	case 3:
+		return "ctrl-c", nil
+	case 27:
+		// Escape sequences of the arrow and paging keys
+		if reader.Buffered() == 0 {
+			return "esc", nil
+		}
+		next, _ := reader.ReadByte()
+		if next != '[' && next != 'O' {
+			return "esc", nil
+		}
+		code, _ := reader.ReadByte()
+		switch code {
+		case 'A':
+			return "up", nil
+		case 'B':
+			return "down", nil
+		case 'H':
+			return "home", nil
+		case 'F':
+			return "end", nil
+		case '5', '6':
+			reader.ReadByte() // trailing ~
+			if code == '5' {
+				return "pgup", nil
+			}
+			return "pgdown", nil
+		}
+		return "", nil
+	}
+	return string(b), nil
+}
+
+// Run draws the picker and handles keys from in until a key ends the pick
+// The end of the input quits
+func (picker *Picker) Run(in io.Reader, out io.Writer) (string, error) {
+	reader := bufio.NewReader(in)
+	for {
+		picker.Render(out)
+		key, err := readKey(reader)
+		if err == io.EOF {
+			return ActionQuit, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if action := picker.Handle(key); action != "" {
+			return action, nil
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package picker_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	picker "github.com/parinithshekar/gitsink/common/picker"
+)
+
+func items() []picker.Item {
+	return []picker.Item{
+		{Label: "app", Status: "on target", Selected: true},
+		{Label: "docs", Status: "new"},
+		{Label: "infra", Status: "new"},
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		Keys             string
+		ExpectedAction   string
+		ExpectedSelected []string
+	}{
+		"Save as is":      {"\r", picker.ActionSave, []string{"app"}},
+		"Toggle":          {"j \x1b[B \r", picker.ActionSave, []string{"app", "docs", "infra"}},
+		"Untoggle":        {" r", picker.ActionRun, nil},
+		"Select all":      {"an", picker.ActionSkip, []string{"app", "docs", "infra"}},
+		"Select none":     {"aaq", picker.ActionQuit, nil},
+		"Stay in list":    {"\x1b[A \x1b[F\x1b[B \x1b[H\r", picker.ActionSave, []string{"infra"}},
+		"End of input":    {"j ", picker.ActionQuit, []string{"app", "docs"}},
+		"Interrupt":       {"\x03", picker.ActionQuit, []string{"app"}},
+		"Paging":          {"\x1b[6~ \x1b[5~ \r", picker.ActionSave, []string{"infra"}},
+		"Escape sequence": {"\x1bOB \r", picker.ActionSave, []string{"app", "docs"}},
+	}
+
+	for tcName, tc := range cases {
+		p := picker.New("Integration", items(), 2)
+		var out bytes.Buffer
+		action, err := p.Run(strings.NewReader(tc.Keys), &out)
+		if err != nil || action != tc.ExpectedAction {
+			t.Errorf("%v - Expected action: %v | Actual: %v %v", tcName, tc.ExpectedAction, action, err)
+		}
+		if !reflect.DeepEqual(p.Selected(), tc.ExpectedSelected) {
+			t.Errorf("%v - Expected selection: %v | Actual: %v", tcName, tc.ExpectedSelected, p.Selected())
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	p := picker.New("Integration", items(), 2)
+	p.Handle("down")
+	p.Handle("down")
+
+	var out bytes.Buffer
+	p.Render(&out)
+	screen := out.String()
+
+	// Only the window of two items around the cursor is drawn
+	expected := []string{"Integration (1 of 3 selected)", "  [ ] docs   new\r\n", "> [ ] infra  new\r\n"}
+	for _, line := range expected {
+		if !strings.Contains(screen, line) {
+			t.Errorf("Expected line: %q | Actual screen: %q", line, screen)
+		}
+	}
+	if strings.Contains(screen, "app") {
+		t.Errorf("Expected app scrolled out | Actual screen: %q", screen)
+	}
+}
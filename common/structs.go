@@ -1,9 +1,130 @@
 package common
 
+import (
+	"time"
+)
+
 // Repository has the fields required for syncing
 type Repository struct {
 	Slug,
 	Description,
 	Source,
 	Target string
+
+	// Metadata reconciled on the target on every sync
+	// Empty values and nil Topics mean the source does not provide the field
+	Private       bool
+	DefaultBranch string
+	Homepage      string
+	Topics        []string
+
+	// Wiki is the clone URL of the source wiki, empty if the repository has none
+	Wiki string
+}
+
+// Pull request states, shared by all source platforms
+const (
+	PullRequestOpen     = "open"
+	PullRequestMerged   = "merged"
+	PullRequestDeclined = "declined"
+)
+
+// User identifies a person on the source platform
+type User struct {
+	Name        string
+	DisplayName string
+	Email       string
+}
+
+// Comment is a comment on a pull request, anchored to a line of a file if Path is set
+type Comment struct {
+	ID      string
+	Author  User
+	Body    string
+	Created time.Time
+	Path    string
+	Line    int
+}
+
+// PullRequest has the fields required for recreating a pull request on the target
+type PullRequest struct {
+	ID           int64
+	Title        string
+	Description  string
+	State        string
+	URL          string
+	Author       User
+	SourceBranch string
+	TargetBranch string
+	Reviewers    []User
+	Comments     []Comment
+	Created      time.Time
+}
+
+// Issue states, shared by all source platforms
+const (
+	IssueOpen   = "open"
+	IssueClosed = "closed"
+)
+
+// Label is an issue label
+type Label struct {
+	Name        string
+	Color       string
+	Description string
+}
+
+// Milestone groups issues, matched on the target by its title
+type Milestone struct {
+	Title       string
+	Description string
+	State       string
+	Due         *time.Time
+}
+
+// Issue has the fields required for recreating an issue on the target
+// ID is the issue number on the source, unique within the repository
+type Issue struct {
+	ID          int64
+	Title       string
+	Description string
+	State       string
+	URL         string
+	Author      User
+	Labels      []Label
+	Milestone   *Milestone
+	Comments    []Comment
+	Created     time.Time
+}
+
+// Asset is a file attached to a release
+// ID and URL identify it on the source, whichever the source platform needs to download it
+type Asset struct {
+	ID          int64
+	Name        string
+	ContentType string
+	Size        int64
+	URL         string
+}
+
+// Release has the fields required for recreating a release on the target
+// Tag is the name of the tag the release is made from, unique within the repository
+type Release struct {
+	Tag        string
+	Title      string
+	Notes      string
+	Prerelease bool
+	Draft      bool
+	URL        string
+	Author     User
+	Assets     []Asset
+	Created    time.Time
+}
+
+// MigrationSummary counts the items handled by an optional migration step
+type MigrationSummary struct {
+	Migrated int `json:"migrated"`
+	Existing int `json:"existing"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
 }
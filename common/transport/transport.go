@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+)
+
+// DefaultTimeout is used for API requests when the config does not set one
+const DefaultTimeout = 15 * time.Second
+
+// ResponseTimeout is how long a server gets to start answering a request. Unlike the API
+// timeout it does not limit reading the body, which takes long for clones and LFS objects
+const ResponseTimeout = 60 * time.Second
+
+// New builds an HTTP transport with the CA bundle, client certificate and proxy from config
+func New(options config.HTTP) (*http.Transport, error) {
+	tlsConfig := &tls.Config{}
+
+	// Trust the custom CA bundle in addition to the system roots
+	if options.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		bundle, err := ioutil.ReadFile(options.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("CA bundle could not be read: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("CA bundle has no PEM certificates: %v", options.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Present a client certificate when the server asks for one
+	if options.ClientCert != "" || options.ClientKey != "" {
+		certificate, err := tls.LoadX509KeyPair(options.ClientCert, options.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Client certificate could not be loaded: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	// Use the configured proxy, else fall back to HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	proxy := http.ProxyFromEnvironment
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Proxy URL could not be parsed: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = proxy
+	transport.ResponseHeaderTimeout = ResponseTimeout
+	return transport, nil
+}
+
+// Timeout returns the API request timeout from config
+func Timeout(options config.HTTP) time.Duration {
+	if options.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(options.Timeout) * time.Second
+}
+
+// Router sends each request through the transport registered for its host
+// Hosts can be registered while requests are being sent
+type Router struct {
+	Default http.RoundTripper
+
+	mutex sync.RWMutex
+	hosts map[string]http.RoundTripper
+}
+
+// Register sends the requests to host through transport, replacing the one registered before
+func (router *Router) Register(host string, transport http.RoundTripper) {
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	if router.hosts == nil {
+		router.hosts = map[string]http.RoundTripper{}
+	}
+	router.hosts[host] = transport
+}
+
+// RoundTrip implements http.RoundTripper
+func (router *Router) RoundTrip(request *http.Request) (*http.Response, error) {
+	router.mutex.RLock()
+	transport, exists := router.hosts[request.URL.Host]
+	router.mutex.RUnlock()
+	if exists {
+		return transport.RoundTrip(request)
+	}
+	if router.Default != nil {
+		return router.Default.RoundTrip(request)
+	}
+	return http.DefaultTransport.RoundTrip(request)
+}
@@ -0,0 +1,212 @@
+package users
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// EmailSource is implemented by input plugins that can look up the email address of a source user
+type EmailSource interface {
+	UserEmail(context.Context, string) (string, error)
+}
+
+// LoginFinder is implemented by output plugins that can find the target login of an email address
+type LoginFinder interface {
+	LoginForEmail(context.Context, string) (string, error)
+}
+
+// mappingFile is the YAML format of a mapping file
+// Keys are source usernames or email addresses, values are target logins
+type mappingFile struct {
+	Users map[string]string `yaml:"users"`
+}
+
+// Mapper maps source users to target logins from a mapping file, falling back to
+// looking up their email address on both platforms if lookups are enabled
+type Mapper struct {
+	byName  map[string]string
+	byEmail map[string]string
+	source  EmailSource
+	target  LoginFinder
+
+	mutex sync.Mutex
+	cache map[string]string
+}
+
+// New returns a mapper with the mappings of the file, which can be YAML or CSV
+// An empty path gives a mapper with no mappings
+func New(path string) (*Mapper, error) {
+	mapper := &Mapper{
+		byName:  map[string]string{},
+		byEmail: map[string]string{},
+		cache:   map[string]string{},
+	}
+	if path == "" {
+		return mapper, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("User mapping file could not be read: %w", err)
+	}
+	defer file.Close()
+
+	var mappings map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		mappings, err = parseCSV(file)
+	case ".yml", ".yaml":
+		mappings, err = parseYAML(file)
+	default:
+		return nil, fmt.Errorf("Unsupported user mapping file format %v", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("User mapping file could not be parsed: %w", err)
+	}
+
+	for sourceUser, login := range mappings {
+		mapper.Add(sourceUser, login)
+	}
+	return mapper, nil
+}
+
+// parseYAML reads a users map of source username or email to target login
+func parseYAML(reader io.Reader) (map[string]string, error) {
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	file := mappingFile{}
+	err = yaml.UnmarshalStrict(contents, &file)
+	return file.Users, err
+}
+
+// parseCSV reads rows of source username or email and target login, with an optional header
+func parseCSV(reader io.Reader) (map[string]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = 2
+	csvReader.TrimLeadingSpace = true
+
+	rows, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := map[string]string{}
+	for i, row := range rows {
+		if i == 0 && strings.EqualFold(row[0], "source") && strings.EqualFold(row[1], "target") {
+			continue
+		}
+		mappings[row[0]] = row[1]
+	}
+	return mappings, nil
+}
+
+// Add maps a source username, or email address if it has an @, to a target login
+func (mapper *Mapper) Add(sourceUser, login string) {
+	key := strings.ToLower(strings.TrimSpace(sourceUser))
+	login = strings.TrimPrefix(strings.TrimSpace(login), "@")
+	if key == "" || login == "" {
+		return
+	}
+	if strings.Contains(key, "@") {
+		mapper.byEmail[key] = login
+	} else {
+		mapper.byName[key] = login
+	}
+}
+
+// EnableLookup makes the mapper look up users missing from the file by their email address
+// The source is asked for the email address of users that come without one
+func (mapper *Mapper) EnableLookup(source EmailSource, target LoginFinder) {
+	mapper.source = source
+	mapper.target = target
+}
+
+// TargetUser gives the target login of the source user, returning false if they are not mapped
+// Lookups by email address stop when ctx is done
+func (mapper *Mapper) TargetUser(ctx context.Context, user common.User) (string, bool) {
+	if mapper == nil {
+		return "", false
+	}
+
+	name := strings.ToLower(user.Name)
+	email := strings.ToLower(user.Email)
+
+	if login, ok := mapper.byName[name]; ok && name != "" {
+		return login, true
+	}
+	if login, ok := mapper.byEmail[email]; ok && email != "" {
+		return login, true
+	}
+	if mapper.target == nil {
+		return "", false
+	}
+
+	// Lookups are cached, including misses, since they cost API calls
+	cacheKey := name + "|" + email
+	mapper.mutex.Lock()
+	defer mapper.mutex.Unlock()
+	if login, ok := mapper.cache[cacheKey]; ok {
+		return login, login != ""
+	}
+
+	login := mapper.lookup(ctx, user.Name, email)
+	// A lookup cut short by ctx is not a miss
+	if ctx.Err() == nil {
+		mapper.cache[cacheKey] = login
+	}
+	return login, login != ""
+}
+
+// lookup finds the target login through the email address of the user
+func (mapper *Mapper) lookup(ctx context.Context, name, email string) string {
+	if email == "" && mapper.source != nil && name != "" {
+		sourceEmail, err := mapper.source.UserEmail(ctx, name)
+		if err != nil {
+			return ""
+		}
+		email = strings.ToLower(sourceEmail)
+	}
+	if email == "" {
+		return ""
+	}
+	if login, ok := mapper.byEmail[email]; ok {
+		return login
+	}
+
+	login, err := mapper.target.LoginForEmail(ctx, email)
+	if err != nil {
+		return ""
+	}
+	return login
+}
+
+// Unmapped lists the distinct users that have no target login
+func (mapper *Mapper) Unmapped(ctx context.Context, users []common.User) []common.User {
+	unmapped := []common.User{}
+	seen := map[string]bool{}
+	for _, user := range users {
+		key := strings.ToLower(user.Name + "|" + user.Email)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := mapper.TargetUser(ctx, user); !ok {
+			unmapped = append(unmapped, user)
+		}
+	}
+	return unmapped
+}
@@ -0,0 +1,125 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	common "github.com/parinithshekar/gitsink/common"
+	users "github.com/parinithshekar/gitsink/common/users"
+)
+
+// directory fakes both platforms' user APIs, counting the lookups made
+type directory struct {
+	emails  map[string]string
+	logins  map[string]string
+	lookups int
+}
+
+func (d *directory) UserEmail(ctx context.Context, name string) (string, error) {
+	if email, ok := d.emails[name]; ok {
+		return email, nil
+	}
+	return "", errors.New("User not found")
+}
+
+func (d *directory) LoginForEmail(ctx context.Context, email string) (string, error) {
+	d.lookups++
+	if login, ok := d.logins[email]; ok {
+		return login, nil
+	}
+	return "", errors.New("No user with the email address")
+}
+
+func writeFile(t *testing.T, name, contents string) string {
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	ioutil.WriteFile(path, []byte(contents), 0600)
+	return path
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		Name, Contents string
+		ExpectedError  bool
+	}{
+		"YAML file":        {"users.yml", "users:\n  alice: alice-gh\n  Bob@Company.com: \"@bobby\"\n", false},
+		"CSV file":         {"users.csv", "source,target\nalice,alice-gh\n# comment\nbob@company.com,bobby\n", false},
+		"CSV wrong fields": {"users.csv", "alice,alice-gh,extra\n", true},
+		"YAML bad field":   {"users.yaml", "people:\n  alice: alice-gh\n", true},
+		"Unknown format":   {"users.txt", "alice alice-gh\n", true},
+	}
+
+	for tcName, tc := range cases {
+		path := writeFile(t, tc.Name, tc.Contents)
+		defer os.RemoveAll(filepath.Dir(path))
+
+		mapper, err := users.New(path)
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		alice, aliceOK := mapper.TargetUser(context.Background(), common.User{Name: "Alice"})
+		bob, bobOK := mapper.TargetUser(context.Background(), common.User{Name: "bob", Email: "bob@company.com"})
+		if !aliceOK || alice != "alice-gh" || !bobOK || bob != "bobby" {
+			t.Errorf("%v - Unexpected mappings: %v %v, %v %v", tcName, alice, aliceOK, bob, bobOK)
+		}
+	}
+
+	if _, err := users.New("/does/not/exist.yml"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestEmailLookup(t *testing.T) {
+	lookup := &directory{
+		emails: map[string]string{"carol": "carol@company.com"},
+		logins: map[string]string{"carol@company.com": "carol-gh", "dave@company.com": "dave-gh"},
+	}
+
+	cases := map[string]struct {
+		User          common.User
+		ExpectedLogin string
+		ExpectedOK    bool
+	}{
+		"Mapped in file":   {common.User{Name: "alice"}, "alice-gh", true},
+		"Email from user":  {common.User{Name: "dave", Email: "dave@company.com"}, "dave-gh", true},
+		"Email via source": {common.User{Name: "carol"}, "carol-gh", true},
+		"Unknown email":    {common.User{Name: "erin", Email: "erin@company.com"}, "", false},
+		"No email":         {common.User{Name: "frank"}, "", false},
+	}
+
+	mapper, _ := users.New("")
+	mapper.Add("alice", "alice-gh")
+	mapper.EnableLookup(lookup, lookup)
+
+	for tcName, tc := range cases {
+		login, ok := mapper.TargetUser(context.Background(), tc.User)
+		if login != tc.ExpectedLogin || ok != tc.ExpectedOK {
+			t.Errorf("%v - Expected: %v %v | Actual: %v %v", tcName, tc.ExpectedLogin, tc.ExpectedOK, login, ok)
+		}
+	}
+
+	// Results, including misses, are cached
+	lookups := lookup.lookups
+	mapper.TargetUser(context.Background(), common.User{Name: "carol"})
+	mapper.TargetUser(context.Background(), common.User{Name: "erin", Email: "erin@company.com"})
+	if lookup.lookups != lookups {
+		t.Errorf("Expected cached lookups | Actual: %v more lookups", lookup.lookups-lookups)
+	}
+
+	unmapped := mapper.Unmapped(context.Background(), []common.User{{Name: "alice"}, {Name: "frank"}, {Name: "frank"}, {Name: "erin", Email: "erin@company.com"}})
+	if len(unmapped) != 2 || unmapped[0].Name != "frank" || unmapped[1].Name != "erin" {
+		t.Errorf("Unexpected unmapped users: %v", unmapped)
+	}
+}
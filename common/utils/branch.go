@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+)
+
+// ModifyBranch gives the target name of a source branch according to the branch modifiers
+// With no modifiers every branch keeps its name. Otherwise only branches matching a
+// modifier are synced, and the first matching modifier renames or prefixes the branch
+func ModifyBranch(branch string, modifiers []config.BranchModifier) (string, bool) {
+	if len(modifiers) == 0 {
+		return branch, true
+	}
+
+	for _, modifier := range modifiers {
+		if !matchBranch(branch, modifier.Match) {
+			continue
+		}
+		if modifier.Rename != "" {
+			return modifier.Rename, true
+		}
+		return modifier.Prefix + branch, true
+	}
+	return "", false
+}
+
+// AuthoritativeSide gives the side whose changes win for the source branch in a two-way sync
+// Returns an empty string if no entry matches, so both sides are equal
+func AuthoritativeSide(branch string, sides []config.BranchSide) string {
+	for _, side := range sides {
+		if matchBranch(branch, side.Match) {
+			return side.AuthoritativeSide
+		}
+	}
+	return ""
+}
+
+// matchBranch checks the branch against a plain name or a regex implied by leading and trailing '/'
+func matchBranch(branch, pattern string) bool {
+	isRE, _ := regexp.MatchString("^/.*/$", pattern)
+	if isRE {
+		barePattern := strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+		match, _ := regexp.MatchString(barePattern, branch)
+		return match
+	}
+	return branch == pattern
+}
@@ -0,0 +1,147 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
+)
+
+// apiError is the body of failed API requests
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes the value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes the error of a request, with the status that matches daemon errors
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case ErrNotFound:
+		status = http.StatusNotFound
+	case ErrDisabled, ErrPaused, ErrAlreadyQueued:
+		status = http.StatusConflict
+	case pkg.ErrUnauthorizedAPI:
+		status = http.StatusUnauthorized
+	}
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// authorized checks the bearer token of the request in constant time
+func authorized(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// Handler serves the API of the daemon to requests with the bearer token, and the webhooks of
+// the integrations at /hooks/<name>, which are signed with their webhook secret instead
+//
+//	GET  /api/v1/integrations                                   status of every integration
+//	GET  /api/v1/integrations/<name>                            status of one integration
+//	GET  /api/v1/integrations/<name>/runs/last                  last run and its report
+//	POST /api/v1/integrations/<name>/sync                       queue a sync of the integration
+//	POST /api/v1/integrations/<name>/repositories/<slug>/sync   queue a sync of one repository
+//	POST /api/v1/integrations/<name>/pause                      stop syncing the integration
+//	POST /api/v1/integrations/<name>/resume                     start syncing it again
+//	GET  /metrics                                               Prometheus metrics of the syncs
+func (daemon *Daemon) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/integrations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, daemon.Integrations())
+	})
+	mux.HandleFunc("/api/v1/integrations/", daemon.serveIntegration)
+	mux.Handle("/metrics", metrics.Handler())
+
+	root := http.NewServeMux()
+	root.HandleFunc("/hooks/", daemon.serveWebhook)
+	root.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || !authorized(r, token) {
+			writeError(w, pkg.ErrUnauthorizedAPI)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+	return root
+}
+
+// serveIntegration routes the requests about one integration
+func (daemon *Daemon) serveIntegration(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/integrations/"), "/")
+	pathSplit := strings.Split(path, "/")
+	name := pathSplit[0]
+
+	route := func(method string, parts ...string) bool {
+		if r.Method != method || len(pathSplit) != len(parts)+1 {
+			return false
+		}
+		for i, part := range parts {
+			if part != "*" && pathSplit[i+1] != part {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case route(http.MethodGet):
+		status, err := daemon.Integration(name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+
+	case route(http.MethodGet, "runs", "last"):
+		status, err := daemon.Integration(name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if status.LastRun == nil {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "Integration has not run yet"})
+			return
+		}
+		writeJSON(w, http.StatusOK, status.LastRun)
+
+	case route(http.MethodPost, "sync"), route(http.MethodPost, "repositories", "*", "sync"):
+		slug := ""
+		if len(pathSplit) == 4 {
+			slug = pathSplit[2]
+		}
+		err := daemon.Trigger(name, slug)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		status, _ := daemon.Integration(name)
+		writeJSON(w, http.StatusAccepted, status)
+
+	case route(http.MethodPost, "pause"), route(http.MethodPost, "resume"):
+		status, err := daemon.SetPaused(name, pathSplit[1] == "pause")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+
+	default:
+		writeJSON(w, http.StatusNotFound, apiError{Error: "Resource not found"})
+	}
+}
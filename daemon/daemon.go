@@ -0,0 +1,361 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+)
+
+var (
+	log = logger.Root()
+)
+
+// Sync types of an integration in config
+const (
+	// SyncLoop runs the integration every period_seconds
+	SyncLoop = "loop"
+	// SyncOnce runs the integration when the daemon starts
+	SyncOnce = "once"
+)
+
+// What started a run
+const (
+	TriggerSchedule = "schedule"
+	TriggerAPI      = "api"
+	TriggerWebhook  = "webhook"
+)
+
+// DefaultDebounce is how long a repository is left alone after a push before it is synced
+const DefaultDebounce = 10 * time.Second
+
+// SyncFunc syncs the repositories of an integration, or only the repository with the slug
+// when one is given. It starts no more repositories once ctx is canceled
+type SyncFunc func(ctx context.Context, integration config.Integration, slug string) (git.Report, error)
+
+// Run is one sync of an integration
+type Run struct {
+	Repository string      `json:"repository,omitempty"`
+	Trigger    string      `json:"trigger"`
+	Started    time.Time   `json:"started"`
+	Finished   time.Time   `json:"finished"`
+	Report     *git.Report `json:"report,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Status is the state of an integration in the daemon
+type Status struct {
+	Name     string     `json:"name"`
+	Enabled  bool       `json:"enabled"`
+	Paused   bool       `json:"paused"`
+	Running  bool       `json:"running"`
+	Queued   []string   `json:"queued,omitempty"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+	LastRun  *Run       `json:"lastRun,omitempty"`
+	Schedule string     `json:"schedule"`
+}
+
+// job is a queued run of an integration
+type job struct {
+	integration string
+	slug        string
+	trigger     string
+}
+
+// covers checks if the job syncs the repository of the integration, all of them when slug is empty
+func (queued job) covers(name, slug string) bool {
+	return queued.integration == name && (queued.slug == "" || queued.slug == slug)
+}
+
+// state is what the daemon keeps about an integration
+type state struct {
+	integration config.Integration
+	paused      bool
+	running     *job
+	nextRun     time.Time
+	lastRun     *Run
+	secret      string
+}
+
+// Daemon runs the integrations on their schedule and on request, one run at a time
+// Runs are never concurrent because syncs work in the directory of the process
+// Pushes to a repository within Debounce of each other are synced once
+type Daemon struct {
+	Debounce time.Duration
+
+	sync  SyncFunc
+	names []string
+
+	mutex   sync.Mutex
+	states  map[string]*state
+	queue   []job
+	wake    chan struct{}
+	pending map[string]*time.Timer
+}
+
+// Errors of requests to the daemon
+var (
+	ErrNotFound      = fmt.Errorf("Integration not found")
+	ErrDisabled      = fmt.Errorf("Integration is disabled in config")
+	ErrPaused        = fmt.Errorf("Integration is paused")
+	ErrAlreadyQueued = fmt.Errorf("Sync is already queued or running")
+)
+
+// New gives a daemon for the integrations of the config, using sync to run them
+// Enabled integrations with a loop or once sync are scheduled from the start
+func New(integrations []config.Integration, sync SyncFunc) *Daemon {
+	daemon := &Daemon{
+		Debounce: DefaultDebounce,
+		sync:     sync,
+		states:   map[string]*state{},
+		wake:     make(chan struct{}, 1),
+		pending:  map[string]*time.Timer{},
+	}
+
+	now := time.Now()
+	for _, integration := range integrations {
+		integrationState := &state{integration: integration}
+		if integration.Webhook.Secret != "" {
+			integrationState.secret = os.Getenv(integration.Webhook.Secret)
+		}
+		if integration.Enabled && (integration.Sync.Type == SyncLoop || integration.Sync.Type == SyncOnce) {
+			integrationState.nextRun = now
+		}
+		daemon.names = append(daemon.names, integration.Name)
+		daemon.states[integration.Name] = integrationState
+	}
+	return daemon
+}
+
+// status describes an integration, the lock must be held
+func (daemon *Daemon) status(name string) Status {
+	integrationState := daemon.states[name]
+	status := Status{
+		Name:     name,
+		Enabled:  integrationState.integration.Enabled,
+		Paused:   integrationState.paused,
+		Running:  integrationState.running != nil,
+		LastRun:  integrationState.lastRun,
+		Schedule: integrationState.integration.Sync.Type,
+	}
+	if !integrationState.nextRun.IsZero() {
+		nextRun := integrationState.nextRun
+		status.NextRun = &nextRun
+	}
+	for _, queued := range daemon.queue {
+		if queued.integration == name {
+			label := queued.slug
+			if label == "" {
+				label = "*"
+			}
+			status.Queued = append(status.Queued, label)
+		}
+	}
+	return status
+}
+
+// Integrations gives the status of every integration in config order
+func (daemon *Daemon) Integrations() []Status {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	statuses := []Status{}
+	for _, name := range daemon.names {
+		statuses = append(statuses, daemon.status(name))
+	}
+	return statuses
+}
+
+// Integration gives the status of the named integration
+func (daemon *Daemon) Integration(name string) (Status, error) {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	if _, exists := daemon.states[name]; !exists {
+		return Status{}, ErrNotFound
+	}
+	return daemon.status(name), nil
+}
+
+// Trigger queues a sync of the integration, or of one of its repositories when slug is given
+// Syncs already queued or running for the repository are not queued again
+func (daemon *Daemon) Trigger(name, slug string) error {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+	return daemon.enqueue(name, slug, TriggerAPI)
+}
+
+// enqueue queues a sync unless the integration cannot run, the lock must be held
+func (daemon *Daemon) enqueue(name, slug, trigger string) error {
+	integrationState, exists := daemon.states[name]
+	switch {
+	case !exists:
+		return ErrNotFound
+	case !integrationState.integration.Enabled:
+		return ErrDisabled
+	case integrationState.paused:
+		return ErrPaused
+	}
+	for _, queued := range daemon.queue {
+		if queued.covers(name, slug) {
+			return ErrAlreadyQueued
+		}
+	}
+	// Pushes still queue a sync, the running one may have fetched the repository before them
+	running := integrationState.running
+	if trigger != TriggerWebhook && running != nil && running.covers(name, slug) {
+		return ErrAlreadyQueued
+	}
+
+	daemon.queue = append(daemon.queue, job{integration: name, slug: slug, trigger: trigger})
+	daemon.signal()
+	return nil
+}
+
+// SetPaused pauses or resumes the scheduled and requested syncs of the integration
+// Queued syncs and pushes of a paused integration are dropped, a running sync is finished
+func (daemon *Daemon) SetPaused(name string, paused bool) (Status, error) {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	integrationState, exists := daemon.states[name]
+	if !exists {
+		return Status{}, ErrNotFound
+	}
+	integrationState.paused = paused
+
+	if paused {
+		var kept []job
+		for _, queued := range daemon.queue {
+			if queued.integration != name {
+				kept = append(kept, queued)
+			}
+		}
+		daemon.queue = kept
+
+		for key, timer := range daemon.pending {
+			if strings.HasPrefix(key, name+"/") {
+				timer.Stop()
+				delete(daemon.pending, key)
+			}
+		}
+	} else if !integrationState.nextRun.IsZero() && integrationState.nextRun.Before(time.Now()) {
+		// Runs missed while paused are not made up, the schedule restarts now
+		integrationState.nextRun = time.Now()
+	}
+	daemon.signal()
+
+	log.WithFields(logrus.Fields{
+		"integration": name,
+		"paused":      paused,
+	}).Infof("Integration schedule changed")
+	return daemon.status(name), nil
+}
+
+// signal wakes the scheduler, the lock must be held
+func (daemon *Daemon) signal() {
+	select {
+	case daemon.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the job to run now, or gives how long to wait for the next scheduled one
+// A zero wait with no job means nothing is scheduled
+func (daemon *Daemon) next() (*job, time.Duration) {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	if len(daemon.queue) > 0 {
+		queued := daemon.queue[0]
+		daemon.queue = daemon.queue[1:]
+		return &queued, 0
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, name := range daemon.names {
+		integrationState := daemon.states[name]
+		if integrationState.paused || integrationState.nextRun.IsZero() {
+			continue
+		}
+		if !integrationState.nextRun.After(now) {
+			return &job{integration: name, trigger: TriggerSchedule}, 0
+		}
+		if until := integrationState.nextRun.Sub(now); wait == 0 || until < wait {
+			wait = until
+		}
+	}
+	return nil, wait
+}
+
+// run syncs the job and records it as the last run of its integration
+func (daemon *Daemon) run(ctx context.Context, queued job) {
+	daemon.mutex.Lock()
+	integrationState := daemon.states[queued.integration]
+	integrationState.running = &queued
+	integration := integrationState.integration
+	if queued.trigger == TriggerSchedule {
+		// The next run is counted from the start of this one, loops without a period run once
+		integrationState.nextRun = time.Time{}
+		if integration.Sync.Type == SyncLoop && integration.Sync.Period > 0 {
+			integrationState.nextRun = time.Now().Add(time.Duration(integration.Sync.Period) * time.Second)
+		}
+	}
+	daemon.mutex.Unlock()
+
+	run := &Run{Repository: queued.slug, Trigger: queued.trigger, Started: time.Now()}
+	report, err := daemon.sync(ctx, integration, queued.slug)
+	run.Finished = time.Now()
+	run.Report = &report
+	if err != nil {
+		run.Error = err.Error()
+		run.Report = nil
+		log.WithFields(logrus.Fields{
+			"integration": queued.integration,
+			"repository":  queued.slug,
+			"error":       err.Error(),
+		}).Errorf("Sync failed")
+	}
+
+	daemon.mutex.Lock()
+	integrationState.running = nil
+	integrationState.lastRun = run
+	daemon.mutex.Unlock()
+}
+
+// Run works through the queued and scheduled syncs until ctx is canceled
+// A sync that is running then is passed the cancellation, and returns once its started
+// repositories are done
+func (daemon *Daemon) Run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		queued, wait := daemon.next()
+		if queued != nil {
+			daemon.run(ctx, *queued)
+			continue
+		}
+
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-daemon.wake:
+		case <-timer:
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package daemon_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	daemon "github.com/parinithshekar/gitsink/daemon"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+)
+
+var integrations = []config.Integration{
+	{Name: "team", Enabled: true, Sync: config.Sync{Type: "loop", Period: 3600}},
+	{Name: "manual", Enabled: true, Sync: config.Sync{Type: "manual"}},
+	{Name: "disabled", Enabled: false, Sync: config.Sync{Type: "loop", Period: 60}},
+}
+
+// recorder is a sync that reports every call on a channel
+type recorder struct {
+	calls chan string
+}
+
+func (recorder recorder) sync(ctx context.Context, integration config.Integration, slug string) (git.Report, error) {
+	recorder.calls <- integration.Name + "/" + slug
+	return git.Report{Integration: integration.Name}, nil
+}
+
+// waitCalls waits for the syncs the daemon runs
+func waitCalls(t *testing.T, calls chan string, count int) []string {
+	var actual []string
+	for i := 0; i < count; i++ {
+		select {
+		case call := <-calls:
+			actual = append(actual, call)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %v syncs | Actual: %v", count, actual)
+		}
+	}
+	return actual
+}
+
+func TestSchedule(t *testing.T) {
+	calls := make(chan string, 10)
+	d := daemon.New(integrations, recorder{calls}.sync)
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	// Only the enabled loop integration is scheduled, and not again within its period
+	actual := waitCalls(t, calls, 1)
+	if !reflect.DeepEqual(actual, []string{"team/"}) {
+		t.Errorf("Expected scheduled syncs: [team/] | Actual: %v", actual)
+	}
+	select {
+	case call := <-calls:
+		t.Errorf("Unexpected sync: %v", call)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stop()
+	<-done
+
+	status, _ := d.Integration("team")
+	if status.LastRun == nil || status.LastRun.Trigger != daemon.TriggerSchedule || status.NextRun == nil || status.NextRun.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	calls := make(chan string, 10)
+	// Nothing is scheduled, so only requested syncs run
+	d := daemon.New(integrations[1:], recorder{calls}.sync)
+	server := httptest.NewServer(d.Handler("secret"))
+	defer server.Close()
+
+	steps := []struct {
+		Method, Path, Token string
+		ExpectedStatus      int
+	}{
+		{"GET", "/api/v1/integrations", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/integrations", "wrong", http.StatusUnauthorized},
+		{"GET", "/api/v1/integrations", "secret", http.StatusOK},
+		{"GET", "/api/v1/integrations/manual", "secret", http.StatusOK},
+		{"GET", "/api/v1/integrations/missing", "secret", http.StatusNotFound},
+		{"GET", "/api/v1/integrations/manual/runs/last", "secret", http.StatusNotFound},
+		{"POST", "/api/v1/integrations/disabled/sync", "secret", http.StatusConflict},
+		{"POST", "/api/v1/integrations/manual/pause", "secret", http.StatusOK},
+		{"POST", "/api/v1/integrations/manual/sync", "secret", http.StatusConflict},
+		{"POST", "/api/v1/integrations/manual/resume", "secret", http.StatusOK},
+		{"POST", "/api/v1/integrations/manual/repositories/app/sync", "secret", http.StatusAccepted},
+		{"POST", "/api/v1/integrations/manual/repositories/app/sync", "secret", http.StatusConflict},
+		{"POST", "/api/v1/integrations/manual/sync", "secret", http.StatusAccepted},
+		{"POST", "/api/v1/integrations/manual/sync", "secret", http.StatusConflict},
+		{"DELETE", "/api/v1/integrations/manual", "secret", http.StatusNotFound},
+		{"GET", "/metrics", "", http.StatusUnauthorized},
+		{"GET", "/metrics", "secret", http.StatusOK},
+	}
+
+	for _, step := range steps {
+		req, _ := http.NewRequest(step.Method, server.URL+step.Path, nil)
+		if step.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+step.Token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != step.ExpectedStatus {
+			t.Errorf("%v %v - Expected status: %v | Actual: %v", step.Method, step.Path, step.ExpectedStatus, resp.StatusCode)
+		}
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go d.Run(ctx)
+
+	actual := waitCalls(t, calls, 2)
+	if !reflect.DeepEqual(actual, []string{"manual/app", "manual/"}) {
+		t.Errorf("Expected syncs in order | Actual: %v", actual)
+	}
+
+	// The last run is recorded once the sync returns
+	var run daemon.Run
+	for i := 0; i < 50; i++ {
+		req, _ := http.NewRequest("GET", server.URL+"/api/v1/integrations/manual/runs/last", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(resp.Body).Decode(&run)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && run.Repository == "" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if run.Trigger != daemon.TriggerAPI || run.Report == nil || run.Report.Integration != "manual" {
+		t.Errorf("Unexpected last run: %+v", run)
+	}
+}
+
+func TestTriggerRunning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	d := daemon.New(integrations[1:2], func(ctx context.Context, integration config.Integration, slug string) (git.Report, error) {
+		close(started)
+		<-release
+		return git.Report{Integration: integration.Name}, nil
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := d.Trigger("manual", ""); err != nil {
+		t.Fatal(err)
+	}
+	go d.Run(ctx)
+	<-started
+	defer close(release)
+
+	// The running sync covers the integration and each of its repositories
+	for _, slug := range []string{"", "app"} {
+		if err := d.Trigger("manual", slug); err != daemon.ErrAlreadyQueued {
+			t.Errorf("%q - Expected error: %v | Actual: %v", slug, daemon.ErrAlreadyQueued, err)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	// The sync stands in for a run that stops starting repositories once it is canceled
+	d := daemon.New(integrations[:1], func(ctx context.Context, integration config.Integration, slug string) (git.Report, error) {
+		close(started)
+		<-ctx.Done()
+		return git.Report{Integration: integration.Name, Canceled: true, Skipped: []string{"app"}}, nil
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the daemon to stop with its running sync")
+	}
+
+	// The canceled run is still recorded with its partial report
+	status, _ := d.Integration("team")
+	if status.Running || status.LastRun == nil || status.LastRun.Report == nil || !status.LastRun.Report.Canceled {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
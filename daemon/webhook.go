@@ -0,0 +1,168 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	gjson "github.com/tidwall/gjson"
+)
+
+// maxPayload is the largest webhook payload read, push payloads are far smaller
+const maxPayload = 5 << 20
+
+// Push is a push to a source repository, read from a webhook payload
+type Push struct {
+	Source     string
+	Repository string
+}
+
+// webhookResponse is the body of answered webhooks
+type webhookResponse struct {
+	Integration string `json:"integration"`
+	Repository  string `json:"repository,omitempty"`
+	Ignored     bool   `json:"ignored,omitempty"`
+}
+
+// validSignature checks the sha256=<hex> HMAC signature of the payload with the secret
+func validSignature(payload []byte, signature, secret string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+// ParsePush reads the push of a GitHub, Bitbucket Server or Bitbucket Cloud webhook
+// Other events give no push, and a nil push with no error
+func ParsePush(header http.Header, payload []byte) (*Push, error) {
+	if !gjson.ValidBytes(payload) {
+		return nil, fmt.Errorf("Webhook payload is not JSON")
+	}
+	body := gjson.ParseBytes(payload)
+
+	var push *Push
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		if header.Get("X-GitHub-Event") == "push" {
+			push = &Push{Source: "github-public", Repository: body.Get("repository.name").String()}
+		}
+
+	case header.Get("X-Event-Key") == "repo:refs_changed":
+		push = &Push{Source: "bitbucket-server", Repository: body.Get("repository.slug").String()}
+
+	case header.Get("X-Event-Key") == "repo:push":
+		// The name of a Bitbucket Cloud repository can differ from its slug
+		fullName := strings.SplitN(body.Get("repository.full_name").String(), "/", 2)
+		push = &Push{Source: "bitbucket-cloud", Repository: fullName[len(fullName)-1]}
+
+	case header.Get("X-Event-Key") == "":
+		return nil, fmt.Errorf("Webhook is not from GitHub or Bitbucket")
+	}
+
+	if push != nil && push.Repository == "" {
+		return nil, fmt.Errorf("Webhook payload has no repository")
+	}
+	return push, nil
+}
+
+// debounce syncs the repository once no push has come for the debounce time, the lock must be held
+func (daemon *Daemon) debounce(name, slug string) {
+	key := name + "/" + slug
+	if timer, exists := daemon.pending[key]; exists {
+		timer.Stop()
+	}
+	daemon.pending[key] = time.AfterFunc(daemon.Debounce, func() {
+		daemon.mutex.Lock()
+		defer daemon.mutex.Unlock()
+		delete(daemon.pending, key)
+
+		err := daemon.enqueue(name, slug, TriggerWebhook)
+		if err != nil && err != ErrAlreadyQueued {
+			log.WithFields(logrus.Fields{
+				"integration": name,
+				"repository":  slug,
+				"error":       err.Error(),
+			}).Warningf("Pushed repository not synced")
+		}
+	})
+}
+
+// serveWebhook takes the webhooks of an integration at /hooks/<name>
+// Payloads must be signed with the webhook secret of the integration
+func (daemon *Daemon) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/hooks/"), "/")
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	daemon.mutex.Lock()
+	integrationState, exists := daemon.states[name]
+	secret := ""
+	if exists {
+		secret = integrationState.secret
+	}
+	daemon.mutex.Unlock()
+	if !exists {
+		writeError(w, ErrNotFound)
+		return
+	}
+	if secret == "" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "Integration has no webhook secret"})
+		return
+	}
+
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = r.Header.Get("X-Hub-Signature")
+	}
+	if !validSignature(payload, signature, secret) {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "Webhook signature does not match"})
+		return
+	}
+
+	push, err := ParsePush(r.Header, payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	// Pings and other events are answered so the webhook shows as working
+	if push == nil {
+		writeJSON(w, http.StatusOK, webhookResponse{Integration: name, Ignored: true})
+		return
+	}
+
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+	switch {
+	case push.Source != integrationState.integration.Source.Type:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("Webhook from %v does not match the source of the integration", push.Source)})
+		return
+	case !integrationState.integration.Enabled:
+		writeError(w, ErrDisabled)
+		return
+	case integrationState.paused:
+		writeError(w, ErrPaused)
+		return
+	}
+
+	daemon.debounce(name, push.Repository)
+	writeJSON(w, http.StatusAccepted, webhookResponse{Integration: name, Repository: push.Repository})
+}
@@ -0,0 +1,124 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	daemon "github.com/parinithshekar/gitsink/daemon"
+)
+
+const (
+	githubPush          = `{"ref":"refs/heads/master","repository":{"name":"app","full_name":"org/app"}}`
+	bitbucketServerPush = `{"eventKey":"repo:refs_changed","repository":{"slug":"app","name":"App"}}`
+	bitbucketCloudPush  = `{"repository":{"name":"My App","full_name":"team/my-app"}}`
+)
+
+func sign(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParsePush(t *testing.T) {
+	cases := map[string]struct {
+		Header        http.Header
+		Payload       string
+		Expected      *daemon.Push
+		ExpectedError bool
+	}{
+		"GitHub push":           {http.Header{"X-Github-Event": {"push"}}, githubPush, &daemon.Push{Source: "github-public", Repository: "app"}, false},
+		"GitHub ping":           {http.Header{"X-Github-Event": {"ping"}}, `{"zen":"Keep it simple"}`, nil, false},
+		"Bitbucket Server push": {http.Header{"X-Event-Key": {"repo:refs_changed"}}, bitbucketServerPush, &daemon.Push{Source: "bitbucket-server", Repository: "app"}, false},
+		"Bitbucket Server ping": {http.Header{"X-Event-Key": {"diagnostics:ping"}}, `{"test":true}`, nil, false},
+		"Bitbucket Cloud push":  {http.Header{"X-Event-Key": {"repo:push"}}, bitbucketCloudPush, &daemon.Push{Source: "bitbucket-cloud", Repository: "my-app"}, false},
+		"Unknown sender":        {http.Header{}, githubPush, nil, true},
+		"Not JSON":              {http.Header{"X-Github-Event": {"push"}}, "ref=master", nil, true},
+		"No repository":         {http.Header{"X-Github-Event": {"push"}}, `{"ref":"refs/heads/master"}`, nil, true},
+	}
+
+	for tcName, tc := range cases {
+		push, err := daemon.ParsePush(tc.Header, []byte(tc.Payload))
+		if (err != nil) != tc.ExpectedError || !reflect.DeepEqual(push, tc.Expected) {
+			t.Errorf("%v - Expected: %+v %v | Actual: %+v %v", tcName, tc.Expected, tc.ExpectedError, push, err)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	os.Setenv("TEST_WEBHOOK_SECRET", "hook-secret")
+	defer os.Unsetenv("TEST_WEBHOOK_SECRET")
+
+	calls := make(chan string, 10)
+	d := daemon.New([]config.Integration{
+		{Name: "gh", Enabled: true, Source: config.Source{Type: "github-public"}, Webhook: config.Webhook{Secret: "TEST_WEBHOOK_SECRET"}},
+		{Name: "open", Enabled: true, Source: config.Source{Type: "github-public"}},
+	}, recorder{calls}.sync)
+	d.Debounce = 300 * time.Millisecond
+	server := httptest.NewServer(d.Handler("secret"))
+	defer server.Close()
+
+	cases := map[string]struct {
+		Path, Event, Payload, Signature string
+		ExpectedStatus                  int
+	}{
+		"Push":             {"/hooks/gh", "push", githubPush, sign(githubPush, "hook-secret"), http.StatusAccepted},
+		"Ping":             {"/hooks/gh", "ping", `{}`, sign(`{}`, "hook-secret"), http.StatusOK},
+		"Wrong signature":  {"/hooks/gh", "push", githubPush, sign(githubPush, "other"), http.StatusUnauthorized},
+		"No signature":     {"/hooks/gh", "push", githubPush, "", http.StatusUnauthorized},
+		"No secret":        {"/hooks/open", "push", githubPush, sign(githubPush, ""), http.StatusForbidden},
+		"Unknown":          {"/hooks/missing", "push", githubPush, sign(githubPush, "hook-secret"), http.StatusNotFound},
+		"Other source":     {"/hooks/gh", "", bitbucketServerPush, sign(bitbucketServerPush, "hook-secret"), http.StatusBadRequest},
+		"Burst of pushes":  {"/hooks/gh", "push", githubPush, sign(githubPush, "hook-secret"), http.StatusAccepted},
+		"Another push too": {"/hooks/gh", "push", githubPush, sign(githubPush, "hook-secret"), http.StatusAccepted},
+	}
+
+	for tcName, tc := range cases {
+		req, _ := http.NewRequest("POST", server.URL+tc.Path, bytes.NewReader([]byte(tc.Payload)))
+		if tc.Event != "" {
+			req.Header.Set("X-GitHub-Event", tc.Event)
+		} else {
+			req.Header.Set("X-Event-Key", "repo:refs_changed")
+		}
+		if tc.Signature != "" {
+			req.Header.Set("X-Hub-Signature-256", tc.Signature)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.ExpectedStatus {
+			t.Errorf("%v - Expected status: %v | Actual: %v", tcName, tc.ExpectedStatus, resp.StatusCode)
+		}
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go d.Run(ctx)
+
+	// The pushes are synced once after the debounce time
+	actual := waitCalls(t, calls, 1)
+	if !reflect.DeepEqual(actual, []string{"gh/app"}) {
+		t.Errorf("Expected syncs: [gh/app] | Actual: %v", actual)
+	}
+	select {
+	case call := <-calls:
+		t.Errorf("Unexpected sync: %v", call)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	status, _ := d.Integration("gh")
+	if status.LastRun == nil || status.LastRun.Trigger != daemon.TriggerWebhook {
+		t.Errorf("Unexpected last run: %+v", status.LastRun)
+	}
+}
@@ -1,8 +1,15 @@
 package bbcloud
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
-	bitbucket "github.com/ktrysmt/go-bitbucket"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Teams ...
@@ -10,15 +17,13 @@ type Teams struct {
 	AccountID, AccessToken string
 }
 
-// Repositories ...
-type Repositories struct {
-	AccountID, AccessToken string
-}
+// HTTP mocks the raw REST API client
+type HTTP struct{}
 
 // MockAPI ...
 type MockAPI struct {
-	Teams        Teams
-	Repositories Repositories
+	Teams Teams
+	HTTP  HTTP
 }
 
 // Projects ...
@@ -92,109 +97,323 @@ func (teams *Teams) Repositories(kindKey string) (interface{}, error) {
 	return res, nil
 }
 
-// ListForAccount ...
-func (repositories *Repositories) ListForAccount(ro *bitbucket.RepositoriesOptions) (*bitbucket.RepositoriesRes, error) {
+// Link ...
+type Link struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
 
-	// Check access token
-	if repositories.AccessToken != "token" {
-		return nil, errors.New("Bad credentials")
-	}
+// Project ...
+type Project struct {
+	Key string `json:"key"`
+}
 
-	// Check account ID
-	if repositories.AccountID != "username" {
-		return nil, errors.New("Access denied")
-	}
+// Branch ...
+type Branch struct {
+	Name string `json:"name"`
+}
 
-	// Check kind key
-	if ro.Owner != "username" {
-		return nil, errors.New("Access Denied")
-	}
+// Value ...
+type Value struct {
+	Slug        string            `json:"slug"`
+	Description string            `json:"description"`
+	IsPrivate   bool              `json:"is_private"`
+	Website     string            `json:"website"`
+	Mainbranch  *Branch           `json:"mainbranch,omitempty"`
+	Project     Project           `json:"project"`
+	Links       map[string][]Link `json:"links"`
+}
 
-	var res bitbucket.RepositoriesRes = bitbucket.RepositoriesRes{
-		Page:     1,
-		Pagelen:  3,
-		MaxDepth: 1,
-		Size:     3,
-		Items: []bitbucket.Repository{
-			{
-				Project: bitbucket.Project{
-					Key:  "WRONG",
-					Name: "Rolling Thunder",
-				},
-				Slug:        "repo-1",
-				Full_name:   "repo-1",
-				Description: "repository for testing",
-				ForkPolicy:  "fork_policy",
-				Type:        "repository",
-				Owner: map[string]interface{}{
-					"one": 1,
-				},
-				Links: map[string]interface{}{
-					"clone": []interface{}{
-						map[string]string{
-							"name": "ssh",
-							"href": "@ssh+repo1.link",
-						},
-						map[string]string{
-							"name": "https",
-							"href": "https://gitclub.com/username/repo-1.git",
-						},
-					},
-				},
-			},
-			{
-				Project: bitbucket.Project{
-					Key:  "TEST",
-					Name: "testing BB Cloud",
-				},
-				Slug:        "repo-2",
-				Full_name:   "repo-2",
-				Description: "repository for testing",
-				ForkPolicy:  "fork_policy",
-				Type:        "repository",
-				Owner: map[string]interface{}{
-					"one": 1,
-				},
-				Links: map[string]interface{}{
-					"clone": []interface{}{
-						map[string]string{
-							"name": "ssh",
-							"href": "@ssh+repo2.link",
-						},
-						map[string]string{
-							"name": "https",
-							"href": "https://gitclub.com/username/repo-2.git",
-						},
-					},
-				},
-			},
-			{
-				Project: bitbucket.Project{
-					Key:  "TEST",
-					Name: "testing BB Cloud",
-				},
-				Slug:        "repo-3",
-				Full_name:   "repo-3",
-				Description: "repository for testing",
-				ForkPolicy:  "fork_policy",
-				Type:        "repository",
-				Owner: map[string]interface{}{
-					"one": 1,
+// Page defines the paginated response format for the API
+type Page struct {
+	Page    int     `json:"page"`
+	Pagelen int     `json:"pagelen"`
+	Size    int     `json:"size"`
+	Next    string  `json:"next,omitempty"`
+	Values  []Value `json:"values"`
+}
+
+// PageLength is the number of repositories the mock returns per page
+const PageLength = 2
+
+// Workspaces maps the workspaces known to the mock to their repositories
+var Workspaces = map[string][]Value{
+	"username": {
+		newValue("username", "repo-1", "WRONG"),
+		newValue("username", "repo-2", "TEST"),
+		newValue("username", "repo-3", "TEST"),
+		newValue("username", "repo-4", "TEST"),
+		newValue("username", "hello", "TEST"),
+	},
+	"team": {
+		newValue("team", "team-repo-1", "CORE"),
+		newValue("team", "team-repo-2", "CORE"),
+		newValue("team", "team-repo-3", "EDGE"),
+	},
+	"labs": {
+		newValue("labs", "labs-repo-1", "CORE"),
+	},
+}
+
+func newValue(workspace, slug, project string) Value {
+	return Value{
+		Slug:        slug,
+		Description: "repository for testing",
+		IsPrivate:   true,
+		Website:     fmt.Sprintf("https://%v.example.com/%v", workspace, slug),
+		Mainbranch:  &Branch{Name: "main"},
+		Project:     Project{Key: project},
+		Links: map[string][]Link{
+			"clone": {
+				{
+					Name: "ssh",
+					Href: fmt.Sprintf("git@gitclub.com:%v/%v.git", workspace, slug),
 				},
-				Links: map[string]interface{}{
-					"clone": []interface{}{
-						map[string]string{
-							"name": "ssh",
-							"href": "@ssh+repo3.link",
-						},
-						map[string]string{
-							"name": "https",
-							"href": "https://gitclub.com/username/repo-3.git",
-						},
-					},
+				{
+					Name: "https",
+					Href: fmt.Sprintf("https://gitclub.com/%v/%v.git", workspace, slug),
 				},
 			},
 		},
 	}
-	return &res, nil
+}
+
+// User ...
+type User struct {
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+}
+
+// Comment ...
+type Comment struct {
+	ID        int                    `json:"id"`
+	User      User                   `json:"user"`
+	Content   map[string]string      `json:"content"`
+	CreatedOn string                 `json:"created_on"`
+	Deleted   bool                   `json:"deleted"`
+	Inline    map[string]interface{} `json:"inline,omitempty"`
+}
+
+// PullRequest ...
+type PullRequest struct {
+	ID          int                          `json:"id"`
+	Title       string                       `json:"title"`
+	Description string                       `json:"description"`
+	State       string                       `json:"state"`
+	Author      User                         `json:"author"`
+	CreatedOn   string                       `json:"created_on"`
+	Source      map[string]Branch            `json:"source"`
+	Destination map[string]Branch            `json:"destination"`
+	Reviewers   []User                       `json:"reviewers,omitempty"`
+	Links       map[string]map[string]string `json:"links"`
+	Comments    []Comment                    `json:"-"`
+}
+
+// PullRequests maps workspace/slug to the pull requests of the repository
+var PullRequests = map[string][]PullRequest{
+	"username/repo-2": {
+		newPullRequest(1, "OPEN", "feature/login", []Comment{
+			{ID: 11, User: User{Nickname: "bob", DisplayName: "Bob"}, Content: map[string]string{"raw": "Looks good"}, CreatedOn: "2020-05-02T10:00:00.000000+00:00"},
+			{ID: 12, User: User{Nickname: "bob", DisplayName: "Bob"}, Content: map[string]string{"raw": "Typo here"}, CreatedOn: "2020-05-02T11:00:00.000000+00:00",
+				Inline: map[string]interface{}{"path": "login.go", "to": 42}},
+			{ID: 13, User: User{Nickname: "bob", DisplayName: "Bob"}, Content: map[string]string{"raw": ""}, CreatedOn: "2020-05-02T12:00:00.000000+00:00", Deleted: true},
+		}),
+		newPullRequest(2, "MERGED", "feature/signup", nil),
+		newPullRequest(3, "DECLINED", "experiment", nil),
+	},
+}
+
+func newPullRequest(id int, state, branch string, comments []Comment) PullRequest {
+	return PullRequest{
+		ID:          id,
+		Title:       fmt.Sprintf("Pull request %v", id),
+		Description: fmt.Sprintf("Changes on %v", branch),
+		State:       state,
+		Author:      User{Nickname: "alice", DisplayName: "Alice"},
+		CreatedOn:   fmt.Sprintf("2020-05-0%vT09:00:00.000000+00:00", id),
+		Source:      map[string]Branch{"branch": {Name: branch}},
+		Destination: map[string]Branch{"branch": {Name: "main"}},
+		Reviewers:   []User{{Nickname: "bob", DisplayName: "Bob"}},
+		Links:       map[string]map[string]string{"html": {"href": fmt.Sprintf("https://bitbucket.org/username/repo-2/pull-requests/%v", id)}},
+		Comments:    comments,
+	}
+}
+
+// BranchRestrictions of the mock repositories by "<workspace>/<slug>", which POST requests add to
+var BranchRestrictions = map[string][]map[string]interface{}{}
+
+// findPullRequest looks up a pull request of the mock by its ID
+func findPullRequest(repo, id string) (PullRequest, bool) {
+	for _, pullRequest := range PullRequests[repo] {
+		if strconv.Itoa(pullRequest.ID) == id {
+			return pullRequest, true
+		}
+	}
+	return PullRequest{}, false
+}
+
+func respond(status int, body interface{}) (*http.Response, error) {
+	bodyBytes, _ := json.Marshal(body)
+	response := http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+	}
+	return &response, nil
+}
+
+func respondError(status int, message string) (*http.Response, error) {
+	return respond(status, map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"message": message},
+	})
+}
+
+// Do is the mock Do method that mimics the Bitbucket Cloud REST API
+func (h *HTTP) Do(req *http.Request) (*http.Response, error) {
+
+	// Check credentials
+	username, token, ok := req.BasicAuth()
+	if !ok {
+		return nil, errors.New("Authorization Failed: Bad Header")
+	}
+	if token != "token" || username != "username" {
+		return respondError(http.StatusUnauthorized, "Bad credentials")
+	}
+
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/2.0"), "/")
+	pathSplit := strings.Split(path, "/")
+
+	switch {
+	// GET /workspaces, the workspaces the user is a member of
+	case len(pathSplit) == 1 && pathSplit[0] == "workspaces":
+		slugs := []string{}
+		for slug := range Workspaces {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		values := []map[string]string{}
+		for _, slug := range slugs {
+			values = append(values, map[string]string{"slug": slug})
+		}
+		return respond(http.StatusOK, map[string]interface{}{"values": values})
+
+	// GET /workspaces/<workspace>
+	case len(pathSplit) == 2 && pathSplit[0] == "workspaces":
+		if _, exists := Workspaces[pathSplit[1]]; !exists {
+			return respondError(http.StatusNotFound, "No workspace with identifier "+pathSplit[1])
+		}
+		return respond(http.StatusOK, map[string]string{"slug": pathSplit[1]})
+
+	// GET /workspaces/<workspace>/projects/<key>
+	case len(pathSplit) == 4 && pathSplit[0] == "workspaces" && pathSplit[2] == "projects":
+		for _, repo := range Workspaces[pathSplit[1]] {
+			if repo.Project.Key == pathSplit[3] {
+				return respond(http.StatusOK, repo.Project)
+			}
+		}
+		return respondError(http.StatusNotFound, "Project not found")
+
+	// GET /repositories/<workspace>?q=project.key="<key>"&page=<n>
+	case len(pathSplit) == 2 && pathSplit[0] == "repositories":
+		repos, exists := Workspaces[pathSplit[1]]
+		if !exists {
+			return respondError(http.StatusNotFound, "Access denied")
+		}
+
+		query := req.URL.Query()
+		if q := query.Get("q"); q != "" {
+			key := strings.Trim(strings.TrimPrefix(q, "project.key="), `"`)
+			filtered := []Value{}
+			for _, repo := range repos {
+				if repo.Project.Key == key {
+					filtered = append(filtered, repo)
+				}
+			}
+			repos = filtered
+		}
+
+		page, err := strconv.Atoi(query.Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		start := (page - 1) * PageLength
+		end := start + PageLength
+		if start > len(repos) {
+			start = len(repos)
+		}
+		if end > len(repos) {
+			end = len(repos)
+		}
+
+		result := Page{
+			Page:    page,
+			Pagelen: PageLength,
+			Size:    len(repos),
+			Values:  repos[start:end],
+		}
+		if end < len(repos) {
+			query.Set("page", strconv.Itoa(page+1))
+			nextURL := *req.URL
+			nextURL.RawQuery = query.Encode()
+			result.Next = nextURL.String()
+		}
+		return respond(http.StatusOK, result)
+
+	// GET, POST /repositories/<workspace>/<slug>/branch-restrictions
+	case len(pathSplit) == 4 && pathSplit[0] == "repositories" && pathSplit[3] == "branch-restrictions":
+		repo := pathSplit[1] + "/" + pathSplit[2]
+		if req.Method == http.MethodPost {
+			restriction := map[string]interface{}{}
+			if req.Body == nil || json.NewDecoder(req.Body).Decode(&restriction) != nil {
+				return respondError(http.StatusBadRequest, "Bad request")
+			}
+			restriction["id"] = len(BranchRestrictions[repo]) + 1
+			BranchRestrictions[repo] = append(BranchRestrictions[repo], restriction)
+			return respond(http.StatusCreated, restriction)
+		}
+		values := BranchRestrictions[repo]
+		if values == nil {
+			values = []map[string]interface{}{}
+		}
+		return respond(http.StatusOK, map[string]interface{}{"values": values})
+
+	// GET /repositories/<workspace>/<slug>/pullrequests?state=<state>
+	case len(pathSplit) == 4 && pathSplit[0] == "repositories" && pathSplit[3] == "pullrequests":
+		states := map[string]bool{}
+		for _, state := range req.URL.Query()["state"] {
+			states[state] = true
+		}
+		values := []PullRequest{}
+		for _, pullRequest := range PullRequests[pathSplit[1]+"/"+pathSplit[2]] {
+			if states[pullRequest.State] {
+				// The list leaves out the reviewers
+				pullRequest.Reviewers = nil
+				values = append(values, pullRequest)
+			}
+		}
+		return respond(http.StatusOK, map[string]interface{}{"values": values})
+
+	// GET /repositories/<workspace>/<slug>/pullrequests/<id>
+	case len(pathSplit) == 5 && pathSplit[0] == "repositories" && pathSplit[3] == "pullrequests":
+		pullRequest, found := findPullRequest(pathSplit[1]+"/"+pathSplit[2], pathSplit[4])
+		if !found {
+			return respondError(http.StatusNotFound, "Pull request not found")
+		}
+		return respond(http.StatusOK, pullRequest)
+
+	// GET /repositories/<workspace>/<slug>/pullrequests/<id>/comments
+	case len(pathSplit) == 6 && pathSplit[0] == "repositories" && pathSplit[5] == "comments":
+		pullRequest, found := findPullRequest(pathSplit[1]+"/"+pathSplit[2], pathSplit[4])
+		if !found {
+			return respondError(http.StatusNotFound, "Pull request not found")
+		}
+		comments := pullRequest.Comments
+		if comments == nil {
+			comments = []Comment{}
+		}
+		return respond(http.StatusOK, map[string]interface{}{"values": comments})
+
+	default:
+		return respondError(http.StatusNotFound, "Resource not found")
+	}
 }
@@ -25,9 +25,27 @@ type Link struct {
 type Value struct {
 	Slug        string            `json:"slug"`
 	Description string            `json:"description"`
+	Public      bool              `json:"public"`
 	Links       map[string][]Link `json:"links"`
 }
 
+// Label ...
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Labels defines the response format for the labels API
+type Labels struct {
+	IsLastPage bool    `json:"isLastPage"`
+	Values     []Label `json:"values"`
+}
+
+// Branch defines the response format for the default branch API
+type Branch struct {
+	ID        string `json:"id"`
+	DisplayID string `json:"displayId"`
+}
+
 // Repos defines the response format for the API
 type Repos struct {
 	IsLastPage    bool    `json:"isLastPage"`
@@ -35,6 +53,56 @@ type Repos struct {
 	Values        []Value `json:"values"`
 }
 
+// PullRequests defines the response format for the pull requests and activities APIs
+type PullRequests struct {
+	IsLastPage bool                     `json:"isLastPage"`
+	Values     []map[string]interface{} `json:"values"`
+}
+
+func user(name, displayName string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         name,
+		"displayName":  displayName,
+		"emailAddress": name + "@company.com",
+	}
+}
+
+func pullRequest(id int, state, branch string, created int64) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          id,
+		"title":       "Pull request " + branch,
+		"description": "Changes on " + branch,
+		"state":       state,
+		"createdDate": created,
+		"author":      map[string]interface{}{"user": user("alice", "Alice")},
+		"reviewers":   []map[string]interface{}{{"user": user("bob", "Bob")}},
+		"fromRef":     map[string]string{"displayId": branch},
+		"toRef":       map[string]string{"displayId": "develop"},
+		"links":       map[string]interface{}{"self": []map[string]string{{"href": "https://bitbucket-test.company.com/projects/TEST/repos/project-repo-1/pull-requests"}}},
+	}
+}
+
+// Activities of pull request 1 of project-repo-1, newest first
+var activities = []map[string]interface{}{
+	{"action": "APPROVED", "user": user("bob", "Bob")},
+	{
+		"action":        "COMMENTED",
+		"commentAction": "ADDED",
+		"comment": map[string]interface{}{
+			"id": 2, "text": "Rename this", "createdDate": 1588420000000, "author": user("bob", "Bob"),
+			"comments": []map[string]interface{}{
+				{"id": 3, "text": "Done", "createdDate": 1588430000000, "author": user("alice", "Alice")},
+			},
+		},
+		"commentAnchor": map[string]interface{}{"path": "src/main.py", "line": 7},
+	},
+	{
+		"action":        "COMMENTED",
+		"commentAction": "ADDED",
+		"comment":       map[string]interface{}{"id": 1, "text": "Looks good", "createdDate": 1588410000000, "author": user("bob", "Bob")},
+	},
+}
+
 var (
 	projectSuffix = "/projects/TEST/repos"
 	userSuffix    = "/users/username/repos"
@@ -61,10 +129,26 @@ var (
 		token := pair[1]
 		ok := (username == "username") && (token == "token")
 		if !ok {
-			return nil, errors.New("Bad credentials")
+			return ErrorResponse(http.StatusUnauthorized, "Authentication failed. Please check your credentials and try again."), nil
 		}
 
 		switch {
+		case strings.HasSuffix(URL, "/project-repo-1/branches/default"):
+			return jsonResponse(Branch{ID: "refs/heads/develop", DisplayID: "develop"}), nil
+		case strings.HasSuffix(URL, "/project-repo-1/labels"):
+			return jsonResponse(Labels{IsLastPage: true, Values: []Label{{Name: "python"}, {Name: "utility"}}}), nil
+		case strings.HasSuffix(URL, "/project-repo-1/pull-requests"):
+			return jsonResponse(PullRequests{IsLastPage: true, Values: []map[string]interface{}{
+				pullRequest(1, "OPEN", "feature/parser", 1588400000000),
+				pullRequest(2, "MERGED", "feature/cli", 1588500000000),
+				pullRequest(3, "DECLINED", "spike", 1588600000000),
+			}}), nil
+		case strings.HasSuffix(URL, "/project-repo-1/pull-requests/1/activities"):
+			return jsonResponse(PullRequests{IsLastPage: true, Values: activities}), nil
+		case strings.HasSuffix(URL, "/activities"):
+			return jsonResponse(PullRequests{IsLastPage: true, Values: []map[string]interface{}{}}), nil
+		case strings.HasSuffix(URL, "/branches/default"), strings.HasSuffix(URL, "/labels"):
+			return ErrorResponse(http.StatusNotFound, "The requested resource does not exist."), nil
 		case projectRequest:
 			repos := Repos{
 				IsLastPage: true,
@@ -100,6 +184,7 @@ var (
 					{
 						Slug:        "user-repo-1",
 						Description: "describe user-repo-1",
+						Public:      true,
 						Links: map[string][]Link{
 							"clone": {
 								{
@@ -122,11 +207,39 @@ var (
 			}
 			return &response, nil
 		default:
-			return nil, errors.New("Unsupported kind")
+			return ErrorResponse(http.StatusNotFound, "The requested repository does not exist."), nil
 		}
 	}
 )
 
+// jsonResponse builds a successful response with the body encoded as JSON
+func jsonResponse(body interface{}) *http.Response {
+	bodyBytes, _ := json.Marshal(body)
+	response := http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+	}
+	return &response
+}
+
+// ErrorResponse builds a response in the Bitbucket Server error format
+func ErrorResponse(statusCode int, message string) *http.Response {
+	errorBytes, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]interface{}{
+			{
+				"context":       nil,
+				"message":       message,
+				"exceptionName": "com.atlassian.bitbucket.AuthorisationException",
+			},
+		},
+	})
+	response := http.Response{
+		StatusCode: statusCode,
+		Body:       ioutil.NopCloser(bytes.NewReader(errorBytes)),
+	}
+	return &response
+}
+
 // Do is the mock Do method that mimics http package functionality
 func (m *MockAPI) Do(req *http.Request) (*http.Response, error) {
 	return DoFunc(req)
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// Repositories of the org organization, over two pages
+var Repositories = [][]map[string]interface{}{
+	{
+		{"name": "app", "clone_url": "https://github.com/org/app.git", "description": "The app", "private": true,
+			"default_branch": "main", "homepage": "https://app.org.com", "topics": []string{"go"}, "has_wiki": true},
+	},
+	{
+		{"name": "docs", "clone_url": "https://github.com/org/docs.git", "private": false, "default_branch": "master"},
+	},
+}
+
+// Issues of org/app, oldest first, with a pull request the issues API lists too
+var Issues = []map[string]interface{}{
+	{
+		"number": 1, "title": "Crash on start", "body": "Stack trace attached", "state": "closed",
+		"html_url": "https://github.com/org/app/issues/1", "user": map[string]string{"login": "alice"},
+		"created_at": "2020-05-01T09:00:00Z", "comments": 1,
+		"labels":    []map[string]string{{"name": "bug", "color": "d73a4a", "description": "Something is broken"}},
+		"milestone": map[string]string{"title": "v1.0", "state": "open", "due_on": "2020-06-01T07:00:00Z"},
+	},
+	{
+		"number": 2, "title": "Add dark mode", "state": "open", "html_url": "https://github.com/org/app/pull/2",
+		"user": map[string]string{"login": "bob"}, "created_at": "2020-05-02T09:00:00Z",
+		"pull_request": map[string]string{"url": "https://api.github.com/repos/org/app/pulls/2"},
+	},
+}
+
+// Comments of issue 1 of org/app
+var Comments = []map[string]interface{}{
+	{"id": 101, "body": "Fixed in main", "user": map[string]string{"login": "bob"}, "created_at": "2020-05-01T10:00:00Z"},
+}
+
+// Releases of org/app, newest first
+var Releases = []map[string]interface{}{
+	{
+		"tag_name": "v1.0.0", "name": "First release", "body": "Fixes the crash", "prerelease": false, "draft": false,
+		"html_url": "https://github.com/org/app/releases/tag/v1.0.0", "author": map[string]string{"login": "alice"},
+		"created_at": "2020-06-01T09:00:00Z",
+		"assets": []map[string]interface{}{{"id": 11, "name": "app.tar.gz", "content_type": "application/gzip", "size": 14,
+			"browser_download_url": "https://github.com/org/app/releases/download/v1.0.0/app.tar.gz"}},
+	},
+}
+
+// Asset is the contents of every release asset
+const Asset = "asset contents"
+
+func write(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// NewServer starts a server that mimics the GitHub Enterprise REST API under /api/v3
+// Requests without the token are unauthorized
+func NewServer(token string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/org", func(w http.ResponseWriter, r *http.Request) {
+		write(w, map[string]string{"login": "org"})
+	})
+	mux.HandleFunc("/api/v3/orgs/org/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			write(w, Repositories[1])
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%v%v?page=2>; rel="next"`, r.Host, r.URL.Path))
+		write(w, Repositories[0])
+	})
+	mux.HandleFunc("/api/v3/repos/org/app/issues", func(w http.ResponseWriter, r *http.Request) {
+		write(w, Issues)
+	})
+	mux.HandleFunc("/api/v3/repos/org/app/releases", func(w http.ResponseWriter, r *http.Request) {
+		write(w, Releases)
+	})
+	mux.HandleFunc("/api/v3/repos/org/app/releases/assets/11", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/octet-stream" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Write([]byte(Asset))
+	})
+	mux.HandleFunc("/api/v3/repos/org/app/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		write(w, Comments)
+	})
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			write(w, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
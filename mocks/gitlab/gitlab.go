@@ -0,0 +1,157 @@
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MockAPI is a mock GitLab API client to help with testing
+type MockAPI struct{}
+
+// PageLength is the number of items the mock returns per page
+const PageLength = 2
+
+func project(path, visibility string) map[string]interface{} {
+	return map[string]interface{}{
+		"path":             path,
+		"description":      "describe " + path,
+		"visibility":       visibility,
+		"default_branch":   "main",
+		"topics":           []string{"go"},
+		"http_url_to_repo": fmt.Sprintf("https://gitlab.company.com/team/%v.git", path),
+		"wiki_enabled":     path == "app",
+	}
+}
+
+func user(username, name string) map[string]string {
+	return map[string]string{"username": username, "name": name}
+}
+
+// Projects of the team group
+var Projects = []map[string]interface{}{
+	project("app", "private"),
+	project("docs", "public"),
+	project("infra", "internal"),
+}
+
+// Issues of team/app, oldest first
+var Issues = []map[string]interface{}{
+	{
+		"iid": 1, "title": "Crash on start", "description": "Stack trace attached", "state": "closed",
+		"web_url": "https://gitlab.company.com/team/app/-/issues/1", "author": user("alice", "Alice"),
+		"created_at": "2020-05-01T09:00:00.000Z", "user_notes_count": 2,
+		"labels":    []map[string]string{{"name": "bug", "color": "#d73a4a", "description": "Something is broken"}},
+		"milestone": map[string]string{"title": "v1.0", "state": "active", "due_date": "2020-06-01"},
+	},
+	{
+		"iid": 2, "title": "Add dark mode", "description": "", "state": "opened",
+		"web_url": "https://gitlab.company.com/team/app/-/issues/2", "author": user("bob", "Bob"),
+		"created_at": "2020-05-02T09:00:00.000Z", "user_notes_count": 0,
+		"labels": []string{"feature"}, "milestone": nil,
+	},
+}
+
+// Notes of issue 1 of team/app, oldest first
+var Notes = []map[string]interface{}{
+	{"id": 101, "body": "Fixed in main", "author": user("bob", "Bob"), "created_at": "2020-05-01T10:00:00.000Z", "system": false},
+	{"id": 102, "body": "closed", "author": user("bob", "Bob"), "created_at": "2020-05-01T10:01:00.000Z", "system": true},
+}
+
+// Releases of team/app, newest first, with a file on the instance and an external link
+var Releases = []map[string]interface{}{
+	{
+		"tag_name": "v1.1.0", "name": "Dark mode", "description": "Adds dark mode", "upcoming_release": true,
+		"created_at": "2020-06-02T09:00:00.000Z", "author": user("bob", "Bob"),
+		"_links": map[string]string{"self": "https://gitlab.company.com/team/app/-/releases/v1.1.0"},
+		"assets": map[string]interface{}{"links": []map[string]interface{}{}},
+	},
+	{
+		"tag_name": "v1.0.0", "name": "First release", "description": "Fixes the crash", "upcoming_release": false,
+		"created_at": "2020-06-01T09:00:00.000Z", "author": user("alice", "Alice"),
+		"_links": map[string]string{"self": "https://gitlab.company.com/team/app/-/releases/v1.0.0"},
+		"assets": map[string]interface{}{"links": []map[string]interface{}{
+			{"id": 1, "name": "app.tar.gz", "url": "https://gitlab.company.com/team/app/-/jobs/1/artifacts/app.tar.gz",
+				"direct_asset_url": "https://gitlab.company.com/team/app/-/releases/v1.0.0/downloads/app.tar.gz"},
+			{"id": 2, "name": "notes.pdf", "url": "https://files.company.com/notes.pdf"},
+		}},
+	},
+}
+
+// Asset is the contents of every release asset
+const Asset = "asset contents"
+
+// respond gives a page of the items, with the X-Next-Page header if more follow
+func respond(req *http.Request, items []map[string]interface{}) *http.Response {
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	start := (page - 1) * PageLength
+	end := start + PageLength
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+
+	header := http.Header{}
+	if end < len(items) {
+		header.Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	bodyBytes, _ := json.Marshal(items[start:end])
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+	}
+}
+
+// ErrorResponse builds a response in the GitLab error format
+func ErrorResponse(statusCode int, message string) *http.Response {
+	bodyBytes, _ := json.Marshal(map[string]string{"message": message})
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+	}
+}
+
+// Do is the mock Do method that mimics the GitLab REST API
+func (m *MockAPI) Do(req *http.Request) (*http.Response, error) {
+	// Files linked from releases outside the instance are served without a token
+	if req.URL.Host == "files.company.com" {
+		if req.Header.Get("PRIVATE-TOKEN") != "" {
+			return ErrorResponse(http.StatusBadRequest, "Token sent to another host"), nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(Asset))}, nil
+	}
+	if req.Header.Get("PRIVATE-TOKEN") != "token" {
+		return ErrorResponse(http.StatusUnauthorized, "401 Unauthorized"), nil
+	}
+
+	path := strings.TrimPrefix(req.URL.EscapedPath(), "/api/v4")
+	switch {
+	case path == "/groups/team":
+		return respond(req, nil), nil
+	case path == "/groups/team/projects":
+		return respond(req, Projects), nil
+	case path == "/users" && req.URL.Query().Get("username") == "username":
+		return respond(req, []map[string]interface{}{{"username": "username"}}), nil
+	case path == "/projects/team%2Fapp/issues":
+		return respond(req, Issues), nil
+	case path == "/projects/team%2Fapp/issues/1/notes":
+		return respond(req, Notes), nil
+	case path == "/projects/team%2Fapp/releases":
+		return respond(req, Releases), nil
+	case req.URL.Path == "/team/app/-/releases/v1.0.0/downloads/app.tar.gz":
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(Asset))}, nil
+	default:
+		return ErrorResponse(http.StatusNotFound, "404 Not Found"), nil
+	}
+}
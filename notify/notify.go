@@ -0,0 +1,238 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+)
+
+// Kinds of failures in a digest
+const (
+	FailedRepository = "repository"
+	FailedBranch     = "branch"
+	FailedTag        = "tag"
+	DivergedBranch   = "diverged"
+	FailedWiki       = "wiki"
+	FailedMigration  = "migration"
+)
+
+// Failure is something that was not synced in a run
+// New failures were not in the digest of the run before
+type Failure struct {
+	Repository string `json:"repository"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	New        bool   `json:"new"`
+}
+
+// key tells the failure apart from the others of an integration
+func (failure Failure) key() string {
+	return failure.Repository + "/" + failure.Kind + "/" + failure.Name
+}
+
+// Digest sums up a run of an integration for the notifications
+type Digest struct {
+	Integration  string    `json:"integration"`
+	Started      time.Time `json:"started"`
+	Finished     time.Time `json:"finished"`
+	Repositories int       `json:"repositories"`
+	Failed       int       `json:"failed"`
+	Failures     []Failure `json:"failures,omitempty"`
+}
+
+// NewFailures counts the failures of the digest that the run before did not have
+func (digest Digest) NewFailures() int {
+	count := 0
+	for _, failure := range digest.Failures {
+		if failure.New {
+			count++
+		}
+	}
+	return count
+}
+
+// rejectionReason finds why the target rejected the push of the ref, if it did
+func rejectionReason(repoReport git.RepositoryReport, ref string) string {
+	for _, rejection := range repoReport.Rejections {
+		if rejection.Ref == ref {
+			return rejection.Reason
+		}
+	}
+	return ""
+}
+
+// NewDigest sums up the report, with failures marked new unless their key is in previous
+func NewDigest(report git.Report, previous map[string]bool) Digest {
+	digest := Digest{
+		Integration:  report.Integration,
+		Started:      report.Started,
+		Finished:     report.Finished,
+		Repositories: len(report.Repositories),
+	}
+
+	for _, repoReport := range report.Failed() {
+		digest.Failed++
+		var failures []Failure
+		if repoReport.Error != "" {
+			failures = append(failures, Failure{Kind: FailedRepository, Reason: repoReport.Error})
+		}
+		for _, branch := range repoReport.FailedBranches {
+			failures = append(failures, Failure{Kind: FailedBranch, Name: branch, Reason: rejectionReason(repoReport, "refs/heads/"+branch)})
+		}
+		for _, tag := range repoReport.FailedTags {
+			failures = append(failures, Failure{Kind: FailedTag, Name: tag, Reason: rejectionReason(repoReport, "refs/tags/"+tag)})
+		}
+		for _, divergence := range repoReport.Divergences {
+			failures = append(failures, Failure{Kind: DivergedBranch, Name: divergence.Branch, Reason: "Commits on both source and target"})
+		}
+		if repoReport.WikiError != "" {
+			failures = append(failures, Failure{Kind: FailedWiki, Reason: repoReport.WikiError})
+		}
+		// LFS objects, migrations and cut-overs are summed up as one failure of the repository
+		if len(failures) == 0 {
+			failures = append(failures, Failure{Kind: FailedMigration, Reason: "See the report of the run"})
+		}
+
+		for _, failure := range failures {
+			failure.Repository = repoReport.Slug
+			failure.New = !previous[failure.key()]
+			digest.Failures = append(digest.Failures, failure)
+		}
+	}
+	return digest
+}
+
+// channel is a configured notification
+type channel struct {
+	kind        string
+	newFailures bool
+	sender      Sender
+}
+
+// Notifier sends the digests of the runs of an integration to its notifications
+// The failures of the last run of each repository are kept in the state file to tell new failures apart
+type Notifier struct {
+	integration string
+	stateFile   string
+	channels    []channel
+}
+
+// New gives a notifier for the notifications of the integration, keeping failures in stateFile
+func New(integration config.Integration, stateFile string) (*Notifier, error) {
+	notifier := &Notifier{integration: integration.Name, stateFile: stateFile}
+	for _, notification := range integration.Notifications {
+		switch notification.When {
+		case "", config.NotifyAlways, config.NotifyNewFailures:
+		default:
+			return nil, fmt.Errorf("Notification when %v is not always or new_failures", notification.When)
+		}
+		sender, err := newSender(notification)
+		if err != nil {
+			return nil, err
+		}
+		notifier.channels = append(notifier.channels, channel{
+			kind:        notification.Type,
+			newFailures: notification.When == config.NotifyNewFailures,
+			sender:      sender,
+		})
+	}
+	return notifier, nil
+}
+
+// state reads the failure keys of the last run of every repository, none when it has not run yet
+func (notifier *Notifier) state(ctx context.Context) map[string][]string {
+	state := map[string][]string{}
+	content, err := ioutil.ReadFile(notifier.stateFile)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		logger.FromContext(ctx).WithFields(logrus.Fields{
+			"integration": notifier.integration,
+			"file":        notifier.stateFile,
+			"error":       err.Error(),
+		}).Warningf("Failed to read notified failures, all failures are new")
+		return map[string][]string{}
+	}
+	return state
+}
+
+// save keeps the failure keys of the synced repositories for the next run
+// Repositories that were not part of the run keep the keys of their last run
+func (notifier *Notifier) save(state map[string][]string, report git.Report, digest Digest) error {
+	for _, repoReport := range report.Repositories {
+		delete(state, repoReport.Slug)
+	}
+	for _, failure := range digest.Failures {
+		state[failure.Repository] = append(state[failure.Repository], failure.key())
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(notifier.stateFile), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(notifier.stateFile, content, 0644)
+}
+
+// Notify sends the digest of the report to every notification of the integration
+// Notifications for new failures are skipped when the run has none, failures are only kept as
+// notified once every notification was sent
+// Failures are logged through the logger of the run in the context
+func (notifier *Notifier) Notify(ctx context.Context, report git.Report) error {
+	if len(notifier.channels) == 0 {
+		return nil
+	}
+	runLog := logger.FromContext(ctx)
+
+	state := notifier.state(ctx)
+	previous := map[string]bool{}
+	for _, keys := range state {
+		for _, key := range keys {
+			previous[key] = true
+		}
+	}
+	digest := NewDigest(report, previous)
+
+	failed := false
+	for _, channel := range notifier.channels {
+		if channel.newFailures && digest.NewFailures() == 0 {
+			continue
+		}
+		err := channel.sender.Send(digest)
+		if err != nil {
+			failed = true
+			runLog.WithFields(logrus.Fields{
+				"integration":  notifier.integration,
+				"notification": channel.kind,
+				"error":        err.Error(),
+			}).Errorf("Failed to send notification")
+		}
+	}
+	if failed {
+		// Failures stay new until every notification about them was sent
+		return fmt.Errorf("Some notifications not sent")
+	}
+
+	err := notifier.save(state, report, digest)
+	if err != nil {
+		runLog.WithFields(logrus.Fields{
+			"integration": notifier.integration,
+			"file":        notifier.stateFile,
+			"error":       err.Error(),
+		}).Warningf("Failed to save notified failures")
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+	notify "github.com/parinithshekar/gitsink/notify"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+)
+
+var report = git.Report{
+	Integration: "team",
+	Repositories: []git.RepositoryReport{
+		{Slug: "app"},
+		{
+			Slug:           "api",
+			FailedBranches: []string{"master", "feature"},
+			Rejections:     []git.Rejection{{Ref: "refs/heads/master", Reason: git.RejectedLargeFile}},
+		},
+		{Slug: "web", Error: "Repository not found"},
+	},
+}
+
+func TestNewDigest(t *testing.T) {
+	cases := map[string]struct {
+		Previous         map[string]bool
+		ExpectedFailures []notify.Failure
+	}{
+		"First run": {
+			nil,
+			[]notify.Failure{
+				{Repository: "api", Kind: notify.FailedBranch, Name: "master", Reason: git.RejectedLargeFile, New: true},
+				{Repository: "api", Kind: notify.FailedBranch, Name: "feature", New: true},
+				{Repository: "web", Kind: notify.FailedRepository, Reason: "Repository not found", New: true},
+			},
+		},
+		"Known failures": {
+			map[string]bool{"api/branch/master": true, "web/repository/": true},
+			[]notify.Failure{
+				{Repository: "api", Kind: notify.FailedBranch, Name: "master", Reason: git.RejectedLargeFile},
+				{Repository: "api", Kind: notify.FailedBranch, Name: "feature", New: true},
+				{Repository: "web", Kind: notify.FailedRepository, Reason: "Repository not found"},
+			},
+		},
+	}
+
+	for tcName, tc := range cases {
+		digest := notify.NewDigest(report, tc.Previous)
+		if digest.Repositories != 3 || digest.Failed != 2 || !reflect.DeepEqual(digest.Failures, tc.ExpectedFailures) {
+			t.Errorf("%v - Expected: %+v | Actual: %+v", tcName, tc.ExpectedFailures, digest)
+		}
+	}
+}
+
+// smtpStandIn accepts mails on a local port and sends every message it gets on a channel
+func smtpStandIn(t *testing.T) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+				reply("220 localhost ESMTP")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					command := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+						reply("250 localhost")
+					case command == "DATA":
+						reply("354 End data with <CR><LF>.<CR><LF>")
+						var message []string
+						for {
+							line, err := reader.ReadString('\n')
+							if err != nil || line == ".\r\n" {
+								break
+							}
+							message = append(message, line)
+						}
+						messages <- strings.Join(message, "")
+						reply("250 OK")
+					case command == "QUIT":
+						reply("221 Bye")
+						return
+					default:
+						reply("250 OK")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener, messages
+}
+
+func TestNotify(t *testing.T) {
+	bodies := map[string][]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], body)
+	}))
+	defer server.Close()
+	for name, path := range map[string]string{"TEST_NOTIFY_WEBHOOK": "/webhook", "TEST_NOTIFY_SLACK": "/slack", "TEST_NOTIFY_TEAMS": "/teams"} {
+		os.Setenv(name, server.URL+path)
+		defer os.Unsetenv(name)
+	}
+	listener, mails := smtpStandIn(t)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notifier, err := notify.New(config.Integration{
+		Name: "team",
+		Notifications: []config.Notification{
+			{Type: notify.TypeWebhook, URL: "TEST_NOTIFY_WEBHOOK"},
+			{Type: notify.TypeSlack, URL: "TEST_NOTIFY_SLACK", When: config.NotifyNewFailures},
+			{Type: notify.TypeTeams, URL: "TEST_NOTIFY_TEAMS", When: config.NotifyNewFailures},
+			{Type: notify.TypeSMTP, When: config.NotifyNewFailures, SMTP: config.SMTP{Host: "127.0.0.1", Port: port, From: "gitsink@example.com", To: []string{"team@example.com"}}},
+		},
+	}, filepath.Join(dir, "team", ".notified.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second run has no new failures, and a run of one repository keeps the failures of others
+	for _, run := range []git.Report{report, report, {Integration: "team", Repositories: report.Repositories[:1]}, report} {
+		if err := notifier.Notify(context.Background(), run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectedCounts := map[string]int{"/webhook": 4, "/slack": 1, "/teams": 1}
+	for path, count := range expectedCounts {
+		if len(bodies[path]) != count {
+			t.Errorf("%v - Expected notifications: %v | Actual: %v", path, count, len(bodies[path]))
+		}
+	}
+	if bodies["/webhook"][0]["integration"] != "team" || bodies["/webhook"][0]["failed"] != float64(2) {
+		t.Errorf("Unexpected webhook digest: %v", bodies["/webhook"][0])
+	}
+	if text, _ := bodies["/slack"][0]["text"].(string); !strings.Contains(text, "- api: branch master (large-file) [new]") {
+		t.Errorf("Unexpected Slack message: %v", text)
+	}
+	if bodies["/teams"][0]["@type"] != "MessageCard" {
+		t.Errorf("Unexpected Teams message: %v", bodies["/teams"][0])
+	}
+
+	select {
+	case mail := <-mails:
+		if !strings.Contains(mail, "Subject: gitsink: team synced 3 repositories, 2 failed (3 new failures)") || !strings.Contains(mail, "To: team@example.com") {
+			t.Errorf("Unexpected mail: %v", mail)
+		}
+	default:
+		t.Errorf("Expected a mail")
+	}
+	if len(mails) != 0 {
+		t.Errorf("Expected one mail | Actual: %v more", len(mails))
+	}
+}
+
+func TestNotifyUnsent(t *testing.T) {
+	// The first send fails, so the failures are still new on the next run
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("TEST_NOTIFY_SLACK", server.URL)
+	defer os.Unsetenv("TEST_NOTIFY_SLACK")
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notifier, err := notify.New(config.Integration{
+		Name: "team",
+		Notifications: []config.Notification{
+			{Type: notify.TypeSlack, URL: "TEST_NOTIFY_SLACK", When: config.NotifyNewFailures},
+		},
+	}, filepath.Join(dir, "team", ".notified.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedErrors := []bool{true, false, false}
+	for i, expectedError := range expectedErrors {
+		err := notifier.Notify(context.Background(), report)
+		if (err != nil) != expectedError {
+			t.Errorf("Run %v - Expected error: %v | Actual: %v", i+1, expectedError, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected notifications: 2 | Actual: %v", requests)
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		Notification  config.Notification
+		ExpectedError bool
+	}{
+		"Webhook URL not set": {config.Notification{Type: notify.TypeWebhook, URL: "TEST_NOTIFY_UNSET"}, true},
+		"Unknown type":        {config.Notification{Type: "pager"}, true},
+		"Unknown when":        {config.Notification{Type: notify.TypeSMTP, When: "sometimes", SMTP: config.SMTP{Host: "localhost", From: "a@example.com", To: []string{"b@example.com"}}}, true},
+		"SMTP without to":     {config.Notification{Type: notify.TypeSMTP, SMTP: config.SMTP{Host: "localhost", From: "a@example.com"}}, true},
+		"SMTP":                {config.Notification{Type: notify.TypeSMTP, SMTP: config.SMTP{Host: "localhost", Port: 25, From: "a@example.com", To: []string{"b@example.com"}}}, false},
+	}
+
+	for tcName, tc := range cases {
+		_, err := notify.New(config.Integration{Name: "team", Notifications: []config.Notification{tc.Notification}}, "")
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/parinithshekar/gitsink/common/config"
+)
+
+// Notification types in config
+const (
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+	TypeTeams   = "teams"
+	TypeSMTP    = "smtp"
+)
+
+// defaultSMTPPort is the mail submission port
+const defaultSMTPPort = 587
+
+// maxListed is how many failures a message lists before leaving the rest to the report
+const maxListed = 20
+
+// Sender delivers digests to one destination
+type Sender interface {
+	Send(digest Digest) error
+}
+
+// newSender gives the sender of the notification type
+func newSender(notification config.Notification) (Sender, error) {
+	switch notification.Type {
+	case TypeWebhook, TypeSlack, TypeTeams:
+		URL := os.Getenv(notification.URL)
+		if URL == "" {
+			return nil, fmt.Errorf("Environment variable %v with the %v notification URL is not set", notification.URL, notification.Type)
+		}
+		return &webhook{kind: notification.Type, URL: URL, client: &http.Client{Timeout: 15 * time.Second}}, nil
+
+	case TypeSMTP:
+		settings := notification.SMTP
+		if settings.Host == "" || settings.From == "" || len(settings.To) == 0 {
+			return nil, fmt.Errorf("SMTP notification needs a host, from and to addresses")
+		}
+		if settings.Port == 0 {
+			settings.Port = defaultSMTPPort
+		}
+		return &mail{settings: settings}, nil
+	}
+	return nil, fmt.Errorf("Notification type %v is not supported", notification.Type)
+}
+
+// Title sums up the digest in one line
+func (digest Digest) Title() string {
+	if digest.Failed == 0 {
+		return fmt.Sprintf("gitsink: %v synced %v repositories", digest.Integration, digest.Repositories)
+	}
+	return fmt.Sprintf("gitsink: %v synced %v repositories, %v failed (%v new failures)", digest.Integration, digest.Repositories, digest.Failed, digest.NewFailures())
+}
+
+// Text lists the failures of the digest, one per line
+func (digest Digest) Text() string {
+	var lines []string
+	for i, failure := range digest.Failures {
+		if i == maxListed {
+			lines = append(lines, fmt.Sprintf("... and %v more", len(digest.Failures)-maxListed))
+			break
+		}
+		line := fmt.Sprintf("- %v: %v", failure.Repository, failure.Kind)
+		if failure.Name != "" {
+			line += " " + failure.Name
+		}
+		if failure.Reason != "" {
+			line += " (" + failure.Reason + ")"
+		}
+		if failure.New {
+			line += " [new]"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// webhook posts digests to a generic, Slack or Teams webhook
+type webhook struct {
+	kind   string
+	URL    string
+	client *http.Client
+}
+
+// payload gives the body of the webhook, the digest itself for generic webhooks
+func (webhook *webhook) payload(digest Digest) interface{} {
+	text := digest.Title()
+	if details := digest.Text(); details != "" {
+		text += "\n" + details
+	}
+	switch webhook.kind {
+	case TypeSlack:
+		return map[string]string{"text": text}
+	case TypeTeams:
+		// Teams renders the text as markdown, which needs blank lines between list items
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  digest.Title(),
+			"title":    digest.Title(),
+			"text":     strings.Replace(digest.Text(), "\n", "\n\n", -1),
+		}
+	}
+	return digest
+}
+
+// Send posts the digest to the webhook
+func (webhook *webhook) Send(digest Digest) error {
+	body, err := json.Marshal(webhook.payload(digest))
+	if err != nil {
+		return err
+	}
+	resp, err := webhook.client.Post(webhook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook answered %v", resp.Status)
+	}
+	return nil
+}
+
+// mail sends digests as plain text mails
+type mail struct {
+	settings config.SMTP
+}
+
+// Send mails the digest to the addresses of the notification
+func (mail *mail) Send(digest Digest) error {
+	settings := mail.settings
+	var auth smtp.Auth
+	if settings.Username != "" {
+		auth = smtp.PlainAuth("", os.Getenv(settings.Username), os.Getenv(settings.Password), settings.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + settings.From,
+		"To: " + strings.Join(settings.To, ", "),
+		"Subject: " + digest.Title(),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		digest.Title(),
+		"",
+		digest.Text(),
+		"",
+	}, "\n")
+	message = strings.Replace(message, "\n", "\r\n", -1)
+
+	addr := net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port))
+	return smtp.SendMail(addr, auth, settings.From, settings.To, []byte(message))
+}
@@ -8,6 +8,12 @@ const (
 	// CounterVec errors.
 	ErrCounterVecCreateFailed = Error("Failed to create counter vector.")
 
+	// HistogramVec errors.
+	ErrHistogramVecCreateFailed = Error("Failed to create histogram vector.")
+
+	// GaugeVec errors.
+	ErrGaugeVecCreateFailed = Error("Failed to create gauge vector.")
+
 	// Errors used for testing.
 	ErrNoMatch = Error("No alert matched in alert config.")
 
@@ -1,10 +1,15 @@
 package cloud
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	bitbucket "github.com/ktrysmt/go-bitbucket"
 	logrus "github.com/sirupsen/logrus"
@@ -13,26 +18,36 @@ import (
 	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
 	utils "github.com/parinithshekar/gitsink/common/utils"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
 	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
 )
 
 var (
-	log = logger.New()
+	log = logger.Root()
+)
+
+const (
+	// apiBaseURL is the Bitbucket Cloud REST API root
+	apiBaseURL = "https://api.bitbucket.org/2.0"
+	// pageLength is the maximum page size allowed by the repositories endpoint
+	pageLength = 100
 )
 
 // Teams interface declares methods to implement in the API object
 type Teams interface {
-	Projects(string) (interface{}, error)
 	Repositories(string) (interface{}, error)
 }
 
-// Repositories interface declares methods to implement in the API object
-type Repositories interface {
-	ListForAccount(*bitbucket.RepositoriesOptions) (*bitbucket.RepositoriesRes, error)
+// APIClient defines the methods for the raw REST API in Cloud object
+type APIClient interface {
+	Do(req *http.Request) (*http.Response, error)
 }
 
 // Cloud struct defines data fields in bitbucket-cloud object
 type Cloud struct {
+	apiBaseURL  string
 	accountID   string
 	accessToken string
 	kind        string
@@ -41,9 +56,18 @@ type Cloud struct {
 		exclude []string
 	}
 	API struct {
-		Teams        Teams
-		Repositories Repositories
+		Teams Teams
+		HTTP  APIClient
 	}
+	log pkg.Logger
+}
+
+// kind is the parsed form of the kind string in config
+// user/<name>, project/<key>, workspace/<name> and workspace/<name>/project/<key>
+type kind struct {
+	kindType  string
+	workspace string
+	project   string
 }
 
 // setAPIClient builds and returns an object to facilitate calls to the API
@@ -53,7 +77,12 @@ func (cloud *Cloud) setAPIClient() {
 
 	client := bitbucket.NewBasicAuth(accountID, accessToken)
 	cloud.API.Teams = client.Teams
-	cloud.API.Repositories = client.Repositories
+
+	cloud.apiBaseURL = apiBaseURL
+	cloud.API.HTTP = &http.Client{
+		Transport: metrics.Transport("input/bitbucket-cloud", nil),
+		Timeout:   time.Second * 15,
+	}
 }
 
 // Credentials fetches amd returns the accountID and accessToken from environment variables
@@ -63,7 +92,7 @@ func (cloud Cloud) Credentials() (string, string, error) {
 
 	accountID, exists := os.LookupEnv(cloud.accountID)
 	if !exists {
-		log.WithFields(logrus.Fields{
+		cloud.log.WithFields(logrus.Fields{
 			"accountID": cloud.accountID,
 		}).Errorf("Account ID not found")
 		return "", "", fmt.Errorf("Account ID not found")
@@ -71,7 +100,7 @@ func (cloud Cloud) Credentials() (string, string, error) {
 
 	accessToken, exists = os.LookupEnv(cloud.accessToken)
 	if !exists {
-		log.WithFields(logrus.Fields{
+		cloud.log.WithFields(logrus.Fields{
 			"accessToken": cloud.accessToken,
 		}).Errorf("Access Token not found")
 		return "", "", fmt.Errorf("Access Token not found")
@@ -80,9 +109,20 @@ func (cloud Cloud) Credentials() (string, string, error) {
 	return accountID, accessToken, nil
 }
 
+// SetLogger has the plugin log through the logger of the run it is used in
+func (cloud *Cloud) SetLogger(log pkg.Logger) {
+	cloud.log = log
+}
+
+// Capabilities tells what Bitbucket Cloud sources support
+func (cloud *Cloud) Capabilities() v2.Capabilities {
+	return v2.Capabilities{LFS: true, PullRequests: true, Wiki: true, Lock: true}
+}
+
 // New returns a new bitbucket-cloud object
 func New(source config.Source) (*Cloud, error) {
 	var cloud *Cloud = new(Cloud)
+	cloud.log = log
 
 	_, exists := os.LookupEnv(source.AccountID)
 	if !exists {
@@ -111,147 +151,304 @@ func New(source config.Source) (*Cloud, error) {
 	return cloud, nil
 }
 
+// parseKind splits the kind from config into its type, workspace and project key
+// The workspace of a project/<key> kind is left for kindOf to find
+func (cloud Cloud) parseKind() (kind, error) {
+	kindSplit := strings.Split(cloud.kind, "/")
+
+	switch {
+	case len(kindSplit) == 2 && kindSplit[0] == "user":
+		return kind{kindType: "user", workspace: kindSplit[1]}, nil
+
+	case len(kindSplit) == 2 && kindSplit[0] == "project":
+		return kind{kindType: "project", project: kindSplit[1]}, nil
+
+	case len(kindSplit) == 2 && kindSplit[0] == "workspace":
+		return kind{kindType: "workspace", workspace: kindSplit[1]}, nil
+
+	case len(kindSplit) == 4 && kindSplit[0] == "workspace" && kindSplit[2] == "project":
+		return kind{kindType: "workspace-project", workspace: kindSplit[1], project: kindSplit[3]}, nil
+
+	default:
+		return kind{kindType: kindSplit[0]}, fmt.Errorf("Unsupported kind")
+	}
+}
+
+// kindOf parses the kind from config. Projects given without a workspace are looked up in
+// all the workspaces the account is a member of, and must belong to exactly one of them
+func (cloud Cloud) kindOf(ctx context.Context, accountID, accessToken string) (kind, error) {
+	k, err := cloud.parseKind()
+	if err != nil || k.kindType != "project" {
+		return k, err
+	}
+
+	workspaces, err := cloud.allValues(ctx, fmt.Sprintf("%v/workspaces?pagelen=%v", cloud.apiBaseURL, pageLength), accountID, accessToken)
+	if err != nil {
+		return k, err
+	}
+
+	owners := []string{}
+	for _, workspace := range workspaces {
+		slug := workspace.Get("slug").String()
+		projectURL := fmt.Sprintf("%v/workspaces/%v/projects/%v", cloud.apiBaseURL, url.PathEscape(slug), url.PathEscape(k.project))
+		_, err := cloud.get(ctx, projectURL, accountID, accessToken)
+		var status *statusError
+		if errors.As(err, &status) && status.status == http.StatusNotFound {
+			continue
+		} else if err != nil {
+			return k, err
+		}
+		owners = append(owners, slug)
+	}
+
+	switch len(owners) {
+	case 0:
+		cloud.log.WithFields(logrus.Fields{
+			"project": k.project,
+		}).Errorf("Project not found. Check user access")
+		return k, fmt.Errorf("Project not found. Check user access")
+	case 1:
+		k.workspace = owners[0]
+		return k, nil
+	default:
+		cloud.log.WithFields(logrus.Fields{
+			"project":    k.project,
+			"workspaces": owners,
+		}).Errorf("Project found in more than one workspace")
+		return k, fmt.Errorf("Project found in more than one workspace, use workspace/<name>/project/%v", k.project)
+	}
+}
+
+// statusError is an unsuccessful response from the Bitbucket Cloud API
+type statusError struct {
+	status  int
+	message string
+}
+
+// Error gives the status and the message of the response
+func (e *statusError) Error() string {
+	return fmt.Sprintf("Request failed with status %v: %v", e.status, e.message)
+}
+
+// HTTPStatus gives the status code of the response, so plugin callers can classify the error
+func (e *statusError) HTTPStatus() int {
+	return e.status
+}
+
+// get performs an authenticated GET request and returns the body of a successful response
+func (cloud Cloud) get(ctx context.Context, URL, accountID, accessToken string) (string, error) {
+	return cloud.send(ctx, "GET", URL, "", accountID, accessToken)
+}
+
+// send performs an authenticated request with an optional JSON body and returns the body of a successful response
+func (cloud Cloud) send(ctx context.Context, method, URL, body, accountID, accessToken string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, method, URL, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(accountID, accessToken)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := cloud.API.HTTP.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	bodyJSON := string(bodyBytes)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message := gjson.Get(bodyJSON, "error.message").String()
+		if message == "" {
+			message = http.StatusText(response.StatusCode)
+		}
+		return "", &statusError{status: response.StatusCode, message: message}
+	}
+	return bodyJSON, nil
+}
+
 // Authenticate checks the account ID and access tokens' validity for the kind defined
+// The requests are not stopped early, V2 gives the method taking a context
 func (cloud Cloud) Authenticate() (bool, error) {
+	err := cloud.authenticate(context.Background())
+	return err == nil, err
+}
 
-	kindSplit := strings.SplitN(cloud.kind, "/", 2)
-	kindType := kindSplit[0]
-	kindKey := kindSplit[1]
+// authenticate checks that the credentials can access the workspace, project or user of the kind,
+// with the requests stopping when ctx is done. Users are checked through the go-bitbucket SDK,
+// which takes no context
+func (cloud Cloud) authenticate(ctx context.Context) error {
+
+	accountID, accessToken, err := cloud.Credentials()
+	if err != nil {
+		cloud.log.Errorf("Failed to authenticate")
+		return err
+	}
 
-	accountID, _, err := cloud.Credentials()
+	k, err := cloud.kindOf(ctx, accountID, accessToken)
 	if err != nil {
-		log.Errorf("Failed to authenticate")
-		return false, err
+		cloud.log.WithFields(logrus.Fields{
+			"kind": k.kindType,
+		}).Errorf("Failed to authenticate")
+		return err
 	}
 
-	switch kindType {
+	switch k.kindType {
 	case "project":
-		/*
-			The go-bitbucket SDK does not cover all API endpoints for some reason
-			Will have to get list of projects and search for the project key
-		*/
+		// kindOf only finds the workspace of projects the current user can access
+		return nil
 
-		// Get list of projects user has access to
-		result, err := cloud.API.Teams.Projects(accountID)
+	case "user":
+		// Check if current user can access the repos of the user mentioned (kindKey) in config
+		_, err := cloud.API.Teams.Repositories(k.workspace)
 		if err != nil {
-			log.Errorf("Failed to get projects")
-			return false, err
+			cloud.log.WithFields(logrus.Fields{
+				"user": k.workspace,
+			}).Errorf("User authentication failed")
+			return err
 		}
+		return nil
 
-		// Check if mentioned project is in the list
-		passed := false
-		values := result.(map[string]interface{})["values"].([]interface{})
-		for _, v := range values {
-			passed = kindKey == v.(map[string]interface{})["key"]
-			if passed {
-				return passed, nil
-			}
+	case "workspace":
+		// Check if current user is a member of the workspace mentioned in config
+		workspaceURL := fmt.Sprintf("%v/workspaces/%v", cloud.apiBaseURL, k.workspace)
+		_, err := cloud.get(ctx, workspaceURL, accountID, accessToken)
+		if err != nil {
+			cloud.log.WithFields(logrus.Fields{
+				"workspace": k.workspace,
+			}).Errorf("Workspace not found. Check user access")
+			return err
 		}
-		log.WithFields(logrus.Fields{
-			"project": kindKey,
-		}).Errorf("Project not found. Check user access")
-		return false, fmt.Errorf("Project not found. Check user access")
+		return nil
 
-	case "user":
-		// Check if current user can access the repos of the user mentioned (kindKey) in config
-		_, err := cloud.API.Teams.Repositories(kindKey)
+	case "workspace-project":
+		// Check if current user can access the project in the workspace mentioned in config
+		projectURL := fmt.Sprintf("%v/workspaces/%v/projects/%v", cloud.apiBaseURL, k.workspace, k.project)
+		_, err := cloud.get(ctx, projectURL, accountID, accessToken)
 		if err != nil {
-			log.WithFields(logrus.Fields{
-				"user": kindKey,
-			}).Errorf("User authentication failed")
-			return false, err
+			cloud.log.WithFields(logrus.Fields{
+				"workspace": k.workspace,
+				"project":   k.project,
+			}).Errorf("Project not found. Check user access")
+			return err
 		}
-		return true, nil
+		return nil
 
 	default:
-		// Mentioned kind is unsupported
-		log.WithFields(logrus.Fields{
-			"kind": kindType,
+		cloud.log.WithFields(logrus.Fields{
+			"kind": k.kindType,
 		}).Errorf("Unsupported kind")
-		return false, fmt.Errorf("Unsupported kind")
+		return fmt.Errorf("Unsupported kind")
 	}
 }
 
-// Repositories queries the API and returns a list of repositories mentioned by the kind
-func (cloud Cloud) Repositories(metadata bool) ([]common.Repository, error) {
+// wikiURL gives the clone URL of a repository's wiki, which is served under the repository
+func wikiURL(cloneURL string) string {
+	return strings.TrimSuffix(cloneURL, ".git") + ".git/wiki"
+}
 
-	kindSplit := strings.SplitN(cloud.kind, "/", 2)
-	kindType := kindSplit[0]
-	kindKey := kindSplit[1]
+// allRepositories follows the 'next' links of paginated results and gives a list of all the repos
+func (cloud Cloud) allRepositories(ctx context.Context, workspace, project, accountID, accessToken string) ([]common.Repository, error) {
 
-	accountID, _, err := cloud.Credentials()
-	if err != nil {
-		log.Errorf("Failed to get repositories")
-		return nil, err
+	query := url.Values{}
+	query.Set("role", "member")
+	query.Set("pagelen", fmt.Sprint(pageLength))
+	if project != "" {
+		query.Set("q", fmt.Sprintf(`project.key="%v"`, project))
 	}
+	nextURL := fmt.Sprintf("%v/repositories/%v?%v", cloud.apiBaseURL, url.PathEscape(workspace), query.Encode())
 
-	switch kindType {
-	case "project":
-		ro := bitbucket.RepositoriesOptions{Owner: accountID, Role: "member"}
-		result, err := cloud.API.Repositories.ListForAccount(&ro)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"project": kindKey,
-			}).Errorf("Failed to get project repositories")
-			return nil, err
-		}
+	repositories := []common.Repository{}
+	visited := map[string]bool{}
 
-		repositories := []common.Repository{}
-		for _, repo := range result.Items {
-			if repo.Project.Key == kindKey {
-
-				repoBytes, _ := json.MarshalIndent(repo, "", "  ")
-				repoJSON := string(repoBytes)
-				// fmt.Println(repoJSON)
-				// Get all metadata for the repository
-				httpCloneLink := gjson.Get(repoJSON, `Links.clone.#(name%"http*").href`).String()
-				slug := gjson.Get(repoJSON, `Slug`).String()
-				description := gjson.Get(repoJSON, `Description`).String()
-
-				newRepo := common.Repository{
-					Slug:        slug,
-					Source:      httpCloneLink,
-					Description: description,
-				}
-				repositories = append(repositories, newRepo)
-			}
+	for nextURL != "" {
+		// Guard against a page linking back to one already fetched
+		if visited[nextURL] {
+			return nil, fmt.Errorf("Pagination loop detected")
 		}
-		repositories = utils.FilterRepos(repositories, cloud.filters.include, cloud.filters.exclude)
-		return repositories, nil
+		visited[nextURL] = true
 
-	case "user":
-		ro := bitbucket.RepositoriesOptions{Owner: kindKey, Role: "member"}
-		result, err := cloud.API.Repositories.ListForAccount(&ro)
+		bodyJSON, err := cloud.get(ctx, nextURL, accountID, accessToken)
 		if err != nil {
-			log.WithFields(logrus.Fields{
-				"user": kindKey,
-			}).Errorf("Failed to get user repositories")
 			return nil, err
 		}
 
-		repositories := []common.Repository{}
-		for _, repo := range result.Items {
-			repoBytes, _ := json.MarshalIndent(repo, "", "  ")
-			repoJSON := string(repoBytes)
+		// Continue fetching pages until there is no next page
+		nextURL = gjson.Get(bodyJSON, "next").String()
+
+		repos := gjson.Get(bodyJSON, "values").Array()
+
+		for _, repoJSON := range repos {
 
-			httpCloneLink := gjson.Get(repoJSON, `Links.clone.#(name%"http*").href`).String()
-			slug := gjson.Get(repoJSON, `Slug`).String()
-			description := gjson.Get(repoJSON, `Description`).String()
+			// Get repo metadata
+			httpCloneLink := gjson.Get(repoJSON.String(), `links.clone.#(name%"http*").href`).String()
+			slug := gjson.Get(repoJSON.String(), `slug`).String()
+			description := gjson.Get(repoJSON.String(), `description`).String()
+			private := gjson.Get(repoJSON.String(), `is_private`).Bool()
+			defaultBranch := gjson.Get(repoJSON.String(), `mainbranch.name`).String()
+			website := gjson.Get(repoJSON.String(), `website`).String()
+			hasWiki := gjson.Get(repoJSON.String(), `has_wiki`).Bool()
 
+			// Bitbucket Cloud has no repository labels, so Topics stays nil
 			newRepo := common.Repository{
-				Slug:        slug,
-				Source:      httpCloneLink,
-				Description: description,
+				Slug:          slug,
+				Source:        httpCloneLink,
+				Description:   description,
+				Private:       private,
+				DefaultBranch: defaultBranch,
+				Homepage:      website,
+			}
+			if hasWiki {
+				newRepo.Wiki = wikiURL(httpCloneLink)
 			}
 			repositories = append(repositories, newRepo)
 		}
-		repositories = utils.FilterRepos(repositories, cloud.filters.include, cloud.filters.exclude)
-		return repositories, nil
+	}
+	return repositories, nil
+}
 
-	default:
-		log.WithFields(logrus.Fields{
-			"kind": kindType,
-		}).Errorf("Unsupported kind")
-		return nil, fmt.Errorf("Unsupported kind")
+// Repositories queries the API and returns a list of repositories mentioned by the kind
+// The requests are not stopped early, V2 gives the method taking a context
+func (cloud Cloud) Repositories(metadata bool) ([]common.Repository, error) {
+	return cloud.repositories(context.Background())
+}
+
+// repositories lists the repositories of the kind matching the filters, with the requests
+// stopping when ctx is done. Bitbucket Cloud has no metadata that takes more requests
+func (cloud Cloud) repositories(ctx context.Context) ([]common.Repository, error) {
+
+	accountID, accessToken, err := cloud.Credentials()
+	if err != nil {
+		cloud.log.Errorf("Failed to get repositories")
+		return nil, err
 	}
+
+	k, err := cloud.kindOf(ctx, accountID, accessToken)
+	if err != nil {
+		cloud.log.WithFields(logrus.Fields{
+			"kind": k.kindType,
+		}).Errorf("Failed to get repositories")
+		return nil, err
+	}
+
+	// abstract over pagination
+	repositories, err := cloud.allRepositories(ctx, k.workspace, k.project, accountID, accessToken)
+	if err != nil {
+		cloud.log.WithFields(logrus.Fields{
+			"kind":      k.kindType,
+			"workspace": k.workspace,
+			"project":   k.project,
+			"error":     err.Error(),
+		}).Errorf("Failed to get repositories")
+		return nil, err
+	}
+
+	repositories = utils.FilterRepos(repositories, cloud.filters.include, cloud.filters.exclude)
+	return repositories, nil
 }
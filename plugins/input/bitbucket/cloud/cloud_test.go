@@ -1,13 +1,20 @@
 package cloud_test
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
+	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
 	mock "github.com/parinithshekar/gitsink/mocks/bbcloud"
 	bbcloud "github.com/parinithshekar/gitsink/plugins/input/bitbucket/cloud"
 	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
 )
 
 var (
@@ -149,7 +156,7 @@ func TestAuthenticate(t *testing.T) {
 			// Set mock client
 			mockInput := input.(*bbcloud.Cloud)
 			mockInput.API.Teams = &mock.Teams{AccountID: tc.AccountID, AccessToken: tc.AccessToken}
-			mockInput.API.Repositories = &mock.Repositories{AccountID: tc.AccountID, AccessToken: tc.AccessToken}
+			mockInput.API.HTTP = &mock.HTTP{}
 
 			// Call Authenticate
 			actualResult, err := mockInput.Authenticate()
@@ -179,6 +186,13 @@ func TestAuthenticate(t *testing.T) {
 		"Unsupported kind":     {"proj/GIGA", false, true},
 		"Correct username":     {"user/username", true, false},
 		"Correct project name": {"project/TEST", true, false},
+		"Team project":         {"project/EDGE", true, false},
+		"Ambiguous project":    {"project/CORE", false, true},
+		"Wrong workspace":      {"workspace/nope", false, true},
+		"Correct workspace":    {"workspace/team", true, false},
+		"Wrong ws project":     {"workspace/team/project/NOYA", false, true},
+		"Correct ws project":   {"workspace/team/project/CORE", true, false},
+		"Malformed workspace":  {"workspace/team/proj/CORE", false, true},
 	}
 
 	for tcName, tc := range kindCases {
@@ -196,7 +210,7 @@ func TestAuthenticate(t *testing.T) {
 			// Set mock client
 			mockInput := input.(*bbcloud.Cloud)
 			mockInput.API.Teams = &mock.Teams{AccountID: "username", AccessToken: "token"}
-			mockInput.API.Repositories = &mock.Repositories{AccountID: "username", AccessToken: "token"}
+			mockInput.API.HTTP = &mock.HTTP{}
 
 			// Call Authenticate()
 			actualResult, err := mockInput.Authenticate()
@@ -221,10 +235,17 @@ func TestRepositories(t *testing.T) {
 		ExpectedResult, ExpectedError bool
 	}{
 		"Wrong username":       {"user/unamebad", false, true},
-		"Wrong project key":    {"project/NOYA", false, false},
+		"Wrong project key":    {"project/NOYA", false, true},
 		"Unsupported kind":     {"proj/GIGA", false, true},
 		"Correct username":     {"user/username", true, false},
 		"Correct project name": {"project/TEST", true, false},
+		"Team project":         {"project/EDGE", true, false},
+		"Ambiguous project":    {"project/CORE", false, true},
+		"Wrong workspace":      {"workspace/nope", false, true},
+		"Correct workspace":    {"workspace/team", true, false},
+		"Wrong ws project":     {"workspace/team/project/NOYA", false, false},
+		"Correct ws project":   {"workspace/team/project/CORE", true, false},
+		"Malformed workspace":  {"workspace/team/proj/CORE", false, true},
 	}
 
 	os.Setenv(envAccountID, "username")
@@ -247,7 +268,7 @@ func TestRepositories(t *testing.T) {
 			// Set mock client
 			mockInput := input.(*bbcloud.Cloud)
 			mockInput.API.Teams = &mock.Teams{AccountID: "username", AccessToken: "token"}
-			mockInput.API.Repositories = &mock.Repositories{AccountID: "username", AccessToken: "token"}
+			mockInput.API.HTTP = &mock.HTTP{}
 
 			// Call Repositories()
 			result, err := mockInput.Repositories(true)
@@ -264,3 +285,234 @@ func TestRepositories(t *testing.T) {
 		})
 	}
 }
+
+func TestRepositoriesPagination(t *testing.T) {
+	var input plugins.Input
+	var err error
+
+	cases := map[string]struct {
+		Kind          string
+		ExpectedSlugs []string
+	}{
+		"User across pages":      {"user/username", []string{"repo-1", "repo-2", "repo-3", "repo-4"}},
+		"Project across pages":   {"project/TEST", []string{"repo-2", "repo-3", "repo-4"}},
+		"Project of a workspace": {"project/EDGE", []string{"team-repo-3"}},
+		"Workspace across pages": {"workspace/team", []string{"team-repo-1", "team-repo-2", "team-repo-3"}},
+		"Workspace project":      {"workspace/team/project/CORE", []string{"team-repo-1", "team-repo-2"}},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+
+			tcSource := source
+			tcSource.Kind = tc.Kind
+
+			// New
+			input, err = bbcloud.New(tcSource)
+			if err != nil {
+				t.Error("Plugin initiation failed")
+			}
+
+			// Set mock client
+			mockInput := input.(*bbcloud.Cloud)
+			mockInput.API.Teams = &mock.Teams{AccountID: "username", AccessToken: "token"}
+			mockInput.API.HTTP = &mock.HTTP{}
+
+			// Call Repositories()
+			result, err := mockInput.Repositories(true)
+			if err != nil {
+				t.Fatalf("%v - Unexpected error: %v", tcName, err)
+			}
+
+			// Validate every page was collected in order, with metadata
+			var actualSlugs []string
+			for _, repo := range result {
+				actualSlugs = append(actualSlugs, repo.Slug)
+				if !repo.Private || repo.DefaultBranch != "main" || repo.Homepage == "" || repo.Topics != nil {
+					t.Errorf("%v - Unexpected metadata: %+v", tcName, repo)
+				}
+			}
+			if strings.Join(actualSlugs, ",") != strings.Join(tc.ExpectedSlugs, ",") {
+				t.Errorf("%v - Expected repos: %v | Actual repos: %v", tcName, tc.ExpectedSlugs, actualSlugs)
+			}
+		})
+	}
+}
+
+func TestPullRequests(t *testing.T) {
+	cases := map[string]struct {
+		States      []string
+		ExpectedIDs []int64
+	}{
+		"Open only":  {[]string{"open"}, []int64{1}},
+		"All states": {[]string{"open", "merged", "declined"}, []int64{1, 2, 3}},
+		"Closed":     {[]string{"merged", "declined"}, []int64{2, 3}},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	input, err := bbcloud.New(source)
+	if err != nil {
+		t.Fatal("Plugin initiation failed")
+	}
+	input.API.HTTP = &mock.HTTP{}
+
+	for tcName, tc := range cases {
+		pullRequests, err := input.PullRequests(context.Background(), common.Repository{Slug: "repo-2"}, tc.States)
+		if err != nil {
+			t.Fatalf("%v - Unexpected error: %v", tcName, err)
+		}
+
+		var actualIDs []int64
+		for _, pullRequest := range pullRequests {
+			actualIDs = append(actualIDs, pullRequest.ID)
+		}
+		if !reflect.DeepEqual(actualIDs, tc.ExpectedIDs) {
+			t.Errorf("%v - Expected pull requests: %v | Actual: %v", tcName, tc.ExpectedIDs, actualIDs)
+		}
+	}
+
+	pullRequests, _ := input.PullRequests(context.Background(), common.Repository{Slug: "repo-2"}, []string{"open"})
+	pullRequest := pullRequests[0]
+	if pullRequest.State != common.PullRequestOpen || pullRequest.SourceBranch != "feature/login" || pullRequest.TargetBranch != "main" {
+		t.Errorf("Unexpected pull request: %+v", pullRequest)
+	}
+	if pullRequest.Author.Name != "alice" || len(pullRequest.Reviewers) != 1 || pullRequest.Reviewers[0].Name != "bob" {
+		t.Errorf("Unexpected author or reviewers: %+v %+v", pullRequest.Author, pullRequest.Reviewers)
+	}
+	// The deleted comment is left out
+	if len(pullRequest.Comments) != 2 || pullRequest.Comments[1].Path != "login.go" || pullRequest.Comments[1].Line != 42 {
+		t.Errorf("Unexpected comments: %+v", pullRequest.Comments)
+	}
+}
+
+func TestLockRepository(t *testing.T) {
+	cases := map[string]struct {
+		Existing             []map[string]interface{}
+		ExpectedRestrictions int
+	}{
+		"Unlocked": {nil, 1},
+		"Other restrictions": {[]map[string]interface{}{
+			{"kind": "push", "pattern": "main", "users": []interface{}{}, "groups": []interface{}{}},
+			{"kind": "push", "pattern": "*", "users": []interface{}{map[string]interface{}{"nickname": "alice"}}, "groups": []interface{}{}},
+		}, 3},
+		"Already locked": {[]map[string]interface{}{
+			{"kind": "push", "pattern": "*", "users": []interface{}{}, "groups": []interface{}{}},
+		}, 1},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	input, err := bbcloud.New(source)
+	if err != nil {
+		t.Fatal("Plugin initiation failed")
+	}
+	recorder := &recordingHTTP{HTTP: &mock.HTTP{}}
+	input.API.HTTP = recorder
+	defer func() { mock.BranchRestrictions = map[string][]map[string]interface{}{} }()
+
+	var locker plugins.SourceLocker = input
+	ctx := context.WithValue(context.Background(), runKey{}, "call")
+	for tcName, tc := range cases {
+		mock.BranchRestrictions = map[string][]map[string]interface{}{"username/repo-2": tc.Existing}
+
+		// Locking again finds the restriction it made
+		for i := 0; i < 2; i++ {
+			err = locker.LockRepository(ctx, common.Repository{Slug: "repo-2"})
+			if err != nil {
+				t.Fatalf("%v - Unexpected error: %v", tcName, err)
+			}
+		}
+
+		restrictions := mock.BranchRestrictions["username/repo-2"]
+		if len(restrictions) != tc.ExpectedRestrictions {
+			t.Errorf("%v - Expected restrictions: %v | Actual: %v", tcName, tc.ExpectedRestrictions, restrictions)
+		}
+		last := restrictions[len(restrictions)-1]
+		if last["kind"] != "push" || last["pattern"] != "*" {
+			t.Errorf("%v - Unexpected restriction: %v", tcName, last)
+		}
+	}
+	for _, run := range recorder.runs {
+		if run != "call" {
+			t.Errorf("Expected requests with the context of the call | Actual: %v", run)
+		}
+	}
+}
+
+// runKey marks the contexts given to the plugin calls
+type runKey struct{}
+
+// recordingHTTP records the run of the context of every request to the mock API
+type recordingHTTP struct {
+	*mock.HTTP
+	runs []interface{}
+}
+
+func (h *recordingHTTP) Do(req *http.Request) (*http.Response, error) {
+	h.runs = append(h.runs, req.Context().Value(runKey{}))
+	return h.HTTP.Do(req)
+}
+
+func TestV2(t *testing.T) {
+	cases := map[string]struct {
+		AccessToken, Kind string
+		ExpectedKind      error
+	}{
+		"Authenticated":      {"token", "workspace/team", nil},
+		"Wrong access token": {"tochen", "workspace/team", v2.ErrUnauthorized},
+		"Missing workspace":  {"token", "workspace/nope", v2.ErrNotFound},
+	}
+
+	os.Setenv(envAccountID, "username")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			os.Setenv(envAccessToken, tc.AccessToken)
+
+			tcSource := source
+			tcSource.Kind = tc.Kind
+			input, err := bbcloud.New(tcSource)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			recorder := &recordingHTTP{HTTP: &mock.HTTP{}}
+			input.API.Teams = &mock.Teams{AccountID: "username", AccessToken: tc.AccessToken}
+			input.API.HTTP = recorder
+
+			ctx := context.WithValue(context.Background(), runKey{}, "call")
+
+			err = input.V2().Authenticate(ctx)
+			if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+				t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+			}
+			if err == nil {
+				repos, err := input.V2().Repositories(ctx, v2.ListOptions{})
+				if err != nil || len(repos) == 0 {
+					t.Errorf("%v - Expected repositories | Actual: %v, %v", tcName, repos, err)
+				}
+			}
+			if len(recorder.runs) == 0 {
+				t.Errorf("%v - Expected requests to the API", tcName)
+			}
+			for _, run := range recorder.runs {
+				if run != "call" {
+					t.Errorf("%v - Expected requests with the context of the call | Actual: %v", tcName, run)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// lockRestriction lets nobody push to any branch
+const lockRestriction = `{"kind":"push","branch_match_kind":"glob","pattern":"*","users":[],"groups":[]}`
+
+// LockRepository makes the repository read-only after the cut-over
+// Bitbucket Cloud cannot archive repositories, so a branch restriction stops all pushes instead
+func (cloud Cloud) LockRepository(ctx context.Context, repo common.Repository) error {
+
+	accountID, accessToken, err := cloud.Credentials()
+	if err != nil {
+		return err
+	}
+
+	k, err := cloud.kindOf(ctx, accountID, accessToken)
+	if err != nil {
+		return err
+	}
+	restrictionsURL := fmt.Sprintf("%v/repositories/%v/%v/branch-restrictions", cloud.apiBaseURL, url.PathEscape(k.workspace), url.PathEscape(repo.Slug))
+
+	// A restriction from an earlier finalize already locks the repository
+	restrictions, err := cloud.allValues(ctx, restrictionsURL+"?pagelen=100", accountID, accessToken)
+	if err != nil {
+		return err
+	}
+	for _, restriction := range restrictions {
+		if restriction.Get("kind").String() == "push" && restriction.Get("pattern").String() == "*" &&
+			len(restriction.Get("users").Array()) == 0 && len(restriction.Get("groups").Array()) == 0 {
+			return nil
+		}
+	}
+
+	_, err = cloud.send(ctx, "POST", restrictionsURL, lockRestriction, accountID, accessToken)
+	if err != nil {
+		return err
+	}
+	cloud.log.WithFields(logrus.Fields{
+		"repository": repo.Slug,
+	}).Infof("Pushes to source repository restricted")
+	return nil
+}
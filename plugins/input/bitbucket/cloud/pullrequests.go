@@ -0,0 +1,138 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	gjson "github.com/tidwall/gjson"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// allValues follows the 'next' links of paginated results and gives the values of all the pages
+func (cloud Cloud) allValues(ctx context.Context, nextURL, accountID, accessToken string) ([]gjson.Result, error) {
+	values := []gjson.Result{}
+	visited := map[string]bool{}
+
+	for nextURL != "" {
+		// Guard against a page linking back to one already fetched
+		if visited[nextURL] {
+			return nil, fmt.Errorf("Pagination loop detected")
+		}
+		visited[nextURL] = true
+
+		bodyJSON, err := cloud.get(ctx, nextURL, accountID, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		nextURL = gjson.Get(bodyJSON, "next").String()
+		values = append(values, gjson.Get(bodyJSON, "values").Array()...)
+	}
+	return values, nil
+}
+
+// parseUser reads a Bitbucket Cloud user object, which has no email address
+func parseUser(userJSON gjson.Result) common.User {
+	return common.User{
+		Name:        userJSON.Get("nickname").String(),
+		DisplayName: userJSON.Get("display_name").String(),
+	}
+}
+
+// parseTime reads the ISO 8601 dates Bitbucket Cloud uses
+func parseTime(date gjson.Result) time.Time {
+	parsed, _ := time.Parse(time.RFC3339Nano, date.String())
+	return parsed.UTC()
+}
+
+// PullRequests reads the pull requests of the repository in the given states, oldest first,
+// with their reviewers and comments
+func (cloud Cloud) PullRequests(ctx context.Context, repo common.Repository, states []string) ([]common.PullRequest, error) {
+
+	accountID, accessToken, err := cloud.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := cloud.kindOf(ctx, accountID, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("pagelen", "50")
+	query.Set("sort", "created_on")
+	for _, state := range states {
+		query.Add("state", strings.ToUpper(state))
+	}
+	repoURL := fmt.Sprintf("%v/repositories/%v/%v", cloud.apiBaseURL, url.PathEscape(k.workspace), url.PathEscape(repo.Slug))
+
+	values, err := cloud.allValues(ctx, repoURL+"/pullrequests?"+query.Encode(), accountID, accessToken)
+	if err != nil {
+		cloud.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+			"error":      err.Error(),
+		}).Errorf("Failed to get pull requests")
+		return nil, err
+	}
+
+	pullRequests := []common.PullRequest{}
+	for _, prJSON := range values {
+		pullRequest := common.PullRequest{
+			ID:           prJSON.Get("id").Int(),
+			Title:        prJSON.Get("title").String(),
+			Description:  prJSON.Get("description").String(),
+			State:        strings.ToLower(prJSON.Get("state").String()),
+			URL:          prJSON.Get("links.html.href").String(),
+			Author:       parseUser(prJSON.Get("author")),
+			SourceBranch: prJSON.Get("source.branch.name").String(),
+			TargetBranch: prJSON.Get("destination.branch.name").String(),
+			Created:      parseTime(prJSON.Get("created_on")),
+		}
+		prURL := fmt.Sprintf("%v/pullrequests/%v", repoURL, pullRequest.ID)
+
+		// Reviewers are only in the full pull request, not in the list
+		bodyJSON, err := cloud.get(ctx, prURL, accountID, accessToken)
+		if err != nil {
+			cloud.log.WithFields(logrus.Fields{
+				"repository":  repo.Slug,
+				"pullRequest": pullRequest.ID,
+				"error":       err.Error(),
+			}).Errorf("Failed to get pull request")
+			return nil, err
+		}
+		for _, reviewer := range gjson.Get(bodyJSON, "reviewers").Array() {
+			pullRequest.Reviewers = append(pullRequest.Reviewers, parseUser(reviewer))
+		}
+
+		comments, err := cloud.allValues(ctx, prURL+"/comments?sort=created_on", accountID, accessToken)
+		if err != nil {
+			cloud.log.WithFields(logrus.Fields{
+				"repository":  repo.Slug,
+				"pullRequest": pullRequest.ID,
+				"error":       err.Error(),
+			}).Errorf("Failed to get pull request comments")
+			return nil, err
+		}
+		for _, commentJSON := range comments {
+			if commentJSON.Get("deleted").Bool() {
+				continue
+			}
+			pullRequest.Comments = append(pullRequest.Comments, common.Comment{
+				ID:      commentJSON.Get("id").String(),
+				Author:  parseUser(commentJSON.Get("user")),
+				Body:    commentJSON.Get("content.raw").String(),
+				Created: parseTime(commentJSON.Get("created_on")),
+				Path:    commentJSON.Get("inline.path").String(),
+				Line:    int(commentJSON.Get("inline.to").Int()),
+			})
+		}
+
+		pullRequests = append(pullRequests, pullRequest)
+	}
+	return pullRequests, nil
+}
@@ -0,0 +1,49 @@
+package cloud
+
+import (
+	"context"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+
+	common "github.com/parinithshekar/gitsink/common"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// native is the Bitbucket Cloud source with the methods of the second plugin interfaces
+// Requests are made with the context of the call, so calls can run concurrently
+type native struct {
+	cloud *Cloud
+}
+
+// V2 gives the source with the methods of the second plugin interfaces
+func (cloud *Cloud) V2() v2.Input {
+	return native{cloud: cloud}
+}
+
+// Authenticate checks that the credentials can access the workspace, project or user of the kind
+func (source native) Authenticate(ctx context.Context) error {
+	if _, _, err := source.cloud.Credentials(); err != nil {
+		return v2.Wrap("authenticate source", "", v2.ErrCredentials, err)
+	}
+	return v2.Wrap("authenticate source", "", v2.ErrFailed, source.cloud.authenticate(ctx))
+}
+
+// Repositories lists the repositories of the kind matching the filters
+// Bitbucket Cloud has no topics, so the metadata option changes nothing
+func (source native) Repositories(ctx context.Context, options v2.ListOptions) ([]common.Repository, error) {
+	repos, err := source.cloud.repositories(ctx)
+	if err != nil {
+		return nil, v2.Wrap("list source repositories", "", v2.ErrFailed, err)
+	}
+	return repos, nil
+}
+
+// Auth gives the credentials of the source as git basic auth
+func (source native) Auth() (transport.AuthMethod, error) {
+	return v2.BasicAuth("source credentials", source.cloud.Credentials)
+}
+
+// Capabilities tells what Bitbucket Cloud sources support
+func (source native) Capabilities() v2.Capabilities {
+	return source.cloud.Capabilities()
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	gjson "github.com/tidwall/gjson"
+)
+
+// Error represents a class of failure reported by the Bitbucket Server API
+type Error string
+
+// Error returns the error as a string
+func (e Error) Error() string { return string(e) }
+
+const (
+	// ErrUnauthorized is returned when the credentials are rejected
+	ErrUnauthorized = Error("Bitbucket Server rejected the credentials")
+	// ErrForbidden is returned when the user lacks permission for the resource
+	ErrForbidden = Error("Bitbucket Server denied access to the resource")
+	// ErrNotFound is returned when the project, user or repository does not exist
+	ErrNotFound = Error("Bitbucket Server resource not found")
+	// ErrUnexpectedStatus is returned for any other unsuccessful status code
+	ErrUnexpectedStatus = Error("Bitbucket Server returned an unexpected status")
+	// ErrMalformedPage is returned when a paged response cannot be walked safely
+	ErrMalformedPage = Error("Bitbucket Server returned a malformed page")
+)
+
+// APIErrorDetail is one entry of the 'errors' array in a Bitbucket Server error response
+type APIErrorDetail struct {
+	Context       string
+	Message       string
+	ExceptionName string
+}
+
+// APIError is an unsuccessful response from the Bitbucket Server API
+type APIError struct {
+	StatusCode int
+	Details    []APIErrorDetail
+}
+
+// Error joins the messages reported by the API
+func (e *APIError) Error() string {
+	var messages []string
+	for _, detail := range e.Details {
+		if detail.Message != "" {
+			messages = append(messages, detail.Message)
+		}
+	}
+	if len(messages) == 0 {
+		messages = append(messages, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%v (status %v): %v", e.Unwrap().Error(), e.StatusCode, strings.Join(messages, "; "))
+}
+
+// Unwrap maps the status code to one of the package errors so callers can use errors.Is
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return ErrUnexpectedStatus
+	}
+}
+
+// HTTPStatus gives the status code of the response, so plugin callers can classify the error
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// newAPIError decodes the 'errors' array of a Bitbucket Server error response body
+func newAPIError(statusCode int, bodyJSON string) *APIError {
+	apiError := &APIError{StatusCode: statusCode}
+
+	for _, detailJSON := range gjson.Get(bodyJSON, "errors").Array() {
+		apiError.Details = append(apiError.Details, APIErrorDetail{
+			Context:       detailJSON.Get("context").String(),
+			Message:       detailJSON.Get("message").String(),
+			ExceptionName: detailJSON.Get("exceptionName").String(),
+		})
+	}
+	return apiError
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// LockRepository archives the repository so it is read-only after the cut-over
+// Archiving needs Bitbucket Server 8.0 or later, older versions reject the request
+func (server *Server) LockRepository(ctx context.Context, repo common.Repository) error {
+
+	accountID, accessToken, err := server.Credentials()
+	if err != nil {
+		return err
+	}
+
+	repoURL, err := server.repositoryURL(repo.Slug)
+	if err != nil {
+		return err
+	}
+
+	_, err = server.send(ctx, "PUT", repoURL, `{"archived":true}`, accountID, accessToken)
+	if err != nil {
+		return err
+	}
+	server.log.WithFields(logrus.Fields{
+		"repository": repo.Slug,
+	}).Infof("Source repository archived")
+	return nil
+}
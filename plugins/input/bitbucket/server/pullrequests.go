@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	gjson "github.com/tidwall/gjson"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// repositoryURL gives the API URL of a repository of the kind
+// Personal repositories live in the user's ~ project
+func (server *Server) repositoryURL(slug string) (string, error) {
+	kindSplit := strings.Split(server.kind, "/")
+	kindType := kindSplit[0]
+	kindKey := kindSplit[1]
+
+	switch kindType {
+	case "project":
+		return fmt.Sprintf("%v/projects/%v/repos/%v", server.apiBaseURL, kindKey, slug), nil
+	case "user":
+		return fmt.Sprintf("%v/projects/~%v/repos/%v", server.apiBaseURL, kindKey, slug), nil
+	default:
+		return "", fmt.Errorf("Unsupported kind")
+	}
+}
+
+// parseUser reads a Bitbucket Server user object
+func parseUser(userJSON gjson.Result) common.User {
+	return common.User{
+		Name:        userJSON.Get("name").String(),
+		DisplayName: userJSON.Get("displayName").String(),
+		Email:       userJSON.Get("emailAddress").String(),
+	}
+}
+
+// parseTime reads the milliseconds since epoch Bitbucket Server uses for dates
+func parseTime(millis gjson.Result) time.Time {
+	return time.Unix(0, millis.Int()*int64(time.Millisecond)).UTC()
+}
+
+// parseComment reads a comment and its replies, which are nested in the comment
+func parseComment(commentJSON gjson.Result, anchor gjson.Result) []common.Comment {
+	comments := []common.Comment{{
+		ID:      commentJSON.Get("id").String(),
+		Author:  parseUser(commentJSON.Get("author")),
+		Body:    commentJSON.Get("text").String(),
+		Created: parseTime(commentJSON.Get("createdDate")),
+		Path:    anchor.Get("path").String(),
+		Line:    int(anchor.Get("line").Int()),
+	}}
+	for _, reply := range commentJSON.Get("comments").Array() {
+		comments = append(comments, parseComment(reply, gjson.Result{})...)
+	}
+	return comments
+}
+
+// PullRequests reads the pull requests of the repository in the given states, oldest first,
+// with their reviewers and comments
+func (server *Server) PullRequests(ctx context.Context, repo common.Repository, states []string) ([]common.PullRequest, error) {
+
+	accountID, accessToken, err := server.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	repoURL, err := server.repositoryURL(repo.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, state := range states {
+		wanted[state] = true
+	}
+
+	values, err := server.allPages(ctx, repoURL+"/pull-requests?state=ALL&order=OLDEST", accountID, accessToken)
+	if err != nil {
+		server.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+			"error":      err.Error(),
+		}).Errorf("Failed to get pull requests")
+		return nil, err
+	}
+
+	pullRequests := []common.PullRequest{}
+	for _, prJSON := range values {
+		state := strings.ToLower(prJSON.Get("state").String())
+		if !wanted[state] {
+			continue
+		}
+
+		pullRequest := common.PullRequest{
+			ID:           prJSON.Get("id").Int(),
+			Title:        prJSON.Get("title").String(),
+			Description:  prJSON.Get("description").String(),
+			State:        state,
+			URL:          prJSON.Get("links.self.0.href").String(),
+			Author:       parseUser(prJSON.Get("author.user")),
+			SourceBranch: prJSON.Get("fromRef.displayId").String(),
+			TargetBranch: prJSON.Get("toRef.displayId").String(),
+			Created:      parseTime(prJSON.Get("createdDate")),
+		}
+		for _, reviewer := range prJSON.Get("reviewers").Array() {
+			pullRequest.Reviewers = append(pullRequest.Reviewers, parseUser(reviewer.Get("user")))
+		}
+
+		activitiesURL := fmt.Sprintf("%v/pull-requests/%v/activities", repoURL, pullRequest.ID)
+		activities, err := server.allPages(ctx, activitiesURL, accountID, accessToken)
+		if err != nil {
+			server.log.WithFields(logrus.Fields{
+				"repository":  repo.Slug,
+				"pullRequest": pullRequest.ID,
+				"error":       err.Error(),
+			}).Errorf("Failed to get pull request comments")
+			return nil, err
+		}
+		for _, activity := range activities {
+			if activity.Get("action").String() != "COMMENTED" || activity.Get("commentAction").String() != "ADDED" {
+				continue
+			}
+			pullRequest.Comments = append(pullRequest.Comments, parseComment(activity.Get("comment"), activity.Get("commentAnchor"))...)
+		}
+		// Activities are newest first
+		sort.SliceStable(pullRequest.Comments, func(i, j int) bool {
+			return pullRequest.Comments[i].Created.Before(pullRequest.Comments[j].Created)
+		})
+
+		pullRequests = append(pullRequests, pullRequest)
+	}
+	return pullRequests, nil
+}
+
+// UserEmail looks up the email address of a source user
+func (server *Server) UserEmail(ctx context.Context, name string) (string, error) {
+	accountID, accessToken, err := server.Credentials()
+	if err != nil {
+		return "", err
+	}
+
+	bodyJSON, err := server.get(ctx, fmt.Sprintf("%v/users/%v", server.apiBaseURL, name), accountID, accessToken)
+	if err != nil {
+		return "", err
+	}
+	email := gjson.Get(bodyJSON, "emailAddress").String()
+	if email == "" {
+		return "", fmt.Errorf("User has no visible email address")
+	}
+	return email, nil
+}
@@ -1,26 +1,33 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
-	"time"
 
 	logrus "github.com/sirupsen/logrus"
 	gjson "github.com/tidwall/gjson"
 
 	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
+	transport "github.com/parinithshekar/gitsink/common/transport"
 	utils "github.com/parinithshekar/gitsink/common/utils"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
 	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
 )
 
 var (
-	log = logger.New()
+	log = logger.Root()
 )
 
+// defaultContextPath is where Bitbucket Server is served unless config says otherwise
+const defaultContextPath = "/bitbucket"
+
 // APIClient defines the methods for the API in Server object
 type APIClient interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -36,7 +43,9 @@ type Server struct {
 		include []string
 		exclude []string
 	}
-	API APIClient
+	API       APIClient
+	transport http.RoundTripper
+	log       pkg.Logger
 }
 
 // Credentials fetches amd returns the accountID and accessToken from environment variables
@@ -46,7 +55,7 @@ func (server *Server) Credentials() (string, string, error) {
 
 	accountID, exists := os.LookupEnv(server.accountID)
 	if !exists {
-		log.WithFields(logrus.Fields{
+		server.log.WithFields(logrus.Fields{
 			"accountID": server.accountID,
 		}).Errorf("Account ID not found")
 		return "", "", fmt.Errorf("Account ID not found")
@@ -54,7 +63,7 @@ func (server *Server) Credentials() (string, string, error) {
 
 	accessToken, exists = os.LookupEnv(server.accessToken)
 	if !exists {
-		log.WithFields(logrus.Fields{
+		server.log.WithFields(logrus.Fields{
 			"accessToken": server.accessToken,
 		}).Errorf("Access Token not found")
 		return "", "", fmt.Errorf("Access Token not found")
@@ -64,19 +73,58 @@ func (server *Server) Credentials() (string, string, error) {
 }
 
 // setAPIClient builds and returns an object to facilitate calls to the API
-func (server *Server) setAPIClient(baseURL string) {
+func (server *Server) setAPIClient(source config.Source) error {
+
+	httpTransport, err := transport.New(source.HTTP)
+	if err != nil {
+		return err
+	}
+	server.transport = httpTransport
 
 	client := &http.Client{
-		Timeout: time.Second * 15,
+		Transport: metrics.Transport("input/bitbucket-server", httpTransport),
+		Timeout:   transport.Timeout(source.HTTP),
 	}
 
-	server.apiBaseURL = baseURL + "/bitbucket/rest/api/1.0"
+	server.apiBaseURL = strings.TrimSuffix(source.BaseURL, "/") + contextPath(source.ContextPath) + "/rest/api/1.0"
 	server.API = client
+	return nil
+}
+
+// contextPath normalizes the context path from config
+// Unset defaults to /bitbucket and "/" means the instance is served at the root context
+func contextPath(path string) string {
+	if path == "" {
+		return defaultContextPath
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// HTTPClient returns a client with the TLS and proxy options of the API client, for git operations
+// It has no overall timeout since clones and fetches of large repositories take longer than API
+// calls, its transport gives up on servers that do not answer within transport.ResponseTimeout
+func (server *Server) HTTPClient() *http.Client {
+	return &http.Client{Transport: server.transport}
+}
+
+// SetLogger has the plugin log through the logger of the run it is used in
+func (server *Server) SetLogger(log pkg.Logger) {
+	server.log = log
+}
+
+// Capabilities tells what Bitbucket Server sources support
+func (server *Server) Capabilities() v2.Capabilities {
+	return v2.Capabilities{LFS: true, PullRequests: true, Lock: true}
 }
 
 // New returns a new bitbucket-server object with metadata
 func New(source config.Source) (*Server, error) {
 	var server *Server = new(Server)
+	server.log = log
 
 	_, exists := os.LookupEnv(source.AccountID)
 	if !exists {
@@ -101,13 +149,28 @@ func New(source config.Source) (*Server, error) {
 	server.filters.include = source.Repositories.Include
 	server.filters.exclude = source.Repositories.Exclude
 
-	server.setAPIClient(source.BaseURL)
+	err := server.setAPIClient(source)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"baseURL": source.BaseURL,
+			"error":   err.Error(),
+		}).Errorf("Failed to set up API client")
+		return nil, err
+	}
 
 	return server, nil
 }
 
 // Authenticate checks the account ID and access tokens' validity for the kind defined
+// The requests are not stopped early, V2 gives the method taking a context
 func (server *Server) Authenticate() (bool, error) {
+	err := server.authenticate(context.Background())
+	return err == nil, err
+}
+
+// authenticate checks that the credentials can read the repositories of the kind, with the
+// requests stopping when ctx is done
+func (server *Server) authenticate(ctx context.Context) error {
 
 	kindSplit := strings.Split(server.kind, "/")
 	kindType := kindSplit[0]
@@ -115,98 +178,192 @@ func (server *Server) Authenticate() (bool, error) {
 
 	accountID, accessToken, err := server.Credentials()
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		server.log.WithFields(logrus.Fields{
 			"accountID":   server.accountID,
 			"accessToken": server.accessToken,
 		}).Errorf("Failed to fetch credentials")
-		return false, err
+		return err
 	}
 
 	switch kindType {
 	case "project":
-		request, err := http.NewRequest("GET", server.apiBaseURL+"/projects/"+kindKey+"/repos", nil)
-		request.SetBasicAuth(accountID, accessToken)
-
 		// Check if user can access repos of the project mentioned (kindKey) in config
-		_, err = server.API.Do(request)
+		_, err = server.get(ctx, server.apiBaseURL+"/projects/"+kindKey+"/repos", accountID, accessToken)
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			server.log.WithFields(logrus.Fields{
 				"project": kindKey,
+				"error":   err.Error(),
 			}).Errorf("Project not found. Check user access")
-			return false, err
+			return err
 		}
-		return true, nil
+		return nil
 
 	case "user":
-		request, err := http.NewRequest("GET", server.apiBaseURL+"/users/"+kindKey+"/repos", nil)
-		request.SetBasicAuth(accountID, accessToken)
-
 		// Check if user can access repos of the user mentioned (kindKey) in config
-		_, err = server.API.Do(request)
+		_, err = server.get(ctx, server.apiBaseURL+"/users/"+kindKey+"/repos", accountID, accessToken)
 		if err != nil {
-			log.WithFields(logrus.Fields{
-				"user": kindKey,
+			server.log.WithFields(logrus.Fields{
+				"user":  kindKey,
+				"error": err.Error(),
 			}).Errorf("User authentication failed")
-			return false, err
+			return err
 		}
-		return true, nil
+		return nil
 
 	default:
 		// Mentioned kind is unsupported
-		log.WithFields(logrus.Fields{
+		server.log.WithFields(logrus.Fields{
 			"kind": kindType,
 		}).Errorf("Unsupported kind")
-		return false, fmt.Errorf("Unsupported kind")
+		return fmt.Errorf("Unsupported kind")
 	}
 }
 
-// allRepositories abstracts over paginated results and gives a list of all the repos
-func (server *Server) allRepositories(URL, accountID, accessToken string) ([]common.Repository, error) {
+// get performs an authenticated GET request and returns the body of a successful response
+// Unsuccessful responses are decoded into an *APIError
+func (server *Server) get(ctx context.Context, URL, accountID, accessToken string) (string, error) {
+	return server.send(ctx, "GET", URL, "", accountID, accessToken)
+}
+
+// send performs an authenticated request with an optional JSON body and returns the body of a successful response
+// Unsuccessful responses are decoded into an *APIError
+func (server *Server) send(ctx context.Context, method, URL, body, accountID, accessToken string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, method, URL, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(accountID, accessToken)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := server.API.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	bodyJSON := string(bodyBytes)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", newAPIError(response.StatusCode, bodyJSON)
+	}
+	return bodyJSON, nil
+}
+
+// allPages abstracts over paginated results and gives the values of all the pages
+func (server *Server) allPages(ctx context.Context, URL, accountID, accessToken string) ([]gjson.Result, error) {
 	isLastPage := false
 	var start int64 = 0
 
-	repositories := []common.Repository{}
+	values := []gjson.Result{}
 
 	for !isLastPage {
-		pagedURL := fmt.Sprintf("%v?start=%v", URL, start)
-		request, err := http.NewRequest("GET", pagedURL, nil)
-		request.SetBasicAuth(accountID, accessToken)
-
-		response, err := server.API.Do(request)
+		separator := "?"
+		if strings.Contains(URL, "?") {
+			separator = "&"
+		}
+		pagedURL := fmt.Sprintf("%v%vstart=%v", URL, separator, start)
+		bodyJSON, err := server.get(ctx, pagedURL, accountID, accessToken)
 		if err != nil {
 			return nil, err
 		}
-		bodyBytes, err := ioutil.ReadAll(response.Body)
-		bodyJSON := string(bodyBytes)
+
+		// A page must be JSON and say whether it is the last one, else the loop never ends
+		lastPage := gjson.Get(bodyJSON, "isLastPage")
+		if !gjson.Valid(bodyJSON) || !lastPage.Exists() {
+			return nil, fmt.Errorf("%w: no isLastPage field at start=%v", ErrMalformedPage, start)
+		}
 
 		// Continue fetching pages until last page
-		isLastPage = gjson.Get(bodyJSON, "isLastPage").Bool()
+		isLastPage = lastPage.Bool()
 		if !isLastPage {
-			start = gjson.Get(bodyJSON, "nextPageStart").Int()
+			nextPageStart := gjson.Get(bodyJSON, "nextPageStart")
+			if !nextPageStart.Exists() || nextPageStart.Int() <= start {
+				return nil, fmt.Errorf("%w: nextPageStart does not advance past start=%v", ErrMalformedPage, start)
+			}
+			start = nextPageStart.Int()
 		}
 
-		repos := gjson.Get(bodyJSON, "values").Array()
-
-		for _, repoJSON := range repos {
+		values = append(values, gjson.Get(bodyJSON, "values").Array()...)
+	}
+	return values, nil
+}
 
-			// Get repo metadata
-			httpCloneLink := gjson.Get(repoJSON.String(), `links.clone.#(name%"http*").href`).String()
-			slug := gjson.Get(repoJSON.String(), `slug`).String()
-			description := gjson.Get(repoJSON.String(), `description`).String()
+// allRepositories abstracts over paginated results and gives a list of all the repos
+func (server *Server) allRepositories(ctx context.Context, URL, accountID, accessToken string) ([]common.Repository, error) {
+	repos, err := server.allPages(ctx, URL, accountID, accessToken)
+	if err != nil {
+		return nil, err
+	}
 
-			newRepo := common.Repository{
-				Slug:        slug,
-				Source:      httpCloneLink,
-				Description: description,
-			}
-			repositories = append(repositories, newRepo)
+	repositories := []common.Repository{}
+	for _, repoJSON := range repos {
+
+		// Get repo metadata
+		httpCloneLink := gjson.Get(repoJSON.String(), `links.clone.#(name%"http*").href`).String()
+		slug := gjson.Get(repoJSON.String(), `slug`).String()
+		description := gjson.Get(repoJSON.String(), `description`).String()
+		public := gjson.Get(repoJSON.String(), `public`).Bool()
+
+		newRepo := common.Repository{
+			Slug:        slug,
+			Source:      httpCloneLink,
+			Description: description,
+			Private:     !public,
 		}
+		repositories = append(repositories, newRepo)
 	}
 	return repositories, nil
 }
 
+// addMetadata fetches the default branch and labels of each repository
+// Failures are logged and leave the field empty, so the target keeps its current value
+func (server *Server) addMetadata(ctx context.Context, reposURL string, repositories []common.Repository, accountID, accessToken string) {
+	for i := range repositories {
+		repoURL := fmt.Sprintf("%v/%v", reposURL, repositories[i].Slug)
+
+		bodyJSON, err := server.get(ctx, repoURL+"/branches/default", accountID, accessToken)
+		if err != nil {
+			// Empty repositories have no default branch
+			server.log.WithFields(logrus.Fields{
+				"repository": repositories[i].Slug,
+				"error":      err.Error(),
+			}).Debugf("Failed to get default branch")
+		} else {
+			repositories[i].DefaultBranch = gjson.Get(bodyJSON, "displayId").String()
+		}
+
+		labels, err := server.allPages(ctx, repoURL+"/labels", accountID, accessToken)
+		if err != nil {
+			// Labels are not available before Bitbucket Server 5.14
+			server.log.WithFields(logrus.Fields{
+				"repository": repositories[i].Slug,
+				"error":      err.Error(),
+			}).Debugf("Failed to get labels")
+			continue
+		}
+		topics := []string{}
+		for _, label := range labels {
+			topics = append(topics, label.Get("name").String())
+		}
+		repositories[i].Topics = topics
+	}
+}
+
 // Repositories queries the API and returns a list of repositories mentioned by the kind
+// The requests are not stopped early, V2 gives the method taking a context
 func (server *Server) Repositories(metadata bool) ([]common.Repository, error) {
+	return server.repositories(context.Background(), metadata)
+}
+
+// repositories lists the repositories of the kind matching the filters, with the requests
+// stopping when ctx is done
+func (server *Server) repositories(ctx context.Context, metadata bool) ([]common.Repository, error) {
 
 	kindSplit := strings.Split(server.kind, "/")
 	kindType := kindSplit[0]
@@ -214,7 +371,7 @@ func (server *Server) Repositories(metadata bool) ([]common.Repository, error) {
 
 	accountID, accessToken, err := server.Credentials()
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		server.log.WithFields(logrus.Fields{
 			"accountID":   server.accountID,
 			"accessToken": server.accessToken,
 		}).Errorf("Failed to fetch credentials")
@@ -225,31 +382,37 @@ func (server *Server) Repositories(metadata bool) ([]common.Repository, error) {
 	case "project":
 		reposURL := fmt.Sprintf("%v/projects/%v/repos", server.apiBaseURL, kindKey)
 		// abstract over pagination
-		repositories, err := server.allRepositories(reposURL, accountID, accessToken)
+		repositories, err := server.allRepositories(ctx, reposURL, accountID, accessToken)
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			server.log.WithFields(logrus.Fields{
 				"project": kindKey,
 			}).Errorf("Failed to get project repositories")
 			return nil, err
 		}
 		repositories = utils.FilterRepos(repositories, server.filters.include, server.filters.exclude)
+		if metadata {
+			server.addMetadata(ctx, reposURL, repositories, accountID, accessToken)
+		}
 		return repositories, nil
 
 	case "user":
 		reposURL := fmt.Sprintf("%v/users/%v/repos", server.apiBaseURL, kindKey)
 		// abstract over pagination
-		repositories, err := server.allRepositories(reposURL, accountID, accessToken)
+		repositories, err := server.allRepositories(ctx, reposURL, accountID, accessToken)
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			server.log.WithFields(logrus.Fields{
 				"user": kindKey,
 			}).Errorf("Failed to get user repositories")
 			return nil, err
 		}
 		repositories = utils.FilterRepos(repositories, server.filters.include, server.filters.exclude)
+		if metadata {
+			server.addMetadata(ctx, reposURL, repositories, accountID, accessToken)
+		}
 		return repositories, nil
 
 	default:
-		log.WithFields(logrus.Fields{
+		server.log.WithFields(logrus.Fields{
 			"kind": kindType,
 		}).Errorf("Unsupported kind")
 		return nil, fmt.Errorf("Unsupported kind")
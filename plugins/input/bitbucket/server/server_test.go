@@ -1,13 +1,21 @@
 package server_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
+	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
 	mock "github.com/parinithshekar/gitsink/mocks/bbserver"
 	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
 	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
 )
 
 var (
@@ -261,3 +269,411 @@ func TestRepositories(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthenticateErrors(t *testing.T) {
+	cases := map[string]struct {
+		AccountID, Kind string
+		ExpectedError   error
+	}{
+		"Rejected credentials": {"usern", "user/username", bbserver.ErrUnauthorized},
+		"Missing project":      {"username", "project/NOYA", bbserver.ErrNotFound},
+		"Missing user":         {"username", "user/unamebad", bbserver.ErrNotFound},
+	}
+
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			os.Setenv(envAccountID, tc.AccountID)
+
+			tcSource := source
+			tcSource.Kind = tc.Kind
+
+			input, err := bbserver.New(tcSource)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+			result, err := input.Authenticate()
+
+			// Validate the status code was mapped to a typed error
+			var apiError *bbserver.APIError
+			if result || !errors.Is(err, tc.ExpectedError) || !errors.As(err, &apiError) {
+				t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, tc.ExpectedError, err)
+			} else if len(apiError.Details) != 1 || apiError.Details[0].Message == "" {
+				t.Errorf("%v - Error details not decoded: %+v", tcName, apiError.Details)
+			}
+		})
+	}
+}
+
+func TestRepositoriesMalformedPages(t *testing.T) {
+	cases := map[string]struct {
+		Body string
+	}{
+		"Non-JSON body":         {"<html>Gateway Timeout</html>"},
+		"Missing isLastPage":    {`{"values": []}`},
+		"Missing nextPageStart": {`{"isLastPage": false, "values": []}`},
+		"Repeated page start":   {`{"isLastPage": false, "nextPageStart": 0, "values": []}`},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	originalDoFunc := mock.DoFunc
+	defer func() { mock.DoFunc = originalDoFunc }()
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			requests := 0
+			mock.DoFunc = func(req *http.Request) (*http.Response, error) {
+				requests++
+				if requests > 10 {
+					t.Fatalf("%v - Pagination did not stop", tcName)
+				}
+				return &http.Response{
+					StatusCode: 200,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(tc.Body))),
+				}, nil
+			}
+
+			input, err := bbserver.New(source)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+			result, err := input.Repositories(true)
+			if result != nil || !errors.Is(err, bbserver.ErrMalformedPage) {
+				t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, bbserver.ErrMalformedPage, err)
+			}
+		})
+	}
+}
+
+func TestContextPath(t *testing.T) {
+	cases := map[string]struct {
+		ContextPath, ExpectedPath string
+	}{
+		"Default context": {"", "/bitbucket/rest/api/1.0/users/username/repos"},
+		"Root context":    {"/", "/rest/api/1.0/users/username/repos"},
+		"Custom context":  {"git/", "/git/rest/api/1.0/users/username/repos"},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	originalDoFunc := mock.DoFunc
+	defer func() { mock.DoFunc = originalDoFunc }()
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			var actualPath string
+			mock.DoFunc = func(req *http.Request) (*http.Response, error) {
+				actualPath = req.URL.Path
+				return originalDoFunc(req)
+			}
+
+			tcSource := source
+			tcSource.ContextPath = tc.ContextPath
+
+			input, err := bbserver.New(tcSource)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			input.API = &mock.MockAPI{}
+
+			_, err = input.Authenticate()
+			if err != nil || actualPath != tc.ExpectedPath {
+				t.Errorf("%v - Expected path: %v | Actual path: %v", tcName, tc.ExpectedPath, actualPath)
+			}
+		})
+	}
+}
+
+func TestHTTPOptions(t *testing.T) {
+	cases := map[string]struct {
+		HTTP          config.HTTP
+		ExpectedError bool
+	}{
+		"No options":         {config.HTTP{}, false},
+		"Timeout and proxy":  {config.HTTP{Timeout: 60, Proxy: "http://proxy.company.com:3128"}, false},
+		"Missing CA bundle":  {config.HTTP{CABundle: "/does/not/exist.pem"}, true},
+		"Missing client key": {config.HTTP{ClientCert: "/does/not/exist.pem"}, true},
+		"Bad proxy URL":      {config.HTTP{Proxy: "http://[::1"}, true},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			tcSource := source
+			tcSource.HTTP = tc.HTTP
+
+			input, err := bbserver.New(tcSource)
+
+			actualError := (err != nil)
+			if actualError != tc.ExpectedError {
+				t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, tc.ExpectedError, err)
+			}
+			if !actualError {
+				var provider plugins.HTTPClientProvider = input
+				if provider.HTTPClient().Transport == nil {
+					t.Errorf("%v - Git HTTP client has no transport", tcName)
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoriesMetadata(t *testing.T) {
+	cases := map[string]struct {
+		Kind, ExpectedDefaultBranch string
+		Metadata, ExpectedPrivate   bool
+		ExpectedTopics              []string
+	}{
+		"Project repo metadata": {"project/TEST", "develop", true, true, []string{"python", "utility"}},
+		"Project repo no fetch": {"project/TEST", "", false, true, nil},
+		"No labels API":         {"user/username", "", true, false, nil},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			tcSource := source
+			tcSource.Kind = tc.Kind
+
+			input, err := bbserver.New(tcSource)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+			result, err := input.Repositories(tc.Metadata)
+			if err != nil || len(result) != 1 {
+				t.Fatalf("%v - Expected one repository | Actual: %v, %v", tcName, result, err)
+			}
+
+			repo := result[0]
+			topicsOK := strings.Join(repo.Topics, ",") == strings.Join(tc.ExpectedTopics, ",") && (repo.Topics == nil) == (tc.ExpectedTopics == nil)
+			if repo.Private != tc.ExpectedPrivate || repo.DefaultBranch != tc.ExpectedDefaultBranch || !topicsOK {
+				t.Errorf("%v - Unexpected metadata: %+v", tcName, repo)
+			}
+		})
+	}
+}
+
+func TestPullRequests(t *testing.T) {
+	cases := map[string]struct {
+		Kind, Slug     string
+		States         []string
+		ExpectedTitles []string
+		ExpectedError  bool
+	}{
+		"Open only":        {"project/TEST", "project-repo-1", []string{"open"}, []string{"Pull request feature/parser"}, false},
+		"Merged, declined": {"project/TEST", "project-repo-1", []string{"merged", "declined"}, []string{"Pull request feature/cli", "Pull request spike"}, false},
+		"Missing repo":     {"user/username", "user-repo-2", []string{"open"}, nil, true},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		tcSource := source
+		tcSource.Kind = tc.Kind
+
+		input, err := bbserver.New(tcSource)
+		if err != nil {
+			t.Fatal("Plugin initiation failed")
+		}
+		input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+		pullRequests, err := input.PullRequests(context.Background(), common.Repository{Slug: tc.Slug}, tc.States)
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+
+		var actualTitles []string
+		for _, pullRequest := range pullRequests {
+			actualTitles = append(actualTitles, pullRequest.Title)
+		}
+		if strings.Join(actualTitles, ",") != strings.Join(tc.ExpectedTitles, ",") {
+			t.Errorf("%v - Expected pull requests: %v | Actual: %v", tcName, tc.ExpectedTitles, actualTitles)
+		}
+	}
+}
+
+func TestPullRequestComments(t *testing.T) {
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	tcSource := source
+	tcSource.Kind = "project/TEST"
+	input, err := bbserver.New(tcSource)
+	if err != nil {
+		t.Fatal("Plugin initiation failed")
+	}
+	input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+	pullRequests, err := input.PullRequests(context.Background(), common.Repository{Slug: "project-repo-1"}, []string{"open"})
+	if err != nil || len(pullRequests) != 1 {
+		t.Fatalf("Expected one pull request | Actual: %v, %v", pullRequests, err)
+	}
+	pullRequest := pullRequests[0]
+
+	if pullRequest.Author.Email != "alice@company.com" || pullRequest.SourceBranch != "feature/parser" || pullRequest.TargetBranch != "develop" {
+		t.Errorf("Unexpected pull request: %+v", pullRequest)
+	}
+
+	// Comments and replies are flattened oldest first, approvals are not comments
+	var actualBodies []string
+	for _, comment := range pullRequest.Comments {
+		actualBodies = append(actualBodies, comment.Body)
+	}
+	expectedBodies := []string{"Looks good", "Rename this", "Done"}
+	if strings.Join(actualBodies, ",") != strings.Join(expectedBodies, ",") {
+		t.Errorf("Expected comments: %v | Actual: %v", expectedBodies, actualBodies)
+	}
+	if pullRequest.Comments[1].Path != "src/main.py" || pullRequest.Comments[1].Line != 7 {
+		t.Errorf("Expected inline comment anchor | Actual: %+v", pullRequest.Comments[1])
+	}
+}
+
+func TestLockRepository(t *testing.T) {
+	cases := map[string]struct {
+		Kind, Slug    string
+		StatusCode    int
+		ExpectedPath  string
+		ExpectedError bool
+	}{
+		"Project repo":        {"project/TEST", "project-repo-1", 200, "/bitbucket/rest/api/1.0/projects/TEST/repos/project-repo-1", false},
+		"User repo":           {"user/username", "user-repo-1", 200, "/bitbucket/rest/api/1.0/projects/~username/repos/user-repo-1", false},
+		"Archiving forbidden": {"project/TEST", "project-repo-1", 403, "/bitbucket/rest/api/1.0/projects/TEST/repos/project-repo-1", true},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	originalDoFunc := mock.DoFunc
+	defer func() { mock.DoFunc = originalDoFunc }()
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			var actualMethod, actualPath, actualBody string
+			var actualRun interface{}
+			mock.DoFunc = func(req *http.Request) (*http.Response, error) {
+				actualMethod, actualPath = req.Method, req.URL.Path
+				actualRun = req.Context().Value(runKey{})
+				if req.Body != nil {
+					body, _ := ioutil.ReadAll(req.Body)
+					actualBody = string(body)
+				}
+				return &http.Response{
+					StatusCode: tc.StatusCode,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+				}, nil
+			}
+
+			tcSource := source
+			tcSource.Kind = tc.Kind
+			input, err := bbserver.New(tcSource)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+			var locker plugins.SourceLocker = input
+			ctx := context.WithValue(context.Background(), runKey{}, "call")
+			err = locker.LockRepository(ctx, common.Repository{Slug: tc.Slug})
+			if (err != nil) != tc.ExpectedError {
+				t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			}
+			if actualMethod != "PUT" || actualPath != tc.ExpectedPath || actualBody != `{"archived":true}` {
+				t.Errorf("%v - Unexpected request: %v %v %v", tcName, actualMethod, actualPath, actualBody)
+			}
+			if actualRun != "call" {
+				t.Errorf("%v - Expected request with the context of the call | Actual: %v", tcName, actualRun)
+			}
+		})
+	}
+}
+
+// runKey marks the contexts given to the plugin calls
+type runKey struct{}
+
+func TestV2(t *testing.T) {
+	cases := map[string]struct {
+		AccountID, Kind string
+		ExpectedKind    error
+	}{
+		"Authenticated":        {"username", "user/username", nil},
+		"Rejected credentials": {"usern", "user/username", v2.ErrUnauthorized},
+		"Missing project":      {"username", "project/NOYA", v2.ErrNotFound},
+	}
+
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	originalDoFunc := mock.DoFunc
+	defer func() { mock.DoFunc = originalDoFunc }()
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			os.Setenv(envAccountID, tc.AccountID)
+
+			var requestRuns []interface{}
+			mock.DoFunc = func(req *http.Request) (*http.Response, error) {
+				requestRuns = append(requestRuns, req.Context().Value(runKey{}))
+				return originalDoFunc(req)
+			}
+
+			tcSource := source
+			tcSource.Kind = tc.Kind
+			input, err := bbserver.New(tcSource)
+			if err != nil {
+				t.Fatal("Plugin initiation failed")
+			}
+			input.API = &mock.MockAPI{BaseURL: source.BaseURL + "/bitbucket/rest/api/1.0"}
+
+			ctx := context.WithValue(context.Background(), runKey{}, "call")
+
+			err = input.V2().Authenticate(ctx)
+			if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+				t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+			}
+			if err == nil {
+				repos, err := input.V2().Repositories(ctx, v2.ListOptions{})
+				if err != nil || len(repos) == 0 {
+					t.Errorf("%v - Expected repositories | Actual: %v, %v", tcName, repos, err)
+				}
+			}
+			for _, run := range requestRuns {
+				if run != "call" {
+					t.Errorf("%v - Expected requests with the context of the call | Actual: %v", tcName, run)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+
+	common "github.com/parinithshekar/gitsink/common"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// native is the Bitbucket Server source with the methods of the second plugin interfaces
+// Its requests use the context of each call, never the one set on the plugin
+type native struct {
+	server *Server
+}
+
+// V2 gives the source with the methods of the second plugin interfaces
+func (server *Server) V2() v2.Input {
+	return native{server: server}
+}
+
+// Authenticate checks that the credentials can read the repositories of the kind
+func (source native) Authenticate(ctx context.Context) error {
+	if _, _, err := source.server.Credentials(); err != nil {
+		return v2.Wrap("authenticate source", "", v2.ErrCredentials, err)
+	}
+	return v2.Wrap("authenticate source", "", v2.ErrFailed, source.server.authenticate(ctx))
+}
+
+// Repositories lists the repositories of the kind matching the filters
+func (source native) Repositories(ctx context.Context, options v2.ListOptions) ([]common.Repository, error) {
+	repos, err := source.server.repositories(ctx, options.Metadata)
+	if err != nil {
+		return nil, v2.Wrap("list source repositories", "", v2.ErrFailed, err)
+	}
+	return repos, nil
+}
+
+// Auth gives the credentials of the source as git basic auth
+func (source native) Auth() (transport.AuthMethod, error) {
+	return v2.BasicAuth("source credentials", source.server.Credentials)
+}
+
+// Capabilities tells what Bitbucket Server sources support
+func (source native) Capabilities() v2.Capabilities {
+	return source.server.Capabilities()
+}
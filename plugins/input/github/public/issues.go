@@ -0,0 +1,126 @@
+package public
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// parseUser reads a GitHub user, which only has a login in issue payloads
+func parseUser(user *github.User) common.User {
+	return common.User{
+		Name:        user.GetLogin(),
+		DisplayName: user.GetName(),
+		Email:       user.GetEmail(),
+	}
+}
+
+// comments lists every comment of an issue, oldest first
+func (public Public) comments(ctx context.Context, owner, name string, number int) ([]common.Comment, error) {
+	var comments []common.Comment
+
+	opt := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: pageLength},
+	}
+	for {
+		page, response, err := public.api.Issues.ListComments(ctx, owner, name, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range page {
+			comments = append(comments, common.Comment{
+				ID:      strconv.FormatInt(comment.GetID(), 10),
+				Author:  parseUser(comment.GetUser()),
+				Body:    comment.GetBody(),
+				Created: comment.GetCreatedAt(),
+			})
+		}
+		if response.NextPage == 0 {
+			return comments, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+// Issues reads the issues of the repository, oldest first, with their comments, labels and milestone
+// Pull requests are left out, although the GitHub issues API lists them too
+func (public Public) Issues(ctx context.Context, repo common.Repository) ([]common.Issue, error) {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	owner := kindSplit[1]
+
+	var issues []common.Issue
+	opt := &github.IssueListByRepoOptions{
+		State:       "all",
+		Sort:        "created",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: pageLength},
+	}
+	for {
+		page, response, err := public.api.Issues.ListByRepo(ctx, owner, repo.Slug, opt)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"error":      err.Error(),
+			}).Errorf("Failed to get issues")
+			return nil, err
+		}
+
+		for _, ghIssue := range page {
+			if ghIssue.IsPullRequest() {
+				continue
+			}
+
+			issue := common.Issue{
+				ID:          int64(ghIssue.GetNumber()),
+				Title:       ghIssue.GetTitle(),
+				Description: ghIssue.GetBody(),
+				State:       ghIssue.GetState(),
+				URL:         ghIssue.GetHTMLURL(),
+				Author:      parseUser(ghIssue.GetUser()),
+				Created:     ghIssue.GetCreatedAt(),
+			}
+			for _, label := range ghIssue.Labels {
+				issue.Labels = append(issue.Labels, common.Label{
+					Name:        label.GetName(),
+					Color:       label.GetColor(),
+					Description: label.GetDescription(),
+				})
+			}
+			if ghIssue.Milestone != nil {
+				issue.Milestone = &common.Milestone{
+					Title:       ghIssue.Milestone.GetTitle(),
+					Description: ghIssue.Milestone.GetDescription(),
+					State:       ghIssue.Milestone.GetState(),
+				}
+				if ghIssue.Milestone.DueOn != nil {
+					due := ghIssue.Milestone.GetDueOn()
+					issue.Milestone.Due = &due
+				}
+			}
+
+			if ghIssue.GetComments() > 0 {
+				issue.Comments, err = public.comments(ctx, owner, repo.Slug, ghIssue.GetNumber())
+				if err != nil {
+					public.log.WithFields(logrus.Fields{
+						"repository": repo.Slug,
+						"issue":      issue.ID,
+						"error":      err.Error(),
+					}).Errorf("Failed to get issue comments")
+					return nil, err
+				}
+			}
+			issues = append(issues, issue)
+		}
+
+		if response.NextPage == 0 {
+			return issues, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
@@ -1 +1,307 @@
 package public
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+	oauth2 "golang.org/x/oauth2"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	transport "github.com/parinithshekar/gitsink/common/transport"
+	utils "github.com/parinithshekar/gitsink/common/utils"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
+)
+
+var (
+	log = logger.Root()
+)
+
+// pageLength is the maximum page size allowed by the GitHub API
+const pageLength = 100
+
+// Public struct defines the data fields in github-public object
+type Public struct {
+	accountID   string
+	accessToken string
+	kind        string
+	filters     struct {
+		include []string
+		exclude []string
+	}
+	api       *github.Client
+	transport http.RoundTripper
+	log       pkg.Logger
+}
+
+// Credentials fetches amd returns the accountID and accessToken from environment variables
+func (public Public) Credentials() (string, string, error) {
+	accountID, exists := os.LookupEnv(public.accountID)
+	if !exists {
+		public.log.WithFields(logrus.Fields{
+			"accountID": public.accountID,
+		}).Errorf("Account ID not found")
+		return "", "", fmt.Errorf("Account ID not found")
+	}
+
+	accessToken, exists := os.LookupEnv(public.accessToken)
+	if !exists {
+		public.log.WithFields(logrus.Fields{
+			"accessToken": public.accessToken,
+		}).Errorf("Access Token not found")
+		return "", "", fmt.Errorf("Access Token not found")
+	}
+
+	return accountID, accessToken, nil
+}
+
+// setAPIClient builds the API client with the TLS and proxy options from config
+// A base URL other than github.com is a GitHub Enterprise instance
+func (public *Public) setAPIClient(source config.Source) error {
+
+	httpTransport, err := transport.New(source.HTTP)
+	if err != nil {
+		return err
+	}
+	public.transport = httpTransport
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: metrics.Transport("input/github-public", httpTransport),
+		Timeout:   transport.Timeout(source.HTTP),
+	})
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: os.Getenv(public.accessToken)},
+	)
+	tc := oauth2.NewClient(ctx, ts)
+
+	if enterprise(source.BaseURL) {
+		public.api, err = github.NewEnterpriseClient(source.BaseURL, source.BaseURL, tc)
+		return err
+	}
+	public.api = github.NewClient(tc)
+	return nil
+}
+
+// enterprise checks if the base URL from config points at a GitHub Enterprise instance
+func enterprise(baseURL string) bool {
+	if baseURL == "" {
+		return false
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return true
+	}
+	host := strings.TrimPrefix(parsed.Hostname(), "www.")
+	return host != "github.com"
+}
+
+// HTTPClient returns a client with the TLS and proxy options of the API client, for git operations
+func (public Public) HTTPClient() *http.Client {
+	return &http.Client{Transport: public.transport}
+}
+
+// SetLogger has the plugin log through the logger of the run it is used in
+func (public *Public) SetLogger(log pkg.Logger) {
+	public.log = log
+}
+
+// Capabilities tells what GitHub sources support
+func (public *Public) Capabilities() v2.Capabilities {
+	return v2.Capabilities{LFS: true, Issues: true, Releases: true, Wiki: true}
+}
+
+// New returns a new github-public source object
+func New(source config.Source) (*Public, error) {
+	var public *Public = new(Public)
+	public.log = log
+
+	_, exists := os.LookupEnv(source.AccountID)
+	if !exists {
+		log.WithFields(logrus.Fields{
+			"accountID": source.AccountID,
+		}).Errorf("Account ID not found")
+		return nil, fmt.Errorf("Account ID not found")
+	}
+	public.accountID = source.AccountID
+
+	_, exists = os.LookupEnv(source.AccessToken)
+	if !exists {
+		log.WithFields(logrus.Fields{
+			"accessToken": source.AccessToken,
+		}).Errorf("Access Token not found")
+		return nil, fmt.Errorf("Access Token not found")
+	}
+	public.accessToken = source.AccessToken
+
+	public.kind = source.Kind
+
+	public.filters.include = source.Repositories.Include
+	public.filters.exclude = source.Repositories.Exclude
+
+	err := public.setAPIClient(source)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"baseURL": source.BaseURL,
+			"error":   err.Error(),
+		}).Errorf("Failed to set up API client")
+		return nil, err
+	}
+
+	return public, nil
+}
+
+// Authenticate checks the account ID and access tokens' validity for the kind defined
+// The requests are not stopped early, V2 gives the method taking a context
+func (public Public) Authenticate() (bool, error) {
+	return public.authenticate(context.Background())
+}
+
+// authenticate checks the organization or user of the kind with the requests stopped by the context
+func (public Public) authenticate(ctx context.Context) (bool, error) {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	kindType := kindSplit[0]
+	kindKey := kindSplit[1]
+
+	switch kindType {
+	case "org":
+		_, _, err := public.api.Organizations.Get(ctx, kindKey)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"organization": kindKey,
+				"error":        err.Error(),
+			}).Errorf("Organization not found. Check user access")
+			return false, err
+		}
+		return true, nil
+
+	case "user":
+		_, _, err := public.api.Users.Get(ctx, kindKey)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"user":  kindKey,
+				"error": err.Error(),
+			}).Errorf("User authentication failed")
+			return false, err
+		}
+		return true, nil
+
+	default:
+		// Mentioned kind is unsupported
+		public.log.WithFields(logrus.Fields{
+			"kind": kindType,
+		}).Errorf("Unsupported kind")
+		return false, fmt.Errorf("Unsupported kind")
+	}
+}
+
+// allRepositories lists every repository of the organization or user across pages
+// Private repositories of a user are only listed for the authenticated user
+func (public Public) allRepositories(ctx context.Context, kindType, kindKey string) ([]*github.Repository, error) {
+	var all []*github.Repository
+
+	self := false
+	if kindType == "user" {
+		user, _, err := public.api.Users.Get(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		self = strings.EqualFold(user.GetLogin(), kindKey)
+	}
+
+	listOptions := github.ListOptions{PerPage: pageLength}
+	for {
+		var repos []*github.Repository
+		var response *github.Response
+		var err error
+
+		switch {
+		case kindType == "org":
+			repos, response, err = public.api.Repositories.ListByOrg(ctx, kindKey, &github.RepositoryListByOrgOptions{
+				Type:        "all",
+				ListOptions: listOptions,
+			})
+		case self:
+			repos, response, err = public.api.Repositories.List(ctx, "", &github.RepositoryListOptions{
+				Affiliation: "owner",
+				ListOptions: listOptions,
+			})
+		default:
+			repos, response, err = public.api.Repositories.List(ctx, kindKey, &github.RepositoryListOptions{
+				Type:        "owner",
+				ListOptions: listOptions,
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, repos...)
+		if response.NextPage == 0 {
+			return all, nil
+		}
+		listOptions.Page = response.NextPage
+	}
+}
+
+// Repositories queries the API and returns a list of repositories mentioned by the kind
+// The requests are not stopped early, V2 gives the method taking a context
+func (public Public) Repositories(metadata bool) ([]common.Repository, error) {
+	return public.repositories(context.Background(), metadata)
+}
+
+// repositories lists the repositories of the kind with the requests stopped by the context
+func (public Public) repositories(ctx context.Context, metadata bool) ([]common.Repository, error) {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	kindType := kindSplit[0]
+	kindKey := kindSplit[1]
+
+	if kindType != "org" && kindType != "user" {
+		public.log.WithFields(logrus.Fields{
+			"kind": kindType,
+		}).Errorf("Unsupported kind")
+		return nil, fmt.Errorf("Unsupported kind")
+	}
+
+	repos, err := public.allRepositories(ctx, kindType, kindKey)
+	if err != nil {
+		public.log.WithFields(logrus.Fields{
+			"kind":  public.kind,
+			"error": err.Error(),
+		}).Errorf("Failed to get repositories")
+		return nil, err
+	}
+
+	repositories := []common.Repository{}
+	for _, repo := range repos {
+		newRepo := common.Repository{
+			Slug:          repo.GetName(),
+			Source:        repo.GetCloneURL(),
+			Description:   repo.GetDescription(),
+			Private:       repo.GetPrivate(),
+			DefaultBranch: repo.GetDefaultBranch(),
+			Homepage:      repo.GetHomepage(),
+		}
+		// Wikis without pages have no git repository yet, which the sync skips
+		if repo.GetHasWiki() {
+			newRepo.Wiki = strings.TrimSuffix(newRepo.Source, ".git") + ".wiki.git"
+		}
+		if metadata {
+			newRepo.Topics = append([]string{}, repo.Topics...)
+		}
+		repositories = append(repositories, newRepo)
+	}
+
+	repositories = utils.FilterRepos(repositories, public.filters.include, public.filters.exclude)
+	return repositories, nil
+}
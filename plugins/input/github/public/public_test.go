@@ -0,0 +1,241 @@
+package public_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	mock "github.com/parinithshekar/gitsink/mocks/github"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/input/github/public"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+var (
+	envAccountID   = "TEST_GHSOURCE_ACCOUNT_ID"
+	envAccessToken = "TEST_GHSOURCE_ACCESS_TOKEN"
+
+	source config.Source = config.Source{
+		Type:        "github-public",
+		AccountID:   envAccountID,
+		AccessToken: envAccessToken,
+		Kind:        "org/org",
+		Repositories: config.Repositories{
+			Include: []string{"/.*/"},
+		},
+	}
+)
+
+// newMockInput returns a github-public source for the kind, calling the mock server with the token
+func newMockInput(t *testing.T, baseURL, kind, token string) *ghpublic.Public {
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, token)
+
+	tcSource := source
+	tcSource.BaseURL = baseURL
+	tcSource.Kind = kind
+	input, err := ghpublic.New(tcSource)
+	if err != nil {
+		t.Fatal("Plugin initiation failed")
+	}
+	return input
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		EnvAccountIDSet, EnvAccessTokenSet, ExpectedError bool
+	}{
+		"No env vars":        {false, false, true},
+		"No accessToken env": {true, false, true},
+		"No accountID env ":  {false, true, true},
+		"Env vars set":       {true, true, false},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		tcSource := source
+		if !tc.EnvAccountIDSet {
+			tcSource.AccountID = "FAKE_ACCOUNT_ID"
+		}
+		if !tc.EnvAccessTokenSet {
+			tcSource.AccessToken = "FAKE_ACCESS_TOKEN"
+		}
+		var input interface{}
+		input, err := ghpublic.New(tcSource)
+
+		actualError := (err != nil)
+		if actualError != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, tc.ExpectedError, actualError)
+		}
+		if _, typeOK := input.(plugins.Input); !typeOK {
+			t.Errorf("%v - Plugin type check failed", tcName)
+		}
+		if _, typeOK := input.(plugins.IssueSource); !typeOK {
+			t.Errorf("%v - Issue source type check failed", tcName)
+		}
+	}
+}
+
+func TestRepositories(t *testing.T) {
+	server := mock.NewServer("token")
+	defer server.Close()
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	cases := map[string]struct {
+		Kind, Token   string
+		ExpectedRepos []common.Repository
+		ExpectedError bool
+	}{
+		"Organization": {"org/org", "token", []common.Repository{
+			{Slug: "app", Source: "https://github.com/org/app.git", Description: "The app", Private: true,
+				DefaultBranch: "main", Homepage: "https://app.org.com", Topics: []string{"go"},
+				Wiki: "https://github.com/org/app.wiki.git"},
+			{Slug: "docs", Source: "https://github.com/org/docs.git", DefaultBranch: "master", Topics: []string{}},
+		}, false},
+		"Bad token":        {"org/org", "bad", nil, true},
+		"Unsupported kind": {"team/org", "token", nil, true},
+	}
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, server.URL, tc.Kind, tc.Token)
+
+		if _, err := input.Authenticate(); (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected authentication error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+		}
+		repositories, err := input.Repositories(true)
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+		if !tc.ExpectedError && !reflect.DeepEqual(repositories, tc.ExpectedRepos) {
+			t.Errorf("%v - Expected: %+v | Actual: %+v", tcName, tc.ExpectedRepos, repositories)
+		}
+	}
+}
+
+func TestIssues(t *testing.T) {
+	server := mock.NewServer("token")
+	defer server.Close()
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	due := time.Date(2020, 6, 1, 7, 0, 0, 0, time.UTC)
+	expected := []common.Issue{{
+		ID:          1,
+		Title:       "Crash on start",
+		Description: "Stack trace attached",
+		State:       common.IssueClosed,
+		URL:         "https://github.com/org/app/issues/1",
+		Author:      common.User{Name: "alice"},
+		Labels:      []common.Label{{Name: "bug", Color: "d73a4a", Description: "Something is broken"}},
+		Milestone:   &common.Milestone{Title: "v1.0", State: common.IssueOpen, Due: &due},
+		Comments: []common.Comment{{
+			ID:      "101",
+			Author:  common.User{Name: "bob"},
+			Body:    "Fixed in main",
+			Created: time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC),
+		}},
+		Created: time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC),
+	}}
+
+	cases := map[string]struct {
+		Slug           string
+		ExpectedIssues []common.Issue
+		ExpectedError  bool
+	}{
+		"Issues without pull requests": {"app", expected, false},
+		"Missing repository":           {"gone", nil, true},
+	}
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, server.URL, "org/org", "token")
+		issues, err := input.Issues(context.Background(), common.Repository{Slug: tc.Slug})
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+		if !reflect.DeepEqual(issues, tc.ExpectedIssues) {
+			t.Errorf("%v - Expected: %+v | Actual: %+v", tcName, tc.ExpectedIssues, issues)
+		}
+	}
+}
+
+func TestReleases(t *testing.T) {
+	server := mock.NewServer("token")
+	defer server.Close()
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	input := newMockInput(t, server.URL, "org/org", "token")
+	repo := common.Repository{Slug: "app"}
+	releases, err := input.Releases(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []common.Release{{
+		Tag: "v1.0.0", Title: "First release", Notes: "Fixes the crash",
+		URL:    "https://github.com/org/app/releases/tag/v1.0.0",
+		Author: common.User{Name: "alice"},
+		Assets: []common.Asset{{ID: 11, Name: "app.tar.gz", ContentType: "application/gzip", Size: 14,
+			URL: "https://github.com/org/app/releases/download/v1.0.0/app.tar.gz"}},
+		Created: time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC),
+	}}
+	if !reflect.DeepEqual(releases, expected) {
+		t.Fatalf("Expected: %+v | Actual: %+v", expected, releases)
+	}
+
+	contents, err := input.ReleaseAsset(context.Background(), repo, releases[0].Assets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer contents.Close()
+	contentBytes, _ := ioutil.ReadAll(contents)
+	if string(contentBytes) != mock.Asset {
+		t.Errorf("Expected: %v | Actual: %v", mock.Asset, string(contentBytes))
+	}
+}
+
+func TestV2(t *testing.T) {
+	server := mock.NewServer("token")
+	defer server.Close()
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := map[string]struct {
+		Ctx          context.Context
+		Token        string
+		ExpectedKind error
+	}{
+		"Authenticated":    {context.Background(), "token", nil},
+		"Canceled context": {canceled, "token", v2.ErrCanceled},
+		"Bad token":        {context.Background(), "bad", v2.ErrFailed},
+	}
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, server.URL, "org/org", tc.Token)
+
+		err := input.V2().Authenticate(tc.Ctx)
+		if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+			t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+		}
+		repos, err := input.V2().Repositories(tc.Ctx, v2.ListOptions{})
+		if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+			t.Errorf("%v - Expected kind: %v | Actual: %v, %v", tcName, tc.ExpectedKind, repos, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package public
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// Releases reads the releases of the repository, with their assets
+// Drafts are listed too since the access token can push to the repository
+func (public Public) Releases(ctx context.Context, repo common.Repository) ([]common.Release, error) {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	owner := kindSplit[1]
+
+	var releases []common.Release
+	opt := &github.ListOptions{PerPage: pageLength}
+	for {
+		page, response, err := public.api.Repositories.ListReleases(ctx, owner, repo.Slug, opt)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"error":      err.Error(),
+			}).Errorf("Failed to get releases")
+			return nil, err
+		}
+
+		for _, ghRelease := range page {
+			release := common.Release{
+				Tag:        ghRelease.GetTagName(),
+				Title:      ghRelease.GetName(),
+				Notes:      ghRelease.GetBody(),
+				Prerelease: ghRelease.GetPrerelease(),
+				Draft:      ghRelease.GetDraft(),
+				URL:        ghRelease.GetHTMLURL(),
+				Author:     parseUser(ghRelease.GetAuthor()),
+				Created:    ghRelease.GetCreatedAt().Time,
+			}
+			for _, asset := range ghRelease.Assets {
+				release.Assets = append(release.Assets, common.Asset{
+					ID:          asset.GetID(),
+					Name:        asset.GetName(),
+					ContentType: asset.GetContentType(),
+					Size:        int64(asset.GetSize()),
+					URL:         asset.GetBrowserDownloadURL(),
+				})
+			}
+			releases = append(releases, release)
+		}
+
+		if response.NextPage == 0 {
+			return releases, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+// ReleaseAsset opens the contents of a release asset
+// Assets are served from storage that GitHub redirects to, which is followed without the access token
+func (public Public) ReleaseAsset(ctx context.Context, repo common.Repository, asset common.Asset) (io.ReadCloser, error) {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	owner := kindSplit[1]
+
+	rc, _, err := public.api.Repositories.DownloadReleaseAsset(ctx, owner, repo.Slug, asset.ID, &http.Client{Transport: public.transport})
+	return rc, err
+}
@@ -0,0 +1,49 @@
+package public
+
+import (
+	"context"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+
+	common "github.com/parinithshekar/gitsink/common"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// native is the GitHub source with the methods of the second plugin interfaces
+// Each call makes its requests with its own context
+type native struct {
+	public *Public
+}
+
+// V2 gives the source with the methods of the second plugin interfaces
+func (public *Public) V2() v2.Input {
+	return native{public: public}
+}
+
+// Authenticate checks that the credentials can read the organization or user of the kind
+func (source native) Authenticate(ctx context.Context) error {
+	if _, _, err := source.public.Credentials(); err != nil {
+		return v2.Wrap("authenticate source", "", v2.ErrCredentials, err)
+	}
+	_, err := source.public.authenticate(ctx)
+	return v2.Wrap("authenticate source", "", v2.ErrFailed, err)
+}
+
+// Repositories lists the repositories of the kind matching the filters
+func (source native) Repositories(ctx context.Context, options v2.ListOptions) ([]common.Repository, error) {
+	repos, err := source.public.repositories(ctx, options.Metadata)
+	if err != nil {
+		return nil, v2.Wrap("list source repositories", "", v2.ErrFailed, err)
+	}
+	return repos, nil
+}
+
+// Auth gives the credentials of the source as git basic auth
+func (source native) Auth() (transport.AuthMethod, error) {
+	return v2.BasicAuth("source credentials", source.public.Credentials)
+}
+
+// Capabilities tells what GitHub sources support
+func (source native) Capabilities() v2.Capabilities {
+	return source.public.Capabilities()
+}
@@ -0,0 +1,345 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	logrus "github.com/sirupsen/logrus"
+	gjson "github.com/tidwall/gjson"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	transport "github.com/parinithshekar/gitsink/common/transport"
+	utils "github.com/parinithshekar/gitsink/common/utils"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
+)
+
+var (
+	log = logger.Root()
+)
+
+const (
+	// defaultBaseURL is GitLab.com, used unless config points at a self-managed instance
+	defaultBaseURL = "https://gitlab.com"
+	// pageLength is the maximum page size allowed by the GitLab API
+	pageLength = 100
+)
+
+// APIClient defines the methods for the API in GitLab object
+type APIClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// GitLab struct defines the data fields in gitlab object
+type GitLab struct {
+	baseURL     string
+	apiBaseURL  string
+	accountID   string
+	accessToken string
+	kind        string
+	filters     struct {
+		include []string
+		exclude []string
+	}
+	API       APIClient
+	transport http.RoundTripper
+	log       pkg.Logger
+}
+
+// Credentials fetches amd returns the accountID and accessToken from environment variables
+func (gitlab *GitLab) Credentials() (string, string, error) {
+	accountID, exists := os.LookupEnv(gitlab.accountID)
+	if !exists {
+		gitlab.log.WithFields(logrus.Fields{
+			"accountID": gitlab.accountID,
+		}).Errorf("Account ID not found")
+		return "", "", fmt.Errorf("Account ID not found")
+	}
+
+	accessToken, exists := os.LookupEnv(gitlab.accessToken)
+	if !exists {
+		gitlab.log.WithFields(logrus.Fields{
+			"accessToken": gitlab.accessToken,
+		}).Errorf("Access Token not found")
+		return "", "", fmt.Errorf("Access Token not found")
+	}
+
+	return accountID, accessToken, nil
+}
+
+// setAPIClient builds and returns an object to facilitate calls to the API
+func (gitlab *GitLab) setAPIClient(source config.Source) error {
+
+	httpTransport, err := transport.New(source.HTTP)
+	if err != nil {
+		return err
+	}
+	gitlab.transport = httpTransport
+
+	gitlab.baseURL = strings.TrimSuffix(source.BaseURL, "/")
+	if gitlab.baseURL == "" {
+		gitlab.baseURL = defaultBaseURL
+	}
+	gitlab.apiBaseURL = gitlab.baseURL + "/api/v4"
+	gitlab.API = &http.Client{
+		Transport: metrics.Transport("input/gitlab", httpTransport),
+		Timeout:   transport.Timeout(source.HTTP),
+	}
+	return nil
+}
+
+// HTTPClient returns a client with the TLS and proxy options of the API client, for git operations
+func (gitlab *GitLab) HTTPClient() *http.Client {
+	return &http.Client{Transport: gitlab.transport}
+}
+
+// SetLogger has the plugin log through the logger of the run it is used in
+func (gitlab *GitLab) SetLogger(log pkg.Logger) {
+	gitlab.log = log
+}
+
+// Capabilities tells what GitLab sources support
+func (gitlab *GitLab) Capabilities() v2.Capabilities {
+	return v2.Capabilities{LFS: true, Issues: true, Releases: true, Wiki: true}
+}
+
+// New returns a new gitlab object
+func New(source config.Source) (*GitLab, error) {
+	var gitlab *GitLab = new(GitLab)
+	gitlab.log = log
+
+	_, exists := os.LookupEnv(source.AccountID)
+	if !exists {
+		log.WithFields(logrus.Fields{
+			"accountID": source.AccountID,
+		}).Errorf("Account ID not found")
+		return nil, fmt.Errorf("Account ID not found")
+	}
+	gitlab.accountID = source.AccountID
+
+	_, exists = os.LookupEnv(source.AccessToken)
+	if !exists {
+		log.WithFields(logrus.Fields{
+			"accessToken": source.AccessToken,
+		}).Errorf("Access Token not found")
+		return nil, fmt.Errorf("Access Token not found")
+	}
+	gitlab.accessToken = source.AccessToken
+
+	gitlab.kind = source.Kind
+
+	gitlab.filters.include = source.Repositories.Include
+	gitlab.filters.exclude = source.Repositories.Exclude
+
+	err := gitlab.setAPIClient(source)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"baseURL": source.BaseURL,
+			"error":   err.Error(),
+		}).Errorf("Failed to set up API client")
+		return nil, err
+	}
+
+	return gitlab, nil
+}
+
+// get performs a GET request with the access token, returning the body and the next page if any
+func (gitlab *GitLab) get(ctx context.Context, URL, accessToken string) (string, string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	request.Header.Set("PRIVATE-TOKEN", accessToken)
+
+	response, err := gitlab.API.Do(request)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", "", err
+	}
+	bodyJSON := string(bodyBytes)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message := gjson.Get(bodyJSON, "message").String()
+		if message == "" {
+			message = gjson.Get(bodyJSON, "error").String()
+		}
+		if message == "" {
+			message = http.StatusText(response.StatusCode)
+		}
+		return "", "", fmt.Errorf("Request failed with status %v: %v", response.StatusCode, message)
+	}
+	return bodyJSON, response.Header.Get("X-Next-Page"), nil
+}
+
+// allPages follows the X-Next-Page header of paginated results and gives the values of all the pages
+func (gitlab *GitLab) allPages(ctx context.Context, URL, accessToken string) ([]gjson.Result, error) {
+	separator := "?"
+	if strings.Contains(URL, "?") {
+		separator = "&"
+	}
+
+	values := []gjson.Result{}
+	page := "1"
+	visited := map[string]bool{}
+	for page != "" {
+		// Guard against a page pointing back to one already fetched
+		if visited[page] {
+			return nil, fmt.Errorf("Pagination loop detected")
+		}
+		visited[page] = true
+
+		bodyJSON, nextPage, err := gitlab.get(ctx, fmt.Sprintf("%v%vper_page=%v&page=%v", URL, separator, pageLength, page), accessToken)
+		if err != nil {
+			return nil, err
+		}
+		if !gjson.Valid(bodyJSON) || !gjson.Parse(bodyJSON).IsArray() {
+			return nil, fmt.Errorf("Page %v is not a list", page)
+		}
+		values = append(values, gjson.Parse(bodyJSON).Array()...)
+		page = nextPage
+	}
+	return values, nil
+}
+
+// Authenticate checks the account ID and access tokens' validity for the kind defined
+// The requests are not stopped early, V2 gives the method taking a context
+func (gitlab *GitLab) Authenticate() (bool, error) {
+	return gitlab.authenticate(context.Background())
+}
+
+// authenticate checks the group or user of the kind with the requests stopped by the context
+func (gitlab *GitLab) authenticate(ctx context.Context) (bool, error) {
+
+	kindSplit := strings.SplitN(gitlab.kind, "/", 2)
+	kindType := kindSplit[0]
+	kindKey := kindSplit[1]
+
+	_, accessToken, err := gitlab.Credentials()
+	if err != nil {
+		return false, err
+	}
+
+	switch kindType {
+	case "group":
+		// Check if the user can see the group, subgroups are given by their full path
+		_, _, err = gitlab.get(ctx, fmt.Sprintf("%v/groups/%v", gitlab.apiBaseURL, url.PathEscape(kindKey)), accessToken)
+		if err != nil {
+			gitlab.log.WithFields(logrus.Fields{
+				"group": kindKey,
+				"error": err.Error(),
+			}).Errorf("Group not found. Check user access")
+			return false, err
+		}
+		return true, nil
+
+	case "user":
+		_, _, err = gitlab.get(ctx, fmt.Sprintf("%v/users?username=%v", gitlab.apiBaseURL, url.QueryEscape(kindKey)), accessToken)
+		if err != nil {
+			gitlab.log.WithFields(logrus.Fields{
+				"user":  kindKey,
+				"error": err.Error(),
+			}).Errorf("User authentication failed")
+			return false, err
+		}
+		return true, nil
+
+	default:
+		gitlab.log.WithFields(logrus.Fields{
+			"kind": kindType,
+		}).Errorf("Unsupported kind")
+		return false, fmt.Errorf("Unsupported kind")
+	}
+}
+
+// wikiEnabled checks if the project has its wiki turned on
+// Newer GitLab versions replace wiki_enabled with wiki_access_level
+func wikiEnabled(project gjson.Result) bool {
+	if accessLevel := project.Get("wiki_access_level"); accessLevel.Exists() {
+		return accessLevel.String() != "disabled"
+	}
+	return project.Get("wiki_enabled").Bool()
+}
+
+// Repositories queries the API and returns a list of the projects mentioned by the kind
+// Projects of subgroups are included for group kinds
+// The requests are not stopped early, V2 gives the method taking a context
+func (gitlab *GitLab) Repositories(metadata bool) ([]common.Repository, error) {
+	return gitlab.repositories(context.Background(), metadata)
+}
+
+// repositories lists the projects of the kind with the requests stopped by the context
+func (gitlab *GitLab) repositories(ctx context.Context, metadata bool) ([]common.Repository, error) {
+
+	kindSplit := strings.SplitN(gitlab.kind, "/", 2)
+	kindType := kindSplit[0]
+	kindKey := kindSplit[1]
+
+	_, accessToken, err := gitlab.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	var projectsURL string
+	switch kindType {
+	case "group":
+		projectsURL = fmt.Sprintf("%v/groups/%v/projects?include_subgroups=true&archived=false", gitlab.apiBaseURL, url.PathEscape(kindKey))
+	case "user":
+		projectsURL = fmt.Sprintf("%v/users/%v/projects?archived=false", gitlab.apiBaseURL, url.PathEscape(kindKey))
+	default:
+		gitlab.log.WithFields(logrus.Fields{
+			"kind": kindType,
+		}).Errorf("Unsupported kind")
+		return nil, fmt.Errorf("Unsupported kind")
+	}
+
+	projects, err := gitlab.allPages(ctx, projectsURL, accessToken)
+	if err != nil {
+		gitlab.log.WithFields(logrus.Fields{
+			"kind":  gitlab.kind,
+			"error": err.Error(),
+		}).Errorf("Failed to get repositories")
+		return nil, err
+	}
+
+	repositories := []common.Repository{}
+	for _, project := range projects {
+		newRepo := common.Repository{
+			Slug:          project.Get("path").String(),
+			Source:        project.Get("http_url_to_repo").String(),
+			Description:   project.Get("description").String(),
+			Private:       project.Get("visibility").String() != "public",
+			DefaultBranch: project.Get("default_branch").String(),
+		}
+		if wikiEnabled(project) {
+			newRepo.Wiki = strings.TrimSuffix(newRepo.Source, ".git") + ".wiki.git"
+		}
+		if metadata {
+			// Older GitLab versions call topics tag_list
+			newRepo.Topics = []string{}
+			topics := project.Get("topics")
+			if !topics.Exists() {
+				topics = project.Get("tag_list")
+			}
+			for _, topic := range topics.Array() {
+				newRepo.Topics = append(newRepo.Topics, topic.String())
+			}
+		}
+		repositories = append(repositories, newRepo)
+	}
+
+	repositories = utils.FilterRepos(repositories, gitlab.filters.include, gitlab.filters.exclude)
+	return repositories, nil
+}
@@ -0,0 +1,322 @@
+package gitlab_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	mock "github.com/parinithshekar/gitsink/mocks/gitlab"
+	gitlab "github.com/parinithshekar/gitsink/plugins/input/gitlab"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+var (
+	envAccountID   = "TEST_GITLAB_ACCOUNT_ID"
+	envAccessToken = "TEST_GITLAB_ACCESS_TOKEN"
+
+	source config.Source = config.Source{
+		Type:        "gitlab",
+		BaseURL:     "https://gitlab.company.com",
+		AccountID:   envAccountID,
+		AccessToken: envAccessToken,
+		Kind:        "group/team",
+		Repositories: config.Repositories{
+			Include: []string{"/.*/"},
+			Exclude: []string{"/^docs$/"},
+		},
+	}
+)
+
+// newMockInput returns a gitlab plugin for the kind, calling the mock API with the token
+func newMockInput(t *testing.T, kind, token string) *gitlab.GitLab {
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, token)
+
+	tcSource := source
+	tcSource.Kind = kind
+	input, err := gitlab.New(tcSource)
+	if err != nil {
+		t.Fatal("Plugin initiation failed")
+	}
+	input.API = &mock.MockAPI{}
+	return input
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		EnvAccountIDSet, EnvAccessTokenSet, ExpectedError bool
+	}{
+		"No env vars":        {false, false, true},
+		"No accessToken env": {true, false, true},
+		"No accountID env ":  {false, true, true},
+		"Env vars set":       {true, true, false},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		tcSource := source
+		if !tc.EnvAccountIDSet {
+			tcSource.AccountID = "FAKE_ACCOUNT_ID"
+		}
+		if !tc.EnvAccessTokenSet {
+			tcSource.AccessToken = "FAKE_ACCESS_TOKEN"
+		}
+		var input interface{}
+		input, err := gitlab.New(tcSource)
+
+		actualError := (err != nil)
+		if actualError != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, tc.ExpectedError, actualError)
+		}
+		if _, typeOK := input.(plugins.Input); !typeOK {
+			t.Errorf("%v - Plugin type check failed", tcName)
+		}
+		if _, typeOK := input.(plugins.IssueSource); !typeOK {
+			t.Errorf("%v - Issue source type check failed", tcName)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	cases := map[string]struct {
+		Kind, Token   string
+		ExpectedError bool
+	}{
+		"Correct group":    {"group/team", "token", false},
+		"Correct user":     {"user/username", "token", false},
+		"Missing group":    {"group/nope", "token", true},
+		"Bad token":        {"group/team", "bad", true},
+		"Unsupported kind": {"project/team", "token", true},
+	}
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, tc.Kind, tc.Token)
+		ok, err := input.Authenticate()
+		if (err != nil) != tc.ExpectedError || ok == tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v %v", tcName, tc.ExpectedError, ok, err)
+		}
+	}
+}
+
+func TestRepositories(t *testing.T) {
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	cases := map[string]struct {
+		Kind, Token    string
+		Metadata       bool
+		ExpectedSlugs  []string
+		ExpectedTopics []string
+		ExpectedError  bool
+	}{
+		"Group with metadata": {"group/team", "token", true, []string{"app", "infra"}, []string{"go"}, false},
+		"Group":               {"group/team", "token", false, []string{"app", "infra"}, nil, false},
+		"Bad token":           {"group/team", "bad", false, nil, nil, true},
+		"Unsupported kind":    {"project/team", "token", false, nil, nil, true},
+	}
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, tc.Kind, tc.Token)
+		repositories, err := input.Repositories(tc.Metadata)
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+
+		slugs := []string{}
+		for _, repo := range repositories {
+			slugs = append(slugs, repo.Slug)
+			if !reflect.DeepEqual(repo.Topics, tc.ExpectedTopics) {
+				t.Errorf("%v - Expected topics: %v | Actual: %v", tcName, tc.ExpectedTopics, repo.Topics)
+			}
+		}
+		if !tc.ExpectedError && !reflect.DeepEqual(slugs, tc.ExpectedSlugs) {
+			t.Errorf("%v - Expected: %v | Actual: %v", tcName, tc.ExpectedSlugs, slugs)
+		}
+	}
+
+	// Private and internal projects are private on the target, and only app has a wiki
+	input := newMockInput(t, "group/team", "token")
+	repositories, _ := input.Repositories(false)
+	expectedWikis := map[string]string{"app": "https://gitlab.company.com/team/app.wiki.git", "infra": ""}
+	for _, repo := range repositories {
+		if !repo.Private {
+			t.Errorf("Expected %v to be private", repo.Slug)
+		}
+		if repo.Wiki != expectedWikis[repo.Slug] {
+			t.Errorf("Expected %v wiki: %v | Actual: %v", repo.Slug, expectedWikis[repo.Slug], repo.Wiki)
+		}
+	}
+}
+
+func TestIssues(t *testing.T) {
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	due := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	expected := []common.Issue{
+		{
+			ID:          1,
+			Title:       "Crash on start",
+			Description: "Stack trace attached",
+			State:       common.IssueClosed,
+			URL:         "https://gitlab.company.com/team/app/-/issues/1",
+			Author:      common.User{Name: "alice", DisplayName: "Alice"},
+			Labels:      []common.Label{{Name: "bug", Color: "d73a4a", Description: "Something is broken"}},
+			Milestone:   &common.Milestone{Title: "v1.0", State: common.IssueOpen, Due: &due},
+			Comments: []common.Comment{{
+				ID:      "101",
+				Author:  common.User{Name: "bob", DisplayName: "Bob"},
+				Body:    "Fixed in main",
+				Created: time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC),
+			}},
+			Created: time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:      2,
+			Title:   "Add dark mode",
+			State:   common.IssueOpen,
+			URL:     "https://gitlab.company.com/team/app/-/issues/2",
+			Author:  common.User{Name: "bob", DisplayName: "Bob"},
+			Labels:  []common.Label{{Name: "feature"}},
+			Created: time.Date(2020, 5, 2, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	cases := map[string]struct {
+		Source         string
+		ExpectedIssues []common.Issue
+		ExpectedError  bool
+	}{
+		"Project issues":  {"https://gitlab.company.com/team/app.git", expected, false},
+		"Missing project": {"https://gitlab.company.com/team/gone.git", nil, true},
+		"No project path": {"https://gitlab.company.com/", nil, true},
+	}
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, "group/team", "token")
+		issues, err := input.Issues(context.Background(), common.Repository{Slug: "app", Source: tc.Source})
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+			continue
+		}
+		if !reflect.DeepEqual(issues, tc.ExpectedIssues) {
+			t.Errorf("%v - Expected: %+v | Actual: %+v", tcName, tc.ExpectedIssues, issues)
+		}
+	}
+}
+
+func TestReleases(t *testing.T) {
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	input := newMockInput(t, "group/team", "token")
+	repo := common.Repository{Slug: "app", Source: "https://gitlab.company.com/team/app.git"}
+	releases, err := input.Releases(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []common.Release{
+		{
+			Tag: "v1.1.0", Title: "Dark mode", Notes: "Adds dark mode", Prerelease: true,
+			URL:     "https://gitlab.company.com/team/app/-/releases/v1.1.0",
+			Author:  common.User{Name: "bob", DisplayName: "Bob"},
+			Created: time.Date(2020, 6, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			Tag: "v1.0.0", Title: "First release", Notes: "Fixes the crash",
+			URL:    "https://gitlab.company.com/team/app/-/releases/v1.0.0",
+			Author: common.User{Name: "alice", DisplayName: "Alice"},
+			Assets: []common.Asset{
+				{ID: 1, Name: "app.tar.gz", URL: "https://gitlab.company.com/team/app/-/releases/v1.0.0/downloads/app.tar.gz"},
+				{ID: 2, Name: "notes.pdf", URL: "https://files.company.com/notes.pdf"},
+			},
+			Created: time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC),
+		},
+	}
+	if !reflect.DeepEqual(releases, expected) {
+		t.Errorf("Expected: %+v | Actual: %+v", expected, releases)
+	}
+
+	// The token is only sent to the GitLab instance
+	for _, asset := range releases[1].Assets {
+		contents, err := input.ReleaseAsset(context.Background(), repo, asset)
+		if err != nil {
+			t.Errorf("%v - Download failed: %v", asset.Name, err)
+			continue
+		}
+		contentBytes, _ := ioutil.ReadAll(contents)
+		contents.Close()
+		if string(contentBytes) != mock.Asset {
+			t.Errorf("%v - Expected: %v | Actual: %v", asset.Name, mock.Asset, string(contentBytes))
+		}
+	}
+}
+
+// runKey marks the contexts given to the plugin calls
+type runKey struct{}
+
+// recordingAPI records the run of the context of every request to the mock API
+type recordingAPI struct {
+	mock.MockAPI
+	runs []interface{}
+}
+
+func (api *recordingAPI) Do(req *http.Request) (*http.Response, error) {
+	api.runs = append(api.runs, req.Context().Value(runKey{}))
+	return api.MockAPI.Do(req)
+}
+
+func TestV2(t *testing.T) {
+	cases := map[string]struct {
+		Kind, Token  string
+		ExpectedKind error
+	}{
+		"Authenticated": {"group/team", "token", nil},
+		"Bad token":     {"group/team", "bad", v2.ErrFailed},
+		"Missing group": {"group/nope", "token", v2.ErrFailed},
+	}
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		input := newMockInput(t, tc.Kind, tc.Token)
+		recorder := &recordingAPI{}
+		input.API = recorder
+		ctx := context.WithValue(context.Background(), runKey{}, "call")
+
+		err := input.V2().Authenticate(ctx)
+		if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+			t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+		}
+		if err == nil {
+			repos, err := input.V2().Repositories(ctx, v2.ListOptions{})
+			if err != nil || len(repos) == 0 {
+				t.Errorf("%v - Expected repositories | Actual: %v, %v", tcName, repos, err)
+			}
+		}
+		if len(recorder.runs) == 0 {
+			t.Errorf("%v - Expected requests to the API", tcName)
+		}
+		for _, run := range recorder.runs {
+			if run != "call" {
+				t.Errorf("%v - Expected requests with the context of the call | Actual: %v", tcName, run)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	gjson "github.com/tidwall/gjson"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// projectPath gives the full path of the project from its clone URL, without the
+// relative URL root of the instance
+func (gitlab *GitLab) projectPath(repo common.Repository) (string, error) {
+	cloneURL, err := url.Parse(repo.Source)
+	if err != nil {
+		return "", err
+	}
+	path := cloneURL.Path
+
+	if base, err := url.Parse(gitlab.baseURL); err == nil {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(base.Path, "/"))
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if path == "" {
+		return "", fmt.Errorf("Project path not found in clone URL")
+	}
+	return path, nil
+}
+
+// parseUser reads a GitLab user, which has no email address in issue payloads
+func parseUser(userJSON gjson.Result) common.User {
+	return common.User{
+		Name:        userJSON.Get("username").String(),
+		DisplayName: userJSON.Get("name").String(),
+	}
+}
+
+// parseTime reads the ISO 8601 dates GitLab uses
+func parseTime(date gjson.Result) time.Time {
+	parsed, _ := time.Parse(time.RFC3339Nano, date.String())
+	return parsed.UTC()
+}
+
+// parseMilestone reads a milestone, converting GitLab's active state to open
+func parseMilestone(milestoneJSON gjson.Result) *common.Milestone {
+	if !milestoneJSON.IsObject() {
+		return nil
+	}
+	milestone := &common.Milestone{
+		Title:       milestoneJSON.Get("title").String(),
+		Description: milestoneJSON.Get("description").String(),
+		State:       common.IssueOpen,
+	}
+	if milestoneJSON.Get("state").String() == "closed" {
+		milestone.State = common.IssueClosed
+	}
+	if due, err := time.Parse("2006-01-02", milestoneJSON.Get("due_date").String()); err == nil {
+		milestone.Due = &due
+	}
+	return milestone
+}
+
+// Issues reads the issues of the project, oldest first, with their comments, labels and milestone
+// System notes, like label changes, are not comments
+func (gitlab *GitLab) Issues(ctx context.Context, repo common.Repository) ([]common.Issue, error) {
+
+	_, accessToken, err := gitlab.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := gitlab.projectPath(repo)
+	if err != nil {
+		return nil, err
+	}
+	projectURL := fmt.Sprintf("%v/projects/%v", gitlab.apiBaseURL, url.PathEscape(path))
+
+	values, err := gitlab.allPages(ctx, projectURL+"/issues?scope=all&order_by=created_at&sort=asc&with_labels_details=true", accessToken)
+	if err != nil {
+		gitlab.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+			"error":      err.Error(),
+		}).Errorf("Failed to get issues")
+		return nil, err
+	}
+
+	issues := []common.Issue{}
+	for _, issueJSON := range values {
+		issue := common.Issue{
+			ID:          issueJSON.Get("iid").Int(),
+			Title:       issueJSON.Get("title").String(),
+			Description: issueJSON.Get("description").String(),
+			State:       common.IssueOpen,
+			URL:         issueJSON.Get("web_url").String(),
+			Author:      parseUser(issueJSON.Get("author")),
+			Milestone:   parseMilestone(issueJSON.Get("milestone")),
+			Created:     parseTime(issueJSON.Get("created_at")),
+		}
+		if issueJSON.Get("state").String() == "closed" {
+			issue.State = common.IssueClosed
+		}
+		for _, label := range issueJSON.Get("labels").Array() {
+			// Labels are names only on instances without label details
+			if !label.IsObject() {
+				issue.Labels = append(issue.Labels, common.Label{Name: label.String()})
+				continue
+			}
+			issue.Labels = append(issue.Labels, common.Label{
+				Name:        label.Get("name").String(),
+				Color:       strings.TrimPrefix(label.Get("color").String(), "#"),
+				Description: label.Get("description").String(),
+			})
+		}
+
+		if issueJSON.Get("user_notes_count").Int() > 0 {
+			notesURL := fmt.Sprintf("%v/issues/%v/notes?order_by=created_at&sort=asc", projectURL, issue.ID)
+			notes, err := gitlab.allPages(ctx, notesURL, accessToken)
+			if err != nil {
+				gitlab.log.WithFields(logrus.Fields{
+					"repository": repo.Slug,
+					"issue":      issue.ID,
+					"error":      err.Error(),
+				}).Errorf("Failed to get issue comments")
+				return nil, err
+			}
+			for _, note := range notes {
+				if note.Get("system").Bool() {
+					continue
+				}
+				issue.Comments = append(issue.Comments, common.Comment{
+					ID:      note.Get("id").String(),
+					Author:  parseUser(note.Get("author")),
+					Body:    note.Get("body").String(),
+					Created: parseTime(note.Get("created_at")),
+				})
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
@@ -0,0 +1,92 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// Releases reads the releases of the project, with the files linked to them as assets
+// GitLab has no prereleases, so upcoming releases are marked as prereleases instead
+func (gitlab *GitLab) Releases(ctx context.Context, repo common.Repository) ([]common.Release, error) {
+
+	_, accessToken, err := gitlab.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := gitlab.projectPath(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := gitlab.allPages(ctx, fmt.Sprintf("%v/projects/%v/releases", gitlab.apiBaseURL, url.PathEscape(path)), accessToken)
+	if err != nil {
+		gitlab.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+			"error":      err.Error(),
+		}).Errorf("Failed to get releases")
+		return nil, err
+	}
+
+	releases := []common.Release{}
+	for _, releaseJSON := range values {
+		release := common.Release{
+			Tag:        releaseJSON.Get("tag_name").String(),
+			Title:      releaseJSON.Get("name").String(),
+			Notes:      releaseJSON.Get("description").String(),
+			Prerelease: releaseJSON.Get("upcoming_release").Bool(),
+			URL:        releaseJSON.Get("_links.self").String(),
+			Author:     parseUser(releaseJSON.Get("author")),
+			Created:    parseTime(releaseJSON.Get("created_at")),
+		}
+		// Source archives are generated from the tag, so only links are assets
+		for _, link := range releaseJSON.Get("assets.links").Array() {
+			assetURL := link.Get("direct_asset_url").String()
+			if assetURL == "" {
+				assetURL = link.Get("url").String()
+			}
+			release.Assets = append(release.Assets, common.Asset{
+				ID:   link.Get("id").Int(),
+				Name: link.Get("name").String(),
+				URL:  assetURL,
+			})
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// ReleaseAsset opens the contents of a file linked to a release
+// The access token is only sent to the GitLab instance, links can point at other hosts
+func (gitlab *GitLab) ReleaseAsset(ctx context.Context, repo common.Repository, asset common.Asset) (io.ReadCloser, error) {
+
+	_, accessToken, err := gitlab.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if base, err := url.Parse(gitlab.baseURL); err == nil && base.Host == request.URL.Host {
+		request.Header.Set("PRIVATE-TOKEN", accessToken)
+	}
+
+	response, err := gitlab.API.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+		return nil, fmt.Errorf("Request failed with status %v", response.StatusCode)
+	}
+	return response.Body, nil
+}
@@ -0,0 +1,49 @@
+package gitlab
+
+import (
+	"context"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+
+	common "github.com/parinithshekar/gitsink/common"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// native is the GitLab source with the methods of the second plugin interfaces
+// Each call makes its requests with its own context
+type native struct {
+	gitlab *GitLab
+}
+
+// V2 gives the source with the methods of the second plugin interfaces
+func (gitlab *GitLab) V2() v2.Input {
+	return native{gitlab: gitlab}
+}
+
+// Authenticate checks that the credentials can read the group or user of the kind
+func (source native) Authenticate(ctx context.Context) error {
+	if _, _, err := source.gitlab.Credentials(); err != nil {
+		return v2.Wrap("authenticate source", "", v2.ErrCredentials, err)
+	}
+	_, err := source.gitlab.authenticate(ctx)
+	return v2.Wrap("authenticate source", "", v2.ErrFailed, err)
+}
+
+// Repositories lists the repositories of the kind matching the filters
+func (source native) Repositories(ctx context.Context, options v2.ListOptions) ([]common.Repository, error) {
+	repos, err := source.gitlab.repositories(ctx, options.Metadata)
+	if err != nil {
+		return nil, v2.Wrap("list source repositories", "", v2.ErrFailed, err)
+	}
+	return repos, nil
+}
+
+// Auth gives the credentials of the source as git basic auth
+func (source native) Auth() (transport.AuthMethod, error) {
+	return v2.BasicAuth("source credentials", source.gitlab.Credentials)
+}
+
+// Capabilities tells what GitLab sources support
+func (source native) Capabilities() v2.Capabilities {
+	return source.gitlab.Capabilities()
+}
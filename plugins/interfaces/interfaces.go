@@ -1,7 +1,12 @@
 package interfaces
 
 import (
+	"context"
+	"io"
+	"net/http"
+
 	common "github.com/parinithshekar/gitsink/common"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
 )
 
 // Input lists the methods that an input plugin must implement
@@ -16,4 +21,97 @@ type Output interface {
 	Authenticate() (bool, error)
 	SyncCheck([]common.Repository) []common.Repository
 	Credentials() (string, string, error)
+	TargetBranch(string) (string, bool)
+	SetDefaultBranch(common.Repository, string) error
+}
+
+// HTTPClientProvider is implemented by plugins whose git traffic needs the same
+// TLS and proxy options as their API client
+type HTTPClientProvider interface {
+	HTTPClient() *http.Client
+}
+
+// WikiTarget is implemented by output plugins that can host the wiki of a repository
+// EnableWiki turns on the wiki of the target repository and returns its clone URL
+type WikiTarget interface {
+	EnableWiki(context.Context, common.Repository) (string, error)
+}
+
+// TargetFinder is implemented by output plugins that can look up target repositories without
+// creating them. Repositories missing on the target are returned without a target URL
+type TargetFinder interface {
+	FindTargets(context.Context, []common.Repository) ([]common.Repository, error)
+}
+
+// TargetCreator is implemented by output plugins that can create one target repository and tell
+// why it failed. A target repository that exists already is brought in line with the source
+type TargetCreator interface {
+	CreateTarget(context.Context, common.Repository) (common.Repository, error)
+}
+
+// OrphanTarget is implemented by output plugins that can retire mirrors whose source is gone
+// ReconcileOrphans is given every repository at the source and returns the retired mirrors
+type OrphanTarget interface {
+	ReconcileOrphans(context.Context, []common.Repository) ([]string, error)
+}
+
+// SourceLocker is implemented by input plugins that can make a source repository read-only
+// It is used once the target is verified after the final sync of a cut-over
+type SourceLocker interface {
+	LockRepository(context.Context, common.Repository) error
+}
+
+// PullRequestSource is implemented by input plugins that can read the pull requests of a repository
+// Only pull requests in the given states are returned
+type PullRequestSource interface {
+	PullRequests(context.Context, common.Repository, []string) ([]common.PullRequest, error)
+}
+
+// PullRequestTarget is implemented by output plugins that can recreate pull requests
+// Branch names of the pull requests are already the target branch names
+// Pull requests and comments already migrated by an earlier run are not created again
+type PullRequestTarget interface {
+	MigratePullRequests(context.Context, common.Repository, []common.PullRequest) (common.MigrationSummary, error)
+}
+
+// IssueSource is implemented by input plugins that can read the issues of a repository
+// Issues are returned oldest first, with their comments, labels and milestone
+type IssueSource interface {
+	Issues(context.Context, common.Repository) ([]common.Issue, error)
+}
+
+// IssueTarget is implemented by output plugins that can recreate issues
+// Issues and comments already migrated by an earlier run are not created again
+type IssueTarget interface {
+	MigrateIssues(context.Context, common.Repository, []common.Issue) (common.MigrationSummary, error)
+}
+
+// ReleaseSource is implemented by input plugins that can read the releases of a repository
+// ReleaseAsset opens the contents of an asset of one of the releases for download
+type ReleaseSource interface {
+	Releases(context.Context, common.Repository) ([]common.Release, error)
+	ReleaseAsset(context.Context, common.Repository, common.Asset) (io.ReadCloser, error)
+}
+
+// ReleaseTarget is implemented by output plugins that can recreate releases
+// Assets are downloaded through the source, which is nil for releases made from tags
+// Releases already on the target get the assets they are missing
+type ReleaseTarget interface {
+	MigrateReleases(context.Context, common.Repository, []common.Release, ReleaseSource) (common.MigrationSummary, error)
+}
+
+// UserMapper gives the target login of a source user, returning false if they are not mapped
+type UserMapper interface {
+	TargetUser(context.Context, common.User) (string, bool)
+}
+
+// UserMapperConsumer is implemented by output plugins that attribute source users on the target
+type UserMapperConsumer interface {
+	SetUserMapper(UserMapper)
+}
+
+// LoggerConsumer is implemented by plugins that log through the logger of the run they are used
+// in, which carries the run ID and integration. Plugins log to the root logger until it is set
+type LoggerConsumer interface {
+	SetLogger(pkg.Logger)
 }
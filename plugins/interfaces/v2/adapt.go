@@ -0,0 +1,163 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+	http "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// The adapters in this file are a temporary shim for the plugins not yet ported to this version.
+// The methods every plugin of the first interfaces has take no context, so the requests they make
+// are not stopped by the context of the call. The optional interfaces are given the context
+
+// BasicAuth gives the credentials of a plugin as git basic auth
+func BasicAuth(op string, credentials func() (string, string, error)) (transport.AuthMethod, error) {
+	accountID, accessToken, err := credentials()
+	if err != nil {
+		return nil, Wrap(op, "", ErrCredentials, err)
+	}
+	return &http.BasicAuth{Username: accountID, Password: accessToken}, nil
+}
+
+// input gives an input plugin of the first interfaces the methods of this version
+type input struct {
+	plugin plugins.Input
+}
+
+// FromInput gives the input plugin with the methods of this version, its own if it has them
+func FromInput(plugin plugins.Input) Input {
+	if provider, ok := plugin.(InputProvider); ok {
+		return provider.V2()
+	}
+	return &input{plugin: plugin}
+}
+
+// Authenticate checks that the credentials can read the repositories of the source
+func (adapter *input) Authenticate(ctx context.Context) error {
+	ok, err := adapter.plugin.Authenticate()
+	if err != nil {
+		return Wrap("authenticate source", "", ErrFailed, err)
+	}
+	if !ok {
+		return &OpError{Op: "authenticate source", Kind: ErrUnauthorized}
+	}
+	return nil
+}
+
+// Repositories lists the source repositories matching the filters of the integration
+func (adapter *input) Repositories(ctx context.Context, options ListOptions) ([]common.Repository, error) {
+	repos, err := adapter.plugin.Repositories(options.Metadata)
+	if err != nil {
+		return nil, Wrap("list source repositories", "", ErrFailed, err)
+	}
+	return repos, nil
+}
+
+// Auth gives the credentials of the source as git basic auth
+func (adapter *input) Auth() (transport.AuthMethod, error) {
+	return BasicAuth("source credentials", adapter.plugin.Credentials)
+}
+
+// Capabilities tells what the source supports, from the plugin or the interfaces it implements
+func (adapter *input) Capabilities() Capabilities {
+	if provider, ok := adapter.plugin.(CapabilityProvider); ok {
+		return provider.Capabilities()
+	}
+	_, pullRequests := adapter.plugin.(plugins.PullRequestSource)
+	_, issues := adapter.plugin.(plugins.IssueSource)
+	_, releases := adapter.plugin.(plugins.ReleaseSource)
+	_, lock := adapter.plugin.(plugins.SourceLocker)
+	return Capabilities{PullRequests: pullRequests, Issues: issues, Releases: releases, Lock: lock}
+}
+
+// output gives an output plugin of the first interfaces the methods of this version
+type output struct {
+	plugin plugins.Output
+}
+
+// FromOutput gives the output plugin with the methods of this version, its own if it has them
+func FromOutput(plugin plugins.Output) Output {
+	if provider, ok := plugin.(OutputProvider); ok {
+		return provider.V2()
+	}
+	return &output{plugin: plugin}
+}
+
+// Authenticate checks that the credentials can create and push to repositories of the target
+func (adapter *output) Authenticate(ctx context.Context) error {
+	ok, err := adapter.plugin.Authenticate()
+	if err != nil {
+		return Wrap("authenticate target", "", ErrFailed, err)
+	}
+	if !ok {
+		return &OpError{Op: "authenticate target", Kind: ErrUnauthorized}
+	}
+	return nil
+}
+
+// LookupTarget gives the repository with the URL of its target repository, for plugins that
+// can look it up without creating it
+func (adapter *output) LookupTarget(ctx context.Context, repo common.Repository) (common.Repository, error) {
+	finder, ok := adapter.plugin.(plugins.TargetFinder)
+	if !ok {
+		return repo, &OpError{Op: "look up target", Repository: repo.Slug, Kind: ErrUnsupported}
+	}
+	found, err := finder.FindTargets(ctx, []common.Repository{repo})
+	if err != nil {
+		return repo, Wrap("look up target", repo.Slug, ErrFailed, err)
+	}
+	if len(found) != 1 || found[0].Target == "" {
+		return repo, &OpError{Op: "look up target", Repository: repo.Slug, Kind: ErrNotFound}
+	}
+	return found[0], nil
+}
+
+// CreateTarget creates the target repository and gives the repository with its URL
+// Plugins that cannot tell why it failed only report that the target was not created
+func (adapter *output) CreateTarget(ctx context.Context, repo common.Repository) (common.Repository, error) {
+	if creator, ok := adapter.plugin.(plugins.TargetCreator); ok {
+		created, err := creator.CreateTarget(ctx, repo)
+		if err != nil {
+			return repo, Wrap("create target", repo.Slug, ErrFailed, err)
+		}
+		return created, nil
+	}
+
+	created := adapter.plugin.SyncCheck([]common.Repository{repo})
+	if len(created) != 1 {
+		return repo, &OpError{Op: "create target", Repository: repo.Slug, Kind: ErrFailed, Err: fmt.Errorf("Target repository not created")}
+	}
+	return created[0], nil
+}
+
+// Auth gives the credentials of the target as git basic auth
+func (adapter *output) Auth() (transport.AuthMethod, error) {
+	return BasicAuth("target credentials", adapter.plugin.Credentials)
+}
+
+// Capabilities tells what the target supports, from the plugin or the interfaces it implements
+func (adapter *output) Capabilities() Capabilities {
+	if provider, ok := adapter.plugin.(CapabilityProvider); ok {
+		return provider.Capabilities()
+	}
+	_, pullRequests := adapter.plugin.(plugins.PullRequestTarget)
+	_, issues := adapter.plugin.(plugins.IssueTarget)
+	_, releases := adapter.plugin.(plugins.ReleaseTarget)
+	_, wiki := adapter.plugin.(plugins.WikiTarget)
+	return Capabilities{PullRequests: pullRequests, Issues: issues, Releases: releases, Wiki: wiki}
+}
+
+// TargetBranch gives the target name of a source branch, returning false if it is not synced
+func (adapter *output) TargetBranch(branch string) (string, bool) {
+	return adapter.plugin.TargetBranch(branch)
+}
+
+// SetDefaultBranch makes the branch the default branch of the target repository
+func (adapter *output) SetDefaultBranch(ctx context.Context, repo common.Repository, branch string) error {
+	return Wrap("set default branch", repo.Slug, ErrFailed, adapter.plugin.SetDefaultBranch(repo, branch))
+}
@@ -0,0 +1,240 @@
+package v2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	http "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	bbcloud "github.com/parinithshekar/gitsink/plugins/input/bitbucket/cloud"
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	ghinput "github.com/parinithshekar/gitsink/plugins/input/github/public"
+	gitlab "github.com/parinithshekar/gitsink/plugins/input/gitlab"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
+)
+
+var (
+	envAccountID   = "TEST_V2_ACCOUNT_ID"
+	envAccessToken = "TEST_V2_ACCESS_TOKEN"
+)
+
+// fakeInput is a plugin of the first interfaces
+type fakeInput struct {
+	authenticated bool
+	err           error
+	metadata      bool
+}
+
+func (input *fakeInput) Authenticate() (bool, error) { return input.authenticated, input.err }
+func (input *fakeInput) Credentials() (string, string, error) {
+	if input.err != nil {
+		return "", "", input.err
+	}
+	return "username", "token", nil
+}
+func (input *fakeInput) Repositories(metadata bool) ([]common.Repository, error) {
+	input.metadata = metadata
+	return []common.Repository{{Slug: "app"}}, input.err
+}
+func (input *fakeInput) LockRepository(context.Context, common.Repository) error {
+	return nil
+}
+
+// fakeOutput is a plugin of the first interfaces whose target has the repositories in targets
+// It records the context its optional methods are given
+type fakeOutput struct {
+	targets map[string]string
+	ctx     context.Context
+}
+
+func (output *fakeOutput) Authenticate() (bool, error)          { return true, nil }
+func (output *fakeOutput) Credentials() (string, string, error) { return "username", "token", nil }
+func (output *fakeOutput) TargetBranch(branch string) (string, bool) {
+	return branch, true
+}
+func (output *fakeOutput) SetDefaultBranch(common.Repository, string) error { return nil }
+func (output *fakeOutput) SyncCheck(repos []common.Repository) []common.Repository {
+	var created []common.Repository
+	for _, repo := range repos {
+		if repo.Slug == "forbidden" {
+			continue
+		}
+		repo.Target = "https://target/" + repo.Slug + ".git"
+		created = append(created, repo)
+	}
+	return created
+}
+
+// finderOutput can also look up target repositories without creating them
+type finderOutput struct {
+	*fakeOutput
+}
+
+func (output finderOutput) FindTargets(ctx context.Context, repos []common.Repository) ([]common.Repository, error) {
+	output.ctx = ctx
+	for i := range repos {
+		repos[i].Target = output.targets[repos[i].Slug]
+	}
+	return repos, nil
+}
+
+func TestInput(t *testing.T) {
+	ctx := context.WithValue(context.Background(), struct{}{}, "run")
+
+	cases := map[string]struct {
+		Plugin       *fakeInput
+		ExpectedKind error
+	}{
+		"Authenticated":      {&fakeInput{authenticated: true}, nil},
+		"Rejected":           {&fakeInput{}, v2.ErrUnauthorized},
+		"Failed":             {&fakeInput{err: fmt.Errorf("Unsupported kind")}, v2.ErrFailed},
+		"Credentials unset":  {&fakeInput{err: v2.ErrCredentials}, v2.ErrCredentials},
+		"Rejected by status": {&fakeInput{err: statusError(401)}, v2.ErrUnauthorized},
+	}
+
+	for tcName, tc := range cases {
+		input := v2.FromInput(tc.Plugin)
+		err := input.Authenticate(ctx)
+		if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+			t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+		}
+	}
+
+	plugin := &fakeInput{authenticated: true}
+	input := v2.FromInput(plugin)
+	repos, err := input.Repositories(ctx, v2.ListOptions{Metadata: true})
+	if err != nil || len(repos) != 1 || !plugin.metadata {
+		t.Errorf("Expected repositories with metadata | Actual: %+v, %v", repos, err)
+	}
+
+	auth, err := input.Auth()
+	if !reflect.DeepEqual(auth, &http.BasicAuth{Username: "username", Password: "token"}) || err != nil {
+		t.Errorf("Expected basic auth of the credentials | Actual: %+v, %v", auth, err)
+	}
+
+	// Capabilities of plugins that do not tell them come from the interfaces they implement
+	if !reflect.DeepEqual(input.Capabilities(), v2.Capabilities{Lock: true}) {
+		t.Errorf("Unexpected capabilities: %+v", input.Capabilities())
+	}
+}
+
+func TestOutput(t *testing.T) {
+	ctx := context.WithValue(context.Background(), struct{}{}, "run")
+	plugin := &fakeOutput{targets: map[string]string{"app": "https://target/app.git"}}
+
+	cases := map[string]struct {
+		Plugin         plugins.Output
+		Lookup         bool
+		Slug           string
+		ExpectedTarget string
+		ExpectedKind   error
+	}{
+		"Found":              {finderOutput{plugin}, true, "app", "https://target/app.git", nil},
+		"Not found":          {finderOutput{plugin}, true, "new", "", v2.ErrNotFound},
+		"Lookup unsupported": {plugin, true, "app", "", v2.ErrUnsupported},
+		"Created":            {plugin, false, "new", "https://target/new.git", nil},
+		"Not created":        {plugin, false, "forbidden", "", v2.ErrFailed},
+	}
+
+	for tcName, tc := range cases {
+		output := v2.FromOutput(tc.Plugin)
+		var repo common.Repository
+		var err error
+		if tc.Lookup {
+			repo, err = output.LookupTarget(ctx, common.Repository{Slug: tc.Slug})
+		} else {
+			repo, err = output.CreateTarget(ctx, common.Repository{Slug: tc.Slug})
+		}
+		if (tc.ExpectedKind == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.ExpectedKind)) {
+			t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+		}
+		if repo.Slug != tc.Slug || repo.Target != tc.ExpectedTarget {
+			t.Errorf("%v - Expected target: %v | Actual: %+v", tcName, tc.ExpectedTarget, repo)
+		}
+		if _, finder := tc.Plugin.(finderOutput); finder && plugin.ctx != ctx {
+			t.Errorf("%v - Expected the plugin to get the context of the call", tcName)
+		}
+		plugin.ctx = nil
+	}
+}
+
+func TestProviders(t *testing.T) {
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	source := config.Source{Type: "bitbucket-server", BaseURL: "https://git.company.com", AccountID: envAccountID, AccessToken: envAccessToken, Kind: "project/TEST"}
+	server, serverErr := bbserver.New(source)
+	source.Type, source.Kind = "bitbucket-cloud", "user/username"
+	cloud, cloudErr := bbcloud.New(source)
+	target, targetErr := ghpublic.New(config.Target{Type: "github-public", AccountID: envAccountID, AccessToken: envAccessToken, Kind: "org/team"})
+	for _, err := range []error{serverErr, cloudErr, targetErr} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Plugins implementing this version natively are used as they are, not adapted
+	inputs := map[string]plugins.Input{"bitbucket-server": server, "bitbucket-cloud": cloud}
+	for name, plugin := range inputs {
+		native := plugin.(v2.InputProvider).V2()
+		if reflect.TypeOf(v2.FromInput(plugin)) != reflect.TypeOf(native) {
+			t.Errorf("%v - Expected the native implementation | Actual: %T", name, v2.FromInput(plugin))
+		}
+	}
+	if reflect.TypeOf(v2.FromOutput(target)) != reflect.TypeOf(target.V2()) {
+		t.Errorf("github-public - Expected the native implementation | Actual: %T", v2.FromOutput(target))
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	source := func(sourceType, kind string) config.Source {
+		return config.Source{Type: sourceType, BaseURL: "https://git.company.com", AccountID: envAccountID, AccessToken: envAccessToken, Kind: kind}
+	}
+	server, serverErr := bbserver.New(source("bitbucket-server", "project/TEST"))
+	cloud, cloudErr := bbcloud.New(source("bitbucket-cloud", "user/username"))
+	lab, labErr := gitlab.New(source("gitlab", "group/team"))
+	github, githubErr := ghinput.New(source("github-public", "org/team"))
+	target, targetErr := ghpublic.New(config.Target{Type: "github-public", AccountID: envAccountID, AccessToken: envAccessToken, Kind: "org/team"})
+	for _, err := range []error{serverErr, cloudErr, labErr, githubErr, targetErr} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Capabilities the plugins tell must match the optional interfaces they implement
+	inputs := map[string]plugins.Input{"bitbucket-server": server, "bitbucket-cloud": cloud, "gitlab": lab, "github-public": github}
+	for name, plugin := range inputs {
+		capabilities := v2.FromInput(plugin).Capabilities()
+		_, pullRequests := plugin.(plugins.PullRequestSource)
+		_, issues := plugin.(plugins.IssueSource)
+		_, releases := plugin.(plugins.ReleaseSource)
+		_, lock := plugin.(plugins.SourceLocker)
+		if capabilities.PullRequests != pullRequests || capabilities.Issues != issues || capabilities.Releases != releases || capabilities.Lock != lock {
+			t.Errorf("%v - Capabilities do not match the plugin: %+v", name, capabilities)
+		}
+	}
+
+	capabilities := v2.FromOutput(target).Capabilities()
+	_, pullRequests := interface{}(target).(plugins.PullRequestTarget)
+	_, issues := interface{}(target).(plugins.IssueTarget)
+	_, releases := interface{}(target).(plugins.ReleaseTarget)
+	_, wiki := interface{}(target).(plugins.WikiTarget)
+	if capabilities.PullRequests != pullRequests || capabilities.Issues != issues || capabilities.Releases != releases || capabilities.Wiki != wiki {
+		t.Errorf("github-public - Capabilities do not match the plugin: %+v", capabilities)
+	}
+}
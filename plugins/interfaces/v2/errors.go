@@ -0,0 +1,109 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error represents a class of failure of a plugin operation
+type Error string
+
+// Error returns the error as a string
+func (e Error) Error() string { return string(e) }
+
+const (
+	// ErrCredentials is returned when the credentials of the plugin are not set
+	ErrCredentials = Error("Credentials not set")
+	// ErrUnauthorized is returned when the credentials are rejected
+	ErrUnauthorized = Error("Credentials rejected")
+	// ErrForbidden is returned when the account lacks permission for the resource
+	ErrForbidden = Error("Access to the resource denied")
+	// ErrNotFound is returned when the kind, repository or resource does not exist
+	ErrNotFound = Error("Resource not found")
+	// ErrUnsupported is returned when the plugin cannot do the operation
+	ErrUnsupported = Error("Operation not supported")
+	// ErrUnavailable is returned when the service failed or limited requests, so retrying can help
+	ErrUnavailable = Error("Service unavailable")
+	// ErrCanceled is returned when the run was canceled or timed out during the operation
+	ErrCanceled = Error("Operation canceled")
+	// ErrFailed is returned for any other failure
+	ErrFailed = Error("Operation failed")
+)
+
+// OpError is a failed operation of a plugin, with the class of failure in Kind
+type OpError struct {
+	Op         string
+	Repository string
+	Kind       Error
+	Err        error
+}
+
+// Error describes the operation and why it failed
+func (e *OpError) Error() string {
+	op := e.Op
+	if e.Repository != "" {
+		op += " " + e.Repository
+	}
+	if e.Err == nil {
+		return fmt.Sprintf("%v: %v", op, e.Kind)
+	}
+	return fmt.Sprintf("%v: %v", op, e.Err)
+}
+
+// Unwrap gives the error of the plugin
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the class of failure, so callers can use errors.Is(err, ErrNotFound)
+func (e *OpError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// StatusError is implemented by errors of API responses, to classify them by status code
+type StatusError interface {
+	HTTPStatus() int
+}
+
+// StatusKind gives the class of failure of an unsuccessful status code
+func StatusKind(statusCode int) Error {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ErrForbidden
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return ErrUnavailable
+	default:
+		return ErrFailed
+	}
+}
+
+// Wrap gives err as an *OpError of the operation. Errors that are already classified keep
+// their class, others get fallback unless their status code or cancellation tells more
+func Wrap(op, repository string, fallback Error, err error) error {
+	if err == nil {
+		return nil
+	}
+	var opError *OpError
+	if errors.As(err, &opError) {
+		return err
+	}
+
+	kind := fallback
+	var statusError StatusError
+	var class Error
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		kind = ErrCanceled
+	case errors.As(err, &statusError):
+		kind = StatusKind(statusError.HTTPStatus())
+	case errors.As(err, &class):
+		kind = class
+	}
+	return &OpError{Op: op, Repository: repository, Kind: kind, Err: err}
+}
@@ -0,0 +1,60 @@
+package v2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// statusError is an API error carrying its status code
+type statusError int
+
+func (err statusError) Error() string   { return fmt.Sprintf("Request failed with status %v", int(err)) }
+func (err statusError) HTTPStatus() int { return int(err) }
+
+func TestWrap(t *testing.T) {
+	classified := &v2.OpError{Op: "create target", Repository: "app", Kind: v2.ErrForbidden}
+
+	cases := map[string]struct {
+		Err          error
+		ExpectedKind error
+	}{
+		"Unclassified":        {fmt.Errorf("Something broke"), v2.ErrFailed},
+		"Not found status":    {statusError(404), v2.ErrNotFound},
+		"Unauthorized status": {statusError(401), v2.ErrUnauthorized},
+		"Rate limited":        {statusError(429), v2.ErrUnavailable},
+		"Server error":        {statusError(502), v2.ErrUnavailable},
+		"Canceled":            {fmt.Errorf("Push failed: %w", context.Canceled), v2.ErrCanceled},
+		"Timed out":           {context.DeadlineExceeded, v2.ErrCanceled},
+		"Class":               {v2.ErrUnsupported, v2.ErrUnsupported},
+		"Already classified":  {classified, v2.ErrForbidden},
+	}
+
+	for tcName, tc := range cases {
+		err := v2.Wrap("look up target", "app", v2.ErrFailed, tc.Err)
+		if !errors.Is(err, tc.ExpectedKind) {
+			t.Errorf("%v - Expected kind: %v | Actual: %v", tcName, tc.ExpectedKind, err)
+		}
+		// The error of the plugin is still reachable
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("%v - Expected wrapped error: %v | Actual: %v", tcName, tc.Err, err)
+		}
+	}
+
+	if v2.Wrap("look up target", "app", v2.ErrFailed, nil) != nil {
+		t.Errorf("Expected no error for a successful operation")
+	}
+
+	// Bitbucket Server errors keep their own classes besides the plugin one
+	err := v2.Wrap("list source repositories", "", v2.ErrFailed, &bbserver.APIError{StatusCode: 403})
+	if !errors.Is(err, v2.ErrForbidden) || !errors.Is(err, bbserver.ErrForbidden) {
+		t.Errorf("Expected forbidden Bitbucket Server error | Actual: %v", err)
+	}
+	if err.Error() != "list source repositories: "+(&bbserver.APIError{StatusCode: 403}).Error() {
+		t.Errorf("Unexpected message: %v", err.Error())
+	}
+}
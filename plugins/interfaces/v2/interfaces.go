@@ -0,0 +1,86 @@
+package v2
+
+import (
+	"context"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// Input lists the methods that an input plugin must implement
+// Failures are returned as *OpError, so callers can tell them apart with errors.Is
+type Input interface {
+	// Authenticate checks that the credentials can read the repositories of the source
+	Authenticate(context.Context) error
+	// Repositories lists the source repositories matching the filters of the integration
+	Repositories(context.Context, ListOptions) ([]common.Repository, error)
+	// Auth gives the authentication of git operations against the source
+	Auth() (transport.AuthMethod, error)
+	// Capabilities tells what the source supports besides git data
+	Capabilities() Capabilities
+}
+
+// Output lists the methods that an output plugin must implement
+// Failures are returned as *OpError, so callers can tell them apart with errors.Is
+type Output interface {
+	// Authenticate checks that the credentials can create and push to repositories of the target
+	Authenticate(context.Context) error
+	// LookupTarget gives the repository with the URL of its target repository, without creating
+	// or changing anything. A missing target repository is an ErrNotFound
+	LookupTarget(context.Context, common.Repository) (common.Repository, error)
+	// CreateTarget creates the target repository and gives the repository with its URL
+	// A target repository that exists already is brought in line with the source and given
+	CreateTarget(context.Context, common.Repository) (common.Repository, error)
+	// Auth gives the authentication of git operations against the target
+	Auth() (transport.AuthMethod, error)
+	// Capabilities tells what the target supports besides git data
+	Capabilities() Capabilities
+	// TargetBranch gives the target name of a source branch, returning false if it is not synced
+	TargetBranch(string) (string, bool)
+	// SetDefaultBranch makes the branch the default branch of the target repository
+	SetDefaultBranch(context.Context, common.Repository, string) error
+}
+
+// ListOptions tune the repositories listed by an input plugin
+type ListOptions struct {
+	// Metadata also gets the topics of the repositories, which takes more requests on some sources
+	Metadata bool
+}
+
+// Capabilities tell what a plugin supports besides git data
+// Input plugins read what they support, output plugins recreate it
+type Capabilities struct {
+	// LFS objects of the repositories can be transferred
+	LFS bool
+	// PullRequests with their comments and reviewers can be migrated
+	PullRequests bool
+	// Issues with their comments, labels and milestones can be migrated
+	Issues bool
+	// Releases and their assets can be migrated
+	Releases bool
+	// Wiki repositories can be synced
+	Wiki bool
+	// BranchProtection rules can be applied to branches
+	BranchProtection bool
+	// Lock makes repositories read-only once a cut-over is done
+	Lock bool
+}
+
+// CapabilityProvider is implemented by plugins that tell their capabilities themselves
+// Capabilities of other plugins are worked out from the optional interfaces they implement
+type CapabilityProvider interface {
+	Capabilities() Capabilities
+}
+
+// InputProvider is implemented by input plugins of the first interfaces that also implement
+// this version natively, the method names being the same in both
+type InputProvider interface {
+	V2() Input
+}
+
+// OutputProvider is implemented by output plugins of the first interfaces that also implement
+// this version natively
+type OutputProvider interface {
+	V2() Output
+}
@@ -0,0 +1,33 @@
+package git
+
+import (
+	"context"
+	"time"
+)
+
+// detached keeps the values of a context, like its logger, without its cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// context gives the context of the repository being synced, the background context outside a sync
+func (gitClient Client) context() context.Context {
+	if gitClient.ctx == nil {
+		return context.Background()
+	}
+	return gitClient.ctx
+}
+
+// repositoryContext gives the context a repository is synced in. Canceling the run does not
+// stop a repository that is being synced, only the repository timeout does
+func (gitClient Client) repositoryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	repoCtx := context.Context(detached{ctx})
+	if gitClient.options.RepositoryTimeout > 0 {
+		return context.WithTimeout(repoCtx, gitClient.options.RepositoryTimeout)
+	}
+	return context.WithCancel(repoCtx)
+}
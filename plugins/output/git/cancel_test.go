@@ -0,0 +1,113 @@
+package git_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/parinithshekar/gitsink/common"
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
+)
+
+// pktLine frames a line of the git protocol with its length
+func pktLine(line string) string {
+	return fmt.Sprintf("%04x%v", len(line)+4, line)
+}
+
+func TestSyncReposCanceled(t *testing.T) {
+	os.Setenv(envSourceAccountID, "username")
+	os.Setenv(envSourceAccessToken, "token")
+	os.Setenv(envTargetAccountID, "username")
+	os.Setenv(envTargetAccessToken, "token")
+	defer os.Unsetenv(envSourceAccountID)
+	defer os.Unsetenv(envSourceAccessToken)
+	defer os.Unsetenv(envTargetAccountID)
+	defer os.Unsetenv(envTargetAccessToken)
+
+	// SyncRepos works in syncDirectory under the working directory
+	dir, err := ioutil.TempDir("", "gitsink-cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	done := make(chan struct{})
+	// The source advertises a branch but never sends it, so clones only end with the repository timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/info/refs") {
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+			fmt.Fprint(w, pktLine("# service=git-upload-pack\n")+"0000")
+			fmt.Fprint(w, pktLine(strings.Repeat("a", 40)+" refs/heads/master\x00ofs-delta\n")+"0000")
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	repos := []common.Repository{
+		{Slug: "first", Source: server.URL + "/first.git", Target: dir + "/first.git"},
+		{Slug: "second", Source: server.URL + "/second.git", Target: dir + "/second.git"},
+	}
+
+	cases := map[string]struct {
+		Ctx             context.Context
+		Timeout         time.Duration
+		ExpectedSkipped []string
+		ExpectedError   string
+	}{
+		"Canceled run": {
+			Ctx:             canceled,
+			ExpectedSkipped: []string{"first", "second"},
+		},
+		"Repository timeout": {
+			Ctx:           context.Background(),
+			Timeout:       50 * time.Millisecond,
+			ExpectedError: context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for tcName, tc := range cases {
+		input, _ := bbserver.New(source)
+		public, _ := ghpublic.New(target)
+		gitClient := git.New(input, localOutput{Public: public}, "test-integration", git.Options{RepositoryTimeout: tc.Timeout})
+
+		report := gitClient.SyncRepos(tc.Ctx, repos)
+		if report.Canceled != (tc.ExpectedSkipped != nil) || !reflect.DeepEqual(report.Skipped, tc.ExpectedSkipped) {
+			t.Errorf("%v - Expected skipped: %v | Actual: %v (canceled %v)", tcName, tc.ExpectedSkipped, report.Skipped, report.Canceled)
+		}
+		if tc.ExpectedError == "" {
+			if len(report.Repositories) != 0 {
+				t.Errorf("%v - Expected no repositories synced | Actual: %+v", tcName, report.Repositories)
+			}
+			continue
+		}
+		// Every repository gets its own timeout, so a slow one does not fail the others
+		if len(report.Repositories) != len(repos) {
+			t.Fatalf("%v - Expected %v repositories | Actual: %+v", tcName, len(repos), report.Repositories)
+		}
+		for _, repoReport := range report.Repositories {
+			if !strings.Contains(repoReport.Error, tc.ExpectedError) {
+				t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, repoReport.Error)
+			}
+		}
+	}
+}
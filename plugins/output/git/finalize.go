@@ -0,0 +1,91 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// Cutover records the end of a migration for one repository
+type Cutover struct {
+	Verified   bool       `json:"verified"`
+	Mismatches []RefDrift `json:"mismatches,omitempty"`
+	Locked     bool       `json:"locked"`
+	LockedAt   *time.Time `json:"lockedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Finalize runs the last sync of a cut-over, verifies that the target has every synced ref of
+// the source, and then makes the source repositories read-only. Repositories that were not
+// completely synced, or whose refs do not match, are left writable, as are all of them when ctx
+// is canceled
+func (gitClient Client) Finalize(ctx context.Context, repos []common.Repository) Report {
+
+	report := gitClient.SyncRepos(ctx, repos)
+	locker, canLock := gitClient.input.(plugins.SourceLocker)
+
+	bySlug := map[string]common.Repository{}
+	for _, repo := range repos {
+		bySlug[repo.Slug] = repo
+	}
+
+	for i := range report.Repositories {
+		repoReport := &report.Repositories[i]
+		repo := bySlug[repoReport.Slug]
+		cutover := &Cutover{}
+
+		if repoReport.Failed() {
+			cutover.Error = "Final sync incomplete, source not locked"
+			repoReport.Cutover = cutover
+			continue
+		}
+		repoReport.Cutover = cutover
+		if ctx.Err() != nil {
+			cutover.Error = "Run canceled, source not locked"
+			continue
+		}
+
+		drift, err := gitClient.VerifyRefs(repo)
+		if err != nil {
+			cutover.Error = fmt.Sprintf("Verification failed: %v", err)
+			continue
+		}
+		// Branches and tags only on the target do not stop the cut-over
+		for _, refDrift := range drift {
+			if refDrift.Drift != DriftExtra {
+				cutover.Mismatches = append(cutover.Mismatches, refDrift)
+			}
+		}
+		if len(cutover.Mismatches) > 0 {
+			cutover.Error = "Refs differ between source and target, source not locked"
+			continue
+		}
+		cutover.Verified = true
+
+		if !canLock {
+			cutover.Error = "Source does not support locking repositories"
+			continue
+		}
+		err = locker.LockRepository(ctx, repo)
+		if err != nil {
+			cutover.Error = fmt.Sprintf("Locking source failed: %v", err)
+			continue
+		}
+		lockedAt := time.Now()
+		cutover.Locked = true
+		cutover.LockedAt = &lockedAt
+
+		gitClient.log.WithFields(logrus.Fields{
+			"integration": gitClient.integrationName,
+			"repository":  repo.Slug,
+		}).Infof("Repository cut over, source locked")
+	}
+
+	report.Finished = time.Now()
+	return report
+}
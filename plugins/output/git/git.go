@@ -1,53 +1,133 @@
 package git
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	nethttp "net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	git "github.com/go-git/go-git/v5"
 	config "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	transportgit "github.com/go-git/go-git/v5/plumbing/transport"
+	client "github.com/go-git/go-git/v5/plumbing/transport/client"
 	http "github.com/go-git/go-git/v5/plumbing/transport/http"
 	logrus "github.com/sirupsen/logrus"
 
 	common "github.com/parinithshekar/gitsink/common"
+	gitsinkconfig "github.com/parinithshekar/gitsink/common/config"
+	transport "github.com/parinithshekar/gitsink/common/transport"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
 	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+	lfs "github.com/parinithshekar/gitsink/plugins/output/git/lfs"
 	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
 )
 
 var (
-	log = logger.New()
+	log = logger.Root()
+
+	// gitTransport sends the git traffic of every integration, by source host
+	gitTransport        = &transport.Router{}
+	installGitTransport sync.Once
 )
 
+// Options tune how the repositories are pushed to the target
+type Options struct {
+	// PushChunkSize is the number of commits per push when a new branch is too large to push at once
+	PushChunkSize int
+	// PullRequestStates are the states of the pull requests migrated, none are if empty
+	PullRequestStates []string
+	// Issues turns on migration of issues with their comments, labels and milestones
+	Issues bool
+	// Releases turns on migration of releases and their assets
+	Releases bool
+	// ReleasesFromTags also makes releases of annotated tags that have none on the source
+	ReleasesFromTags bool
+	// TwoWay also fast-forwards source branches to target changes, and reports diverged branches
+	TwoWay bool
+	// BranchSides give the authoritative side of source branches in a two-way sync
+	BranchSides []gitsinkconfig.BranchSide
+	// Wiki turns on syncing the source wiki to the wiki of the target repository
+	Wiki bool
+	// RepositoryTimeout stops the sync of a repository that takes longer, no limit when zero
+	RepositoryTimeout time.Duration
+}
+
 // Client struct has the output plugin associated with the integration
 type Client struct {
 	input           plugins.Input
 	output          plugins.Output
 	integrationName string
+	options         Options
+	ctx             context.Context
+	log             pkg.Logger
+}
+
+// SetLogger has the client log through the logger of the run it is used in
+func (gitClient *Client) SetLogger(log pkg.Logger) {
+	gitClient.log = log
+}
+
+// syncDirectory holds the directories of the integrations under the working directory
+const syncDirectory = "syncDirectory"
+
+// directoryName gives the name of the directory of an integration, which has no spaces
+func directoryName(integrationName string) string {
+	return strings.Join(strings.Split(integrationName, " "), "-")
+}
+
+// Directory gives the directory the repositories of an integration are synced in,
+// relative to the working directory of the run
+func Directory(integrationName string) string {
+	return filepath.Join(syncDirectory, directoryName(integrationName))
 }
 
 // New returns a new git instance to perform git functions
-func New(input plugins.Input, output plugins.Output, integrationName string) *Client {
+func New(input plugins.Input, output plugins.Output, integrationName string, options Options) *Client {
 	gitClient := new(Client)
+	gitClient.log = log
 
 	gitClient.input = input
 	gitClient.output = output
+	gitClient.options = options
 
-	integrationNameSplit := strings.Split(integrationName, " ")
-	gitClient.integrationName = strings.Join(integrationNameSplit, "-")
+	gitClient.integrationName = directoryName(integrationName)
 
 	return gitClient
 }
 
-// SyncRepos clones repositories locally and syncs
-func (gitClient Client) SyncRepos(repos []common.Repository) {
+// sourceAuth gives the authentication of git operations against the source
+func (gitClient Client) sourceAuth() (transportgit.AuthMethod, error) {
+	return v2.FromInput(gitClient.input).Auth()
+}
+
+// targetAuth gives the authentication of git operations against the target
+func (gitClient Client) targetAuth() (transportgit.AuthMethod, error) {
+	return v2.FromOutput(gitClient.output).Auth()
+}
+
+// SyncRepos clones repositories locally and syncs, reporting what could not be synced
+// Once ctx is canceled no more repositories are started, and the report lists them as skipped
+func (gitClient Client) SyncRepos(ctx context.Context, repos []common.Repository) Report {
+
+	report := Report{
+		Integration: gitClient.integrationName,
+		Started:     time.Now(),
+	}
 
 	// Make and enter syncDirectory if it does not exist
-	if _, err := os.Stat("syncDirectory"); os.IsNotExist(err) {
-		os.Mkdir("syncDirectory", 0777)
+	if _, err := os.Stat(syncDirectory); os.IsNotExist(err) {
+		os.Mkdir(syncDirectory, 0777)
 	}
-	os.Chdir("syncDirectory")
+	os.Chdir(syncDirectory)
 
 	// Make and enter directory for the current integration
 	if _, err := os.Stat(gitClient.integrationName); os.IsNotExist(err) {
@@ -56,54 +136,85 @@ func (gitClient Client) SyncRepos(repos []common.Repository) {
 	os.Chdir(gitClient.integrationName)
 
 	// Get authentication object for source
-	sourceAccountID, sourceAccessToken, err := gitClient.input.Credentials()
+	sourceAuth, err := gitClient.sourceAuth()
 	if err != nil {
-		log.Errorf("Failed to fetch source credentials")
-		return
-	}
-	sourceAuth := http.BasicAuth{
-		Username: sourceAccountID,
-		Password: sourceAccessToken,
+		gitClient.log.Errorf("Failed to fetch source credentials")
+		os.Chdir("../..")
+		report.Finished = time.Now()
+		return report
 	}
 
-	for _, repo := range repos {
+	gitClient.installTransport(repos)
+
+	// The repository being synced when the run is canceled is finished, or stopped by its timeout
+	cancel := func() {}
+	for i, repo := range repos {
+		cancel()
+		if ctx.Err() != nil {
+			report.Canceled = true
+			for _, skipped := range repos[i:] {
+				report.Skipped = append(report.Skipped, skipped.Slug)
+			}
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"skipped":     len(report.Skipped),
+			}).Warningf("Run canceled, remaining repositories not synced")
+			break
+		}
+		gitClient.ctx, cancel = gitClient.repositoryContext(ctx)
+
+		repoReport := RepositoryReport{Slug: repo.Slug}
 
 		var localRepo *git.Repository
-		if _, err := os.Stat(repo.Slug); os.IsNotExist(err) {
+		if _, statErr := os.Stat(repo.Slug); os.IsNotExist(statErr) {
 			// Clone the repo
 			co := git.CloneOptions{
 				URL:  repo.Source,
-				Auth: &sourceAuth,
+				Auth: sourceAuth,
 			}
 			co.Validate()
-			localRepo, _ = git.PlainClone(repo.Slug, false, &co)
+			start := time.Now()
+			localRepo, err = git.PlainCloneContext(gitClient.context(), repo.Slug, false, &co)
+			metrics.ObserveGit(gitClient.integrationName, metrics.OperationClone, start)
 		} else {
 			localRepo, err = git.PlainOpen(repo.Slug)
 		}
+		if err != nil {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"error":       err.Error(),
+			}).Errorf("Failed to get local copy of repository")
+			repoReport.Error = err.Error()
+			report.Repositories = append(report.Repositories, repoReport)
+			continue
+		}
 
 		_, err = localRepo.CreateRemote(&config.RemoteConfig{
 			Name: "target",
 			URLs: []string{repo.Target},
 		})
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			gitClient.log.WithFields(logrus.Fields{
 				"integration": gitClient.integrationName,
 				"repository":  repo.Slug,
 				"error":       err.Error(),
 			}).Errorf("Failed to set target remote")
 		}
 
-		failedTags, err := gitClient.SyncTags(repo, localRepo)
+		failedTags, tagRejections, err := gitClient.SyncTags(repo, localRepo)
+		repoReport.FailedTags = failedTags
+		repoReport.Rejections = append(repoReport.Rejections, tagRejections...)
 		if err != nil {
 			if failedTags != nil {
-				log.WithFields(logrus.Fields{
+				gitClient.log.WithFields(logrus.Fields{
 					"integration": gitClient.integrationName,
 					"repository":  repo.Slug,
 					"failedTags":  failedTags,
 					"error":       err.Error(),
 				}).Warningf("Some tags not synced")
 			} else {
-				log.WithFields(logrus.Fields{
+				gitClient.log.WithFields(logrus.Fields{
 					"integration": gitClient.integrationName,
 					"repository":  repo.Slug,
 					"error":       err.Error(),
@@ -111,17 +222,20 @@ func (gitClient Client) SyncRepos(repos []common.Repository) {
 			}
 		}
 
-		failedBranches, err := gitClient.SyncBranches(repo, localRepo)
+		failedBranches, branchRejections, divergences, err := gitClient.SyncBranches(repo, localRepo)
+		repoReport.FailedBranches = failedBranches
+		repoReport.Rejections = append(repoReport.Rejections, branchRejections...)
+		repoReport.Divergences = divergences
 		if err != nil {
 			if failedBranches != nil {
-				log.WithFields(logrus.Fields{
+				gitClient.log.WithFields(logrus.Fields{
 					"integration":    gitClient.integrationName,
 					"repository":     repo.Slug,
 					"failedBranches": failedBranches,
 					"error":          err.Error(),
 				}).Warningf("Some branches not synced")
 			} else {
-				log.WithFields(logrus.Fields{
+				gitClient.log.WithFields(logrus.Fields{
 					"integration": gitClient.integrationName,
 					"repository":  repo.Slug,
 					"error":       err.Error(),
@@ -129,61 +243,181 @@ func (gitClient Client) SyncRepos(repos []common.Repository) {
 			}
 		}
 
+		summary, err := gitClient.SyncLFS(repo, localRepo)
+		repoReport.LFS = summary
+		if err != nil {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"lfs":         summary,
+				"error":       err.Error(),
+			}).Warningf("Failed to sync LFS objects")
+		} else if summary != nil {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"lfs":         summary,
+			}).Infof("LFS objects synced")
+		}
+
+		if gitClient.options.Wiki && repo.Wiki != "" {
+			err := gitClient.SyncWiki(repo)
+			if err != nil {
+				repoReport.WikiError = err.Error()
+				gitClient.log.WithFields(logrus.Fields{
+					"integration": gitClient.integrationName,
+					"repository":  repo.Slug,
+					"error":       err.Error(),
+				}).Warningf("Failed to sync wiki")
+			}
+		}
+
+		if gitClient.options.Releases {
+			releases, err := gitClient.MigrateReleases(repo, localRepo, failedTags)
+			repoReport.Releases = releases
+			if err != nil {
+				gitClient.log.WithFields(logrus.Fields{
+					"integration": gitClient.integrationName,
+					"repository":  repo.Slug,
+					"releases":    releases,
+					"error":       err.Error(),
+				}).Warningf("Failed to migrate releases")
+			} else {
+				gitClient.log.WithFields(logrus.Fields{
+					"integration": gitClient.integrationName,
+					"repository":  repo.Slug,
+					"releases":    releases,
+				}).Infof("Releases migrated")
+			}
+		}
+
+		if len(gitClient.options.PullRequestStates) > 0 {
+			pullRequests, err := gitClient.MigratePullRequests(repo)
+			repoReport.PullRequests = pullRequests
+			if err != nil {
+				gitClient.log.WithFields(logrus.Fields{
+					"integration":  gitClient.integrationName,
+					"repository":   repo.Slug,
+					"pullRequests": pullRequests,
+					"error":        err.Error(),
+				}).Warningf("Failed to migrate pull requests")
+			} else {
+				gitClient.log.WithFields(logrus.Fields{
+					"integration":  gitClient.integrationName,
+					"repository":   repo.Slug,
+					"pullRequests": pullRequests,
+				}).Infof("Pull requests migrated")
+			}
+		}
+
+		if gitClient.options.Issues {
+			issues, err := gitClient.MigrateIssues(repo)
+			repoReport.Issues = issues
+			if err != nil {
+				gitClient.log.WithFields(logrus.Fields{
+					"integration": gitClient.integrationName,
+					"repository":  repo.Slug,
+					"issues":      issues,
+					"error":       err.Error(),
+				}).Warningf("Failed to migrate issues")
+			} else {
+				gitClient.log.WithFields(logrus.Fields{
+					"integration": gitClient.integrationName,
+					"repository":  repo.Slug,
+					"issues":      issues,
+				}).Infof("Issues migrated")
+			}
+		}
+
 		err = localRepo.DeleteRemote("target")
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			gitClient.log.WithFields(logrus.Fields{
 				"integration": gitClient.integrationName,
 				"repository":  repo.Slug,
 				"error":       err.Error(),
 			}).Warningf("Failed to remove old remote")
 		}
+
+		report.Repositories = append(report.Repositories, repoReport)
+	}
+	cancel()
+
+	for _, repoReport := range report.Repositories {
+		metrics.RecordRepository(gitClient.integrationName, repoReport.Slug, repoReport.Failed())
 	}
 
 	// back to project root
 	os.Chdir("../..")
+	report.Finished = time.Now()
+	return report
+}
+
+// installTransport routes git traffic for the source hosts through the input plugin's HTTP client
+// Other hosts, like the target, use the default client. go-git keeps one client per protocol for
+// the whole process, so the hosts of every integration are registered on one router. Config
+// checks that integrations sharing a source host have the same transport options, see
+// config.CheckTransports
+func (gitClient Client) installTransport(repos []common.Repository) {
+	installGitTransport.Do(func() {
+		httpClient := http.NewClient(&nethttp.Client{Transport: gitTransport})
+		client.InstallProtocol("https", httpClient)
+		client.InstallProtocol("http", httpClient)
+	})
+
+	provider, ok := gitClient.input.(plugins.HTTPClientProvider)
+	if !ok {
+		return
+	}
+	sourceTransport := provider.HTTPClient().Transport
+	if sourceTransport == nil {
+		return
+	}
+	for _, repo := range repos {
+		sourceURL, err := url.Parse(repo.Source)
+		if err != nil {
+			continue
+		}
+		gitTransport.Register(sourceURL.Host, sourceTransport)
+	}
 }
 
 // SyncTags individually syncs the tags from source remote to the target remote
-func (gitClient Client) SyncTags(repo common.Repository, localRepo *git.Repository) ([]string, error) {
+// Tags the target refused are returned as rejections along with the failed tags
+func (gitClient Client) SyncTags(repo common.Repository, localRepo *git.Repository) ([]string, []Rejection, error) {
 
 	var failedTags []string
+	var rejections []Rejection
 	// Get authentication object for source
-	sourceAccountID, sourceAccessToken, err := gitClient.input.Credentials()
+	sourceAuth, err := gitClient.sourceAuth()
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
 		}).Errorf("Failed to fetch source credentials")
-		return nil, errors.New("Failed to sync tags")
-	}
-	sourceAuth := http.BasicAuth{
-		Username: sourceAccountID,
-		Password: sourceAccessToken,
+		return nil, nil, errors.New("Failed to sync tags")
 	}
 
 	// Get authentication object for target
-	targetAccountID, targetAccessToken, err := gitClient.output.Credentials()
+	targetAuth, err := gitClient.targetAuth()
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
 		}).Errorf("Failed to fetch target credentials")
-		return nil, errors.New("Failed to sync tags")
-	}
-	targetAuth := http.BasicAuth{
-		Username: targetAccountID,
-		Password: targetAccessToken,
+		return nil, nil, errors.New("Failed to sync tags")
 	}
 
 	// Fetch from origin
 	fo := git.FetchOptions{
 		RemoteName: "origin",
-		Auth:       &sourceAuth,
+		Auth:       sourceAuth,
 	}
 	fo.Validate()
-	err = localRepo.Fetch(&fo)
+	start := time.Now()
+	err = localRepo.FetchContext(gitClient.context(), &fo)
+	metrics.ObserveGit(gitClient.integrationName, metrics.OperationFetch, start)
 
 	remotes, _ := localRepo.Remotes()
 	for _, remote := range remotes {
@@ -191,7 +425,7 @@ func (gitClient Client) SyncTags(repo common.Repository, localRepo *git.Reposito
 	}
 
 	if err != nil && err.Error() != "already up-to-date" {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
@@ -201,15 +435,15 @@ func (gitClient Client) SyncTags(repo common.Repository, localRepo *git.Reposito
 	// Get list of origin tags
 	origin, err := localRepo.Remote("origin")
 	refs, err := origin.List(&git.ListOptions{
-		Auth: &sourceAuth,
+		Auth: sourceAuth,
 	})
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
 		}).Warningf("Failed to fetch tags from origin")
-		return nil, errors.New("Failed to sync tags")
+		return nil, nil, errors.New("Failed to sync tags")
 	}
 
 	// Parse list of tag names
@@ -227,18 +461,19 @@ func (gitClient Client) SyncTags(repo common.Repository, localRepo *git.Reposito
 		tagRefspec := fmt.Sprintf("refs/tags/%v:refs/tags/%v", tag, tag)
 
 		// Push tag to target remote
-		po := git.PushOptions{
-			RemoteName: "target",
-			Auth:       &targetAuth,
-			RefSpecs:   []config.RefSpec{config.RefSpec(tagRefspec)},
-		}
-		po.Validate()
-		err = localRepo.Push(&po)
+		start := time.Now()
+		remoteMessages, err := push(gitClient.context(), localRepo, tagRefspec, targetAuth)
+		metrics.ObserveGit(gitClient.integrationName, metrics.OperationPush, start)
 
 		// Report errors if any
-		if (err != nil) && (err.Error() != "already up-to-date") {
+		if err != nil {
 			failedTags = append(failedTags, tag)
-			log.WithFields(logrus.Fields{
+			if rejection, ok := classifyPush(localRepo, "refs/tags/"+tag, "refs/tags/"+tag, err, remoteMessages); ok {
+				rejections = append(rejections, *rejection)
+				gitClient.logRejection(repo, *rejection)
+				continue
+			}
+			gitClient.log.WithFields(logrus.Fields{
 				"integration": gitClient.integrationName,
 				"repository":  repo.Slug,
 				"tag":         tag,
@@ -247,11 +482,65 @@ func (gitClient Client) SyncTags(repo common.Repository, localRepo *git.Reposito
 		}
 
 	}
+	metrics.RecordRefs(gitClient.integrationName, metrics.KindTag, len(tags)-len(failedTags), len(failedTags))
 
 	if len(failedTags) > 0 {
-		return failedTags, errors.New("Some tags not synced")
+		return failedTags, rejections, errors.New("Some tags not synced")
+	}
+	return nil, nil, nil
+}
+
+// logRejection logs a ref refused by the target with what is known about the reason
+func (gitClient Client) logRejection(repo common.Repository, rejection Rejection) {
+	gitClient.log.WithFields(logrus.Fields{
+		"integration": gitClient.integrationName,
+		"repository":  repo.Slug,
+		"ref":         rejection.Ref,
+		"reason":      rejection.Reason,
+		"paths":       rejection.Paths,
+		"commits":     rejection.Commits,
+		"error":       rejection.Message,
+	}).Errorf("Target rejected push")
+}
+
+// SyncLFS copies the LFS objects of the repository from the source LFS server to the target LFS server
+// Returns a nil summary for repositories that do not use LFS
+func (gitClient Client) SyncLFS(repo common.Repository, localRepo *git.Repository) (*lfs.Summary, error) {
+
+	usesLFS, err := lfs.Detect(localRepo)
+	if err != nil || !usesLFS {
+		return nil, err
+	}
+
+	pointers, err := lfs.Pointers(localRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceAuth, err := gitClient.sourceAuth()
+	if err != nil {
+		return nil, err
+	}
+	targetAuth, err := gitClient.targetAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	// LFS servers take the same basic auth as git
+	source := lfs.Endpoint{URL: lfs.EndpointURL(repo.Source)}
+	if basic, ok := sourceAuth.(*http.BasicAuth); ok {
+		source.Username, source.Password = basic.Username, basic.Password
+	}
+	if provider, ok := gitClient.input.(plugins.HTTPClientProvider); ok {
+		source.HTTP = provider.HTTPClient()
+	}
+	target := lfs.Endpoint{URL: lfs.EndpointURL(repo.Target)}
+	if basic, ok := targetAuth.(*http.BasicAuth); ok {
+		target.Username, target.Password = basic.Username, basic.Password
 	}
-	return nil, nil
+
+	summary, err := lfs.Transfer(gitClient.context(), source, target, pointers)
+	return &summary, err
 }
 
 func reorderDefault(branches []string, keyBranch string) []string {
@@ -272,49 +561,48 @@ func reorderDefault(branches []string, keyBranch string) []string {
 }
 
 // SyncBranches individually syncs the branches from source remote to the target remote
-func (gitClient Client) SyncBranches(repo common.Repository, localRepo *git.Repository) ([]string, error) {
+// New branches too large to push at once are pushed in chunks of commits. Branches
+// the target refused are returned as rejections along with the failed branches
+// In a two-way sync, target changes are pushed to the source and diverged branches are returned
+func (gitClient Client) SyncBranches(repo common.Repository, localRepo *git.Repository) ([]string, []Rejection, []Divergence, error) {
 
 	var failedBranches []string
+	var rejections []Rejection
+	var divergences []Divergence
 
 	// Get authentication object for source
-	sourceAccountID, sourceAccessToken, err := gitClient.input.Credentials()
+	sourceAuth, err := gitClient.sourceAuth()
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
 		}).Errorf("Failed to fetch source credentials")
-		return nil, errors.New("Failed to sync tags")
-	}
-	sourceAuth := http.BasicAuth{
-		Username: sourceAccountID,
-		Password: sourceAccessToken,
+		return nil, nil, nil, errors.New("Failed to sync branches")
 	}
 
 	// Get authentication object for target
-	targetAccountID, targetAccessToken, err := gitClient.output.Credentials()
+	targetAuth, err := gitClient.targetAuth()
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
 		}).Errorf("Failed to fetch target credentials")
-		return nil, errors.New("Failed to sync tags")
-	}
-	targetAuth := http.BasicAuth{
-		Username: targetAccountID,
-		Password: targetAccessToken,
+		return nil, nil, nil, errors.New("Failed to sync branches")
 	}
 
 	// Fetch from origin
 	fo := git.FetchOptions{
 		RemoteName: "origin",
-		Auth:       &sourceAuth,
+		Auth:       sourceAuth,
 	}
 	fo.Validate()
-	err = localRepo.Fetch(&fo)
+	start := time.Now()
+	err = localRepo.FetchContext(gitClient.context(), &fo)
+	metrics.ObserveGit(gitClient.integrationName, metrics.OperationFetch, start)
 	if err != nil && err.Error() != "already up-to-date" {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
@@ -324,10 +612,10 @@ func (gitClient Client) SyncBranches(repo common.Repository, localRepo *git.Repo
 	// Get list of origin branches
 	origin, err := localRepo.Remote("origin")
 	refs, err := origin.List(&git.ListOptions{
-		Auth: &sourceAuth,
+		Auth: sourceAuth,
 	})
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		gitClient.log.WithFields(logrus.Fields{
 			"integration": gitClient.integrationName,
 			"repository":  repo.Slug,
 			"error":       err.Error(),
@@ -343,7 +631,11 @@ func (gitClient Client) SyncBranches(repo common.Repository, localRepo *git.Repo
 	}
 
 	// Reorder to push default branch from source first
+	// HEAD is authoritative, the default branch from the source API is a fallback
 	defaultBranch := "master"
+	if repo.DefaultBranch != "" {
+		defaultBranch = repo.DefaultBranch
+	}
 	for _, ref := range refs {
 		if ref.Strings()[0] == "HEAD" {
 			defaultBranch = strings.SplitN(ref.Strings()[1], "ref: refs/heads/", 2)[1]
@@ -352,25 +644,90 @@ func (gitClient Client) SyncBranches(repo common.Repository, localRepo *git.Repo
 	}
 	branches = reorderDefault(branches, defaultBranch)
 
+	// Branches already on the target are never pushed in chunks
+	targetBranches, targetKnown := gitClient.targetBranches(localRepo, targetAuth)
+
+	// Two-way syncs compare each branch with its copy on the target
+	twoWay := gitClient.options.TwoWay
+	if twoWay {
+		err = fetchTarget(gitClient.context(), localRepo, targetAuth)
+		if err != nil {
+			twoWay = false
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"error":       err.Error(),
+			}).Warningf("Failed to fetch target branches, syncing one way")
+		}
+	}
+
 	// Sync branches
+	syncedBranches := 0
 	for _, branch := range branches {
 
+		// Apply branch modifiers, skipping branches that are not synced
+		targetBranch, synced := gitClient.output.TargetBranch(branch)
+		if !synced {
+			continue
+		}
+		syncedBranches++
+
+		if twoWay {
+			handled, divergence, err := gitClient.syncTwoWay(repo, localRepo, branch, targetBranch, sourceAuth)
+			if divergence != nil {
+				divergences = append(divergences, *divergence)
+				gitClient.log.WithFields(logrus.Fields{
+					"integration":       gitClient.integrationName,
+					"repository":        repo.Slug,
+					"branch":            branch,
+					"sourceCommit":      divergence.SourceCommit,
+					"targetCommit":      divergence.TargetCommit,
+					"authoritativeSide": divergence.AuthoritativeSide,
+				}).Warningf("Branch diverged between source and target")
+			}
+			if err != nil {
+				failedBranches = append(failedBranches, branch)
+				gitClient.log.WithFields(logrus.Fields{
+					"integration": gitClient.integrationName,
+					"repository":  repo.Slug,
+					"branch":      branch,
+					"error":       err.Error(),
+				}).Errorf("Branch could not be synced")
+				continue
+			}
+			if handled {
+				continue
+			}
+		}
+
 		// Build refspec
-		branchRefspec := fmt.Sprintf("refs/remotes/origin/%v:refs/heads/%v", branch, branch)
+		localRef := fmt.Sprintf("refs/remotes/origin/%v", branch)
+		branchRefspec := fmt.Sprintf("%v:refs/heads/%v", localRef, targetBranch)
 
 		// Push branch to target remote
-		po := git.PushOptions{
-			RemoteName: "target",
-			Auth:       &targetAuth,
-			RefSpecs:   []config.RefSpec{config.RefSpec(branchRefspec)},
+		start := time.Now()
+		remoteMessages, err := push(gitClient.context(), localRepo, branchRefspec, targetAuth)
+		metrics.ObserveGit(gitClient.integrationName, metrics.OperationPush, start)
+		if err == nil {
+			continue
+		}
+
+		rejection, rejected := classifyPush(localRepo, localRef, "refs/heads/"+targetBranch, err, remoteMessages)
+		if rejected && rejection.Reason == RejectedPushTooLarge && targetKnown && !targetBranches[targetBranch] {
+			err = gitClient.pushChunked(repo, localRepo, localRef, targetBranch, targetAuth)
+			if err == nil {
+				continue
+			}
+			rejection.Message = err.Error()
 		}
-		po.Validate()
-		err = localRepo.Push(&po)
 
 		// Report errors if any
-		if (err != nil) && (err.Error() != "already up-to-date") {
-			failedBranches = append(failedBranches, branch)
-			log.WithFields(logrus.Fields{
+		failedBranches = append(failedBranches, branch)
+		if rejected {
+			rejections = append(rejections, *rejection)
+			gitClient.logRejection(repo, *rejection)
+		} else {
+			gitClient.log.WithFields(logrus.Fields{
 				"integration": gitClient.integrationName,
 				"repository":  repo.Slug,
 				"branch":      branch,
@@ -379,8 +736,87 @@ func (gitClient Client) SyncBranches(repo common.Repository, localRepo *git.Repo
 		}
 	}
 
+	metrics.RecordRefs(gitClient.integrationName, metrics.KindBranch, syncedBranches-len(failedBranches), len(failedBranches))
+
+	gitClient.syncDefaultBranch(repo, defaultBranch, failedBranches)
+
 	if len(failedBranches) > 0 {
-		return failedBranches, errors.New("Some branches not synced")
+		return failedBranches, rejections, divergences, errors.New("Some branches not synced")
+	}
+	return nil, nil, divergences, nil
+}
+
+// targetBranches lists the branches on the target, returning false if they could not be listed
+func (gitClient Client) targetBranches(localRepo *git.Repository, targetAuth transportgit.AuthMethod) (map[string]bool, bool) {
+	branches := map[string]bool{}
+
+	target, err := localRepo.Remote("target")
+	if err != nil {
+		return nil, false
+	}
+	refs, err := target.List(&git.ListOptions{Auth: targetAuth})
+	if err == transportgit.ErrEmptyRemoteRepository {
+		return branches, true
+	}
+	if err != nil {
+		return nil, false
+	}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches[ref.Name().Short()] = true
+		}
+	}
+	return branches, true
+}
+
+// pushChunked seeds a new target branch that is too large to push at once
+func (gitClient Client) pushChunked(repo common.Repository, localRepo *git.Repository, localRef string, targetBranch string, targetAuth transportgit.AuthMethod) error {
+	tip, err := localRepo.ResolveRevision(plumbing.Revision(localRef))
+	if err != nil {
+		return err
+	}
+
+	chunkSize := gitClient.options.PushChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultPushChunkSize
+	}
+
+	gitClient.log.WithFields(logrus.Fields{
+		"integration": gitClient.integrationName,
+		"repository":  repo.Slug,
+		"branch":      targetBranch,
+		"chunkSize":   chunkSize,
+	}).Infof("Push too large, pushing branch in chunks")
+
+	return pushChunked(gitClient.context(), localRepo, *tip, targetBranch, chunkSize, targetAuth)
+}
+
+// syncDefaultBranch sets the target default branch to the modified name of the source HEAD
+// It is skipped when that branch is not synced or failed to push
+func (gitClient Client) syncDefaultBranch(repo common.Repository, defaultBranch string, failedBranches []string) {
+	for _, failedBranch := range failedBranches {
+		if failedBranch == defaultBranch {
+			return
+		}
+	}
+
+	targetBranch, synced := gitClient.output.TargetBranch(defaultBranch)
+	if !synced {
+		gitClient.log.WithFields(logrus.Fields{
+			"integration":   gitClient.integrationName,
+			"repository":    repo.Slug,
+			"defaultBranch": defaultBranch,
+		}).Debugf("Default branch not synced, target default branch unchanged")
+		return
+	}
+
+	err := v2.FromOutput(gitClient.output).SetDefaultBranch(gitClient.context(), repo, targetBranch)
+	if err != nil {
+		gitClient.log.WithFields(logrus.Fields{
+			"integration":   gitClient.integrationName,
+			"repository":    repo.Slug,
+			"defaultBranch": targetBranch,
+			"error":         err.Error(),
+		}).Warningf("Failed to set target default branch")
 	}
-	return nil, nil
 }
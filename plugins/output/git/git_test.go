@@ -1,11 +1,16 @@
 package git_test
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
+	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
 	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
 	git "github.com/parinithshekar/gitsink/plugins/output/git"
 	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
 )
@@ -36,6 +41,29 @@ var (
 	}
 )
 
+// localOutput is a target whose repositories and wikis are local, so nothing calls the API
+type localOutput struct {
+	*ghpublic.Public
+	wiki string
+}
+
+func (output localOutput) EnableWiki(ctx context.Context, repo common.Repository) (string, error) {
+	return output.wiki, nil
+}
+
+func (output localOutput) V2() v2.Output {
+	return localV2{output.Public.V2()}
+}
+
+// localV2 is the target of localOutput with the methods of the second plugin interfaces
+type localV2 struct {
+	v2.Output
+}
+
+func (target localV2) SetDefaultBranch(ctx context.Context, repo common.Repository, branch string) error {
+	return nil
+}
+
 func TestNew(t *testing.T) {
 	os.Setenv(envSourceAccountID, "username")
 	os.Setenv(envSourceAccessToken, "token")
@@ -49,5 +77,50 @@ func TestNew(t *testing.T) {
 	input, _ := bbserver.New(source)
 	output, _ := ghpublic.New(target)
 
-	_ = git.New(input, output, "test-integration")
+	_ = git.New(input, output, "test-integration", git.Options{})
+}
+
+func TestDirectory(t *testing.T) {
+	os.Setenv(envSourceAccountID, "username")
+	os.Setenv(envSourceAccessToken, "token")
+	os.Setenv(envTargetAccountID, "username")
+	os.Setenv(envTargetAccessToken, "token")
+	defer os.Unsetenv(envSourceAccountID)
+	defer os.Unsetenv(envSourceAccessToken)
+	defer os.Unsetenv(envTargetAccountID)
+	defer os.Unsetenv(envTargetAccessToken)
+
+	dir, err := ioutil.TempDir("", "gitsink-directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	cases := map[string]struct {
+		IntegrationName   string
+		ExpectedDirectory string
+	}{
+		"Plain name":  {"mirror", filepath.Join("syncDirectory", "mirror")},
+		"With spaces": {"Team mirror to GitHub", filepath.Join("syncDirectory", "Team-mirror-to-GitHub")},
+	}
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			actualDirectory := git.Directory(tc.IntegrationName)
+			if actualDirectory != tc.ExpectedDirectory {
+				t.Errorf("Expected directory: %v | Actual: %v", tc.ExpectedDirectory, actualDirectory)
+			}
+
+			// The repositories of the integration are synced in the same directory
+			input, _ := bbserver.New(source)
+			output, _ := ghpublic.New(target)
+			git.New(input, output, tc.IntegrationName, git.Options{}).SyncRepos(context.Background(), nil)
+			if info, err := os.Stat(actualDirectory); err != nil || !info.IsDir() {
+				t.Errorf("Expected the sync to use %v", actualDirectory)
+			}
+		})
+	}
 }
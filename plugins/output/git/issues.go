@@ -0,0 +1,30 @@
+package git
+
+import (
+	"fmt"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// MigrateIssues copies the issues of the source repository, with their comments, labels,
+// milestones and state, to the target repository
+func (gitClient Client) MigrateIssues(repo common.Repository) (*common.MigrationSummary, error) {
+
+	source, ok := gitClient.input.(plugins.IssueSource)
+	if !ok {
+		return nil, fmt.Errorf("Source does not support issue migration")
+	}
+	target, ok := gitClient.output.(plugins.IssueTarget)
+	if !ok {
+		return nil, fmt.Errorf("Target does not support issue migration")
+	}
+
+	issues, err := source.Issues(gitClient.context(), repo)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := target.MigrateIssues(gitClient.context(), repo, issues)
+	return &summary, err
+}
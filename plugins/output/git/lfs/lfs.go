@@ -0,0 +1,371 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	transport "github.com/parinithshekar/gitsink/common/transport"
+)
+
+const (
+	// mediaType is required by the batch API for requests and responses
+	mediaType = "application/vnd.git-lfs+json"
+	// pointerVersion is the first line of every LFS pointer file
+	pointerVersion = "version https://git-lfs.github.com/spec/v1"
+	// maxPointerSize is the largest blob that can be a pointer file
+	maxPointerSize = 1024
+	// BatchSize is the number of objects sent in one batch API request
+	BatchSize = 100
+)
+
+var (
+	// lfsAttribute matches .gitattributes lines that route paths through LFS
+	lfsAttribute = regexp.MustCompile(`(^|\s)filter=lfs(\s|$)`)
+	// oidPattern matches a sha256 object ID
+	oidPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// defaultClient is used for endpoints without a client of their own. Objects can be large,
+	// so only the wait for a response is limited and the context of the transfer ends the rest
+	defaultClient = newDefaultClient()
+)
+
+// Pointer identifies an LFS object by its sha256 and size
+type Pointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// Summary counts the objects and bytes handled by a transfer
+type Summary struct {
+	Objects     int      `json:"objects"`
+	Transferred int      `json:"transferred"`
+	Bytes       int64    `json:"bytes"`
+	Present     int      `json:"present"`
+	Failed      []string `json:"failed,omitempty"`
+}
+
+// Endpoint is an LFS server for one repository, HTTP defaults to a client that gives up on
+// servers not answering within transport.ResponseTimeout
+type Endpoint struct {
+	URL      string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+// action is an href to call for one object, with the headers to send
+type action struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+// batchObject is one object in a batch API response
+type batchObject struct {
+	Pointer
+	Actions map[string]action `json:"actions"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// EndpointURL derives the LFS server URL from a clone URL
+func EndpointURL(cloneURL string) string {
+	cloneURL = strings.TrimSuffix(cloneURL, "/")
+	if !strings.HasSuffix(cloneURL, ".git") {
+		cloneURL += ".git"
+	}
+	return cloneURL + "/info/lfs"
+}
+
+// Detect checks the .gitattributes files at the tip of every origin branch for LFS filters
+func Detect(repo *git.Repository) (bool, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return false, err
+	}
+
+	detected := false
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if detected || ref.Type() != plumbing.HashReference || !strings.HasPrefix(ref.Name().String(), "refs/remotes/origin/") {
+			return nil
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil
+		}
+		return tree.Files().ForEach(func(file *object.File) error {
+			if path.Base(file.Name) != ".gitattributes" {
+				return nil
+			}
+			contents, err := file.Contents()
+			if err != nil {
+				return nil
+			}
+			for _, line := range strings.Split(contents, "\n") {
+				line = strings.TrimSpace(line)
+				if !strings.HasPrefix(line, "#") && lfsAttribute.MatchString(line) {
+					detected = true
+					return io.EOF
+				}
+			}
+			return nil
+		})
+	})
+	if err == io.EOF {
+		err = nil
+	}
+	return detected, err
+}
+
+// ParsePointer reads an LFS pointer file, returning false if the contents are not a pointer
+func ParsePointer(contents []byte) (Pointer, bool) {
+	var pointer Pointer
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+
+	if !scanner.Scan() || scanner.Text() != pointerVersion {
+		return pointer, false
+	}
+	for scanner.Scan() {
+		keyValue := strings.SplitN(scanner.Text(), " ", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		switch keyValue[0] {
+		case "oid":
+			pointer.OID = strings.TrimPrefix(keyValue[1], "sha256:")
+		case "size":
+			pointer.Size, _ = strconv.ParseInt(keyValue[1], 10, 64)
+		}
+	}
+	if !oidPattern.MatchString(pointer.OID) || pointer.Size < 0 {
+		return pointer, false
+	}
+	return pointer, true
+}
+
+// Pointers lists the distinct LFS objects referenced by pointer files anywhere in the local repository
+func Pointers(repo *git.Repository) ([]Pointer, error) {
+	blobs, err := repo.BlobObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	pointers := []Pointer{}
+	seen := map[string]bool{}
+	err = blobs.ForEach(func(blob *object.Blob) error {
+		if blob.Size > maxPointerSize {
+			return nil
+		}
+		reader, err := blob.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		contents, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+
+		pointer, ok := ParsePointer(contents)
+		if ok && !seen[pointer.OID] {
+			seen[pointer.OID] = true
+			pointers = append(pointers, pointer)
+		}
+		return nil
+	})
+	return pointers, err
+}
+
+// batch asks the LFS server how to perform the operation for the objects
+func (endpoint Endpoint) batch(ctx context.Context, operation string, pointers []Pointer) ([]batchObject, error) {
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"operation": operation,
+		"transfers": []string{"basic"},
+		"objects":   pointers,
+	})
+
+	request, err := http.NewRequestWithContext(ctx, "POST", endpoint.URL+"/objects/batch", bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", mediaType)
+	request.Header.Set("Content-Type", mediaType)
+	request.SetBasicAuth(endpoint.Username, endpoint.Password)
+
+	response, err := endpoint.client().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("LFS %v batch failed with status %v: %s", operation, response.StatusCode, bytes.TrimSpace(message))
+	}
+
+	var result struct {
+		Objects []batchObject `json:"objects"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	return result.Objects, err
+}
+
+func newDefaultClient() *http.Client {
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	httpTransport.ResponseHeaderTimeout = transport.ResponseTimeout
+	return &http.Client{Transport: httpTransport}
+}
+
+func (endpoint Endpoint) client() *http.Client {
+	if endpoint.HTTP != nil {
+		return endpoint.HTTP
+	}
+	return defaultClient
+}
+
+// sameHost checks if the URL points at the LFS server of the endpoint
+func (endpoint Endpoint) sameHost(actionURL *url.URL) bool {
+	endpointURL, err := url.Parse(endpoint.URL)
+	return err == nil && endpointURL.Host == actionURL.Host
+}
+
+// call performs the request for an action and fails on unsuccessful responses
+func (endpoint Endpoint) call(ctx context.Context, method string, act action, body io.Reader, size int64) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, act.Href, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = size
+	}
+	if method == "POST" {
+		request.Header.Set("Accept", mediaType)
+		request.Header.Set("Content-Type", mediaType)
+	}
+	for key, value := range act.Header {
+		request.Header.Set(key, value)
+	}
+	// Actions on the LFS server itself without their own Authorization header use the
+	// repository credentials. Pre-signed storage URLs on other hosts must not get them
+	if request.Header.Get("Authorization") == "" && endpoint.sameHost(request.URL) {
+		request.SetBasicAuth(endpoint.Username, endpoint.Password)
+	}
+
+	response, err := endpoint.client().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+		return nil, fmt.Errorf("LFS %v %v failed with status %v", method, act.Href, response.StatusCode)
+	}
+	return response, nil
+}
+
+// Transfer copies the objects missing on the target from the source, in batches
+// Objects are streamed from the source download to the target upload without touching disk,
+// and the requests stop when ctx is done
+func Transfer(ctx context.Context, source, target Endpoint, pointers []Pointer) (Summary, error) {
+	summary := Summary{Objects: len(pointers)}
+
+	for start := 0; start < len(pointers); start += BatchSize {
+		end := start + BatchSize
+		if end > len(pointers) {
+			end = len(pointers)
+		}
+		chunk := pointers[start:end]
+
+		// Objects the target already has come back without an upload action
+		uploads, err := target.batch(ctx, "upload", chunk)
+		if err != nil {
+			return summary, err
+		}
+		missing := []Pointer{}
+		uploadActions := map[string]batchObject{}
+		for _, object := range uploads {
+			if object.Error != nil {
+				summary.Failed = append(summary.Failed, object.OID)
+				continue
+			}
+			if _, needed := object.Actions["upload"]; !needed {
+				summary.Present++
+				continue
+			}
+			missing = append(missing, object.Pointer)
+			uploadActions[object.OID] = object
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		downloads, err := source.batch(ctx, "download", missing)
+		if err != nil {
+			return summary, err
+		}
+		for _, object := range downloads {
+			download, ok := object.Actions["download"]
+			if object.Error != nil || !ok {
+				summary.Failed = append(summary.Failed, object.OID)
+				continue
+			}
+
+			err := copyObject(ctx, source, target, object.Pointer, download, uploadActions[object.OID])
+			if err != nil {
+				summary.Failed = append(summary.Failed, object.OID)
+				continue
+			}
+			summary.Transferred++
+			summary.Bytes += object.Size
+		}
+	}
+
+	if len(summary.Failed) > 0 {
+		return summary, fmt.Errorf("%v of %v LFS objects not transferred", len(summary.Failed), summary.Objects)
+	}
+	return summary, nil
+}
+
+// copyObject streams one object from the source download action to the target upload action
+func copyObject(ctx context.Context, source, target Endpoint, pointer Pointer, download action, upload batchObject) error {
+	response, err := source.call(ctx, "GET", download, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	uploadResponse, err := target.call(ctx, "PUT", upload.Actions["upload"], response.Body, pointer.Size)
+	if err != nil {
+		return err
+	}
+	uploadResponse.Body.Close()
+
+	// Servers that ask for verification only keep the object once it is verified
+	if verify, ok := upload.Actions["verify"]; ok {
+		verifyBody, _ := json.Marshal(pointer)
+		verifyResponse, err := target.call(ctx, "POST", verify, bytes.NewReader(verifyBody), int64(len(verifyBody)))
+		if err != nil {
+			return err
+		}
+		verifyResponse.Body.Close()
+	}
+	return nil
+}
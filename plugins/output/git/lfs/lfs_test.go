@@ -0,0 +1,233 @@
+package lfs_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	memfs "github.com/go-git/go-billy/v5/memfs"
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	memory "github.com/go-git/go-git/v5/storage/memory"
+
+	lfs "github.com/parinithshekar/gitsink/plugins/output/git/lfs"
+)
+
+// lfsServer is a minimal LFS batch API with basic transfers, storing objects in memory
+type lfsServer struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func newLFSServer(objects map[string][]byte) *lfsServer {
+	server := &lfsServer{objects: objects}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+func (server *lfsServer) handle(w http.ResponseWriter, r *http.Request) {
+	if username, password, _ := r.BasicAuth(); username != "username" || password != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/objects/batch"):
+		var request struct {
+			Operation string        `json:"operation"`
+			Objects   []lfs.Pointer `json:"objects"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		objects := []map[string]interface{}{}
+		for _, pointer := range request.Objects {
+			_, exists := server.objects[pointer.OID]
+			href := map[string]interface{}{"href": server.URL + "/objects/" + pointer.OID}
+			object := map[string]interface{}{"oid": pointer.OID, "size": pointer.Size}
+			switch {
+			case request.Operation == "download" && exists:
+				object["actions"] = map[string]interface{}{"download": href}
+			case request.Operation == "download":
+				object["error"] = map[string]interface{}{"code": 404, "message": "Object does not exist"}
+			case request.Operation == "upload" && !exists:
+				object["actions"] = map[string]interface{}{"upload": href}
+			}
+			objects = append(objects, object)
+		}
+		w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"objects": objects})
+
+	case r.Method == "GET":
+		w.Write(server.objects[strings.TrimPrefix(r.URL.Path, "/objects/")])
+
+	case r.Method == "PUT":
+		contents, _ := ioutil.ReadAll(r.Body)
+		server.objects[strings.TrimPrefix(r.URL.Path, "/objects/")] = contents
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func pointerFor(contents string) (lfs.Pointer, string) {
+	sum := sha256.Sum256([]byte(contents))
+	oid := hex.EncodeToString(sum[:])
+	file := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%v\nsize %v\n", oid, len(contents))
+	return lfs.Pointer{OID: oid, Size: int64(len(contents))}, file
+}
+
+func TestParsePointer(t *testing.T) {
+	pointer, file := pointerFor("large asset")
+
+	cases := map[string]struct {
+		Contents        string
+		ExpectedPointer bool
+	}{
+		"Valid pointer":   {file, true},
+		"Plain text file": {"hello world\n", false},
+		"Bad oid":         {"version https://git-lfs.github.com/spec/v1\noid sha256:1234\nsize 10\n", false},
+		"Missing version": {strings.SplitN(file, "\n", 2)[1], false},
+	}
+
+	for tcName, tc := range cases {
+		actualPointer, ok := lfs.ParsePointer([]byte(tc.Contents))
+		if ok != tc.ExpectedPointer || (ok && actualPointer != pointer) {
+			t.Errorf("%v - Expected pointer: %v | Actual: %v %+v", tcName, tc.ExpectedPointer, ok, actualPointer)
+		}
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	cases := map[string]struct {
+		CloneURL, ExpectedURL string
+	}{
+		"GitHub clone URL":    {"https://github.com/org/repo.git", "https://github.com/org/repo.git/info/lfs"},
+		"Bitbucket clone URL": {"https://bitbucket.company.com/scm/proj/repo", "https://bitbucket.company.com/scm/proj/repo.git/info/lfs"},
+	}
+
+	for tcName, tc := range cases {
+		if actualURL := lfs.EndpointURL(tc.CloneURL); actualURL != tc.ExpectedURL {
+			t.Errorf("%v - Expected URL: %v | Actual URL: %v", tcName, tc.ExpectedURL, actualURL)
+		}
+	}
+}
+
+func TestDetectAndPointers(t *testing.T) {
+	pointer, file := pointerFor("large asset")
+
+	cases := map[string]struct {
+		Attributes       string
+		ExpectedDetected bool
+	}{
+		"LFS attributes":       {"*.bin filter=lfs diff=lfs merge=lfs -text\n", true},
+		"Commented attributes": {"# *.bin filter=lfs\n", false},
+		"Other attributes":     {"*.sh text eol=lf\n", false},
+	}
+
+	for tcName, tc := range cases {
+		t.Run(tcName, func(t *testing.T) {
+			repo, err := git.Init(memory.NewStorage(), memfs.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			worktree, _ := repo.Worktree()
+			for name, contents := range map[string]string{".gitattributes": tc.Attributes, "asset.bin": file} {
+				f, _ := worktree.Filesystem.Create(name)
+				f.Write([]byte(contents))
+				f.Close()
+				worktree.Add(name)
+			}
+			hash, err := worktree.Commit("initial", &git.CommitOptions{
+				Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Detection looks at origin branches, as after a clone
+			repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/master", hash))
+
+			detected, err := lfs.Detect(repo)
+			if err != nil || detected != tc.ExpectedDetected {
+				t.Errorf("%v - Expected detected: %v | Actual: %v, %v", tcName, tc.ExpectedDetected, detected, err)
+			}
+
+			pointers, err := lfs.Pointers(repo)
+			if err != nil || len(pointers) != 1 || pointers[0] != pointer {
+				t.Errorf("%v - Expected pointers: %v | Actual: %v, %v", tcName, pointer, pointers, err)
+			}
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	present, _ := pointerFor("already on target")
+	missing, _ := pointerFor("only on source")
+	lost, _ := pointerFor("on neither side")
+
+	source := newLFSServer(map[string][]byte{
+		present.OID: []byte("already on target"),
+		missing.OID: []byte("only on source"),
+	})
+	defer source.Close()
+	target := newLFSServer(map[string][]byte{
+		present.OID: []byte("already on target"),
+	})
+	defer target.Close()
+
+	sourceEndpoint := lfs.Endpoint{URL: source.URL, Username: "username", Password: "token"}
+	targetEndpoint := lfs.Endpoint{URL: target.URL, Username: "username", Password: "token"}
+
+	summary, err := lfs.Transfer(context.Background(), sourceEndpoint, targetEndpoint, []lfs.Pointer{present, missing, lost})
+
+	if err == nil {
+		t.Error("Expected an error for the object missing on the source")
+	}
+	if summary.Objects != 3 || summary.Present != 1 || summary.Transferred != 1 || summary.Bytes != missing.Size {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if len(summary.Failed) != 1 || summary.Failed[0] != lost.OID {
+		t.Errorf("Expected failed objects: %v | Actual: %v", []string{lost.OID}, summary.Failed)
+	}
+	if string(target.objects[missing.OID]) != "only on source" {
+		t.Errorf("Object not uploaded to target")
+	}
+}
+
+func TestTransferCanceled(t *testing.T) {
+	missing, _ := pointerFor("only on source")
+
+	source := newLFSServer(map[string][]byte{
+		missing.OID: []byte("only on source"),
+	})
+	defer source.Close()
+	target := newLFSServer(map[string][]byte{})
+	defer target.Close()
+
+	sourceEndpoint := lfs.Endpoint{URL: source.URL, Username: "username", Password: "token"}
+	targetEndpoint := lfs.Endpoint{URL: target.URL, Username: "username", Password: "token"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary, err := lfs.Transfer(ctx, sourceEndpoint, targetEndpoint, []lfs.Pointer{missing})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error: %v | Actual: %v", context.Canceled, err)
+	}
+	if summary.Transferred != 0 || len(target.objects) != 0 {
+		t.Errorf("Expected nothing transferred, got summary %+v", summary)
+	}
+}
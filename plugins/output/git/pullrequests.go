@@ -0,0 +1,56 @@
+package git
+
+import (
+	"fmt"
+
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// MigratePullRequests recreates the source pull requests on the target against the synced branches
+// Pull requests into branches that are not synced are skipped
+func (gitClient Client) MigratePullRequests(repo common.Repository) (*common.MigrationSummary, error) {
+
+	source, ok := gitClient.input.(plugins.PullRequestSource)
+	if !ok {
+		return nil, fmt.Errorf("Source does not support pull request migration")
+	}
+	target, ok := gitClient.output.(plugins.PullRequestTarget)
+	if !ok {
+		return nil, fmt.Errorf("Target does not support pull request migration")
+	}
+
+	pullRequests, err := source.PullRequests(gitClient.context(), repo, gitClient.options.PullRequestStates)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := 0
+	var migrating []common.PullRequest
+	for _, pullRequest := range pullRequests {
+		targetBranch, synced := gitClient.output.TargetBranch(pullRequest.TargetBranch)
+		if !synced {
+			skipped++
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"pullRequest": pullRequest.ID,
+				"branch":      pullRequest.TargetBranch,
+			}).Debugf("Pull request into a branch that is not synced, skipping")
+			continue
+		}
+		pullRequest.TargetBranch = targetBranch
+
+		// Branches deleted on the source or not synced keep their name for the attribution text
+		if sourceBranch, synced := gitClient.output.TargetBranch(pullRequest.SourceBranch); synced {
+			pullRequest.SourceBranch = sourceBranch
+		}
+		migrating = append(migrating, pullRequest)
+	}
+
+	summary, err := target.MigratePullRequests(gitClient.context(), repo, migrating)
+	summary.Skipped += skipped
+	return &summary, err
+}
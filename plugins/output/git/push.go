@@ -0,0 +1,286 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	git "github.com/go-git/go-git/v5"
+	config "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	transportgit "github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+const (
+	// RejectedLargeFile means the target refused files over its size limit
+	RejectedLargeFile = "large-file"
+	// RejectedPushTooLarge means the target refused the size of the push as a whole
+	RejectedPushTooLarge = "push-too-large"
+	// RejectedOther is any other refusal by the target
+	RejectedOther = "rejected"
+
+	// defaultPushChunkSize is the number of commits per push when seeding a branch in chunks
+	defaultPushChunkSize = 1000
+	// maxHistoryWalk bounds the commits searched for large files
+	maxHistoryWalk = 100000
+	// chunkRef is a scratch reference used as the source of chunked pushes
+	chunkRef = "refs/gitsink/chunk"
+)
+
+var (
+	// MaxFileSize is the largest file the target accepts, GitHub's hard limit by default
+	MaxFileSize int64 = 100 * 1024 * 1024
+
+	// largeFilePattern matches GitHub's per-file error, capturing the path
+	// remote: error: File assets/video.mp4 is 123.45 MB; this exceeds GitHub's file size limit of 100.00 MB
+	largeFilePattern = regexp.MustCompile(`File (.+) is [0-9.]+ [KMG]B; this exceeds`)
+	// largeFilesPattern matches the pre-receive hook summary for large files
+	largeFilesPattern = regexp.MustCompile(`GH001|Large files detected|exceeds GitHub's file size limit`)
+	// pushTooLargePattern matches the server's refusals of the whole push because of its pack size
+	// Generic transport failures such as HTTP 413 from a proxy are not taken as a size refusal
+	// remote: fatal: pack exceeds maximum allowed size (2.00 GiB)
+	pushTooLargePattern = regexp.MustCompile(`(?i)pack exceeds maximum allowed size|push exceeds maximum allowed size`)
+	// refStatusPattern matches a ref the target refused while accepting the push itself
+	refStatusPattern = regexp.MustCompile(`command error on`)
+)
+
+// Rejection describes a ref the target refused, with the offending paths and commits if known
+type Rejection struct {
+	Ref     string   `json:"ref"`
+	Reason  string   `json:"reason"`
+	Message string   `json:"message"`
+	Paths   []string `json:"paths,omitempty"`
+	Commits []string `json:"commits,omitempty"`
+}
+
+// ClassifyRejection inspects a failed push and the messages the target sent while receiving it
+// Returns false if the push failed for reasons other than the target refusing it
+func ClassifyRejection(ref string, err error, remoteMessages string) (Rejection, bool) {
+	rejection := Rejection{
+		Ref:     ref,
+		Reason:  RejectedOther,
+		Message: err.Error(),
+	}
+	combined := remoteMessages + "\n" + err.Error()
+
+	switch {
+	case largeFilesPattern.MatchString(combined):
+		rejection.Reason = RejectedLargeFile
+		seen := map[string]bool{}
+		for _, match := range largeFilePattern.FindAllStringSubmatch(combined, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				rejection.Paths = append(rejection.Paths, match[1])
+			}
+		}
+	case pushTooLargePattern.MatchString(combined):
+		rejection.Reason = RejectedPushTooLarge
+	case !refStatusPattern.MatchString(combined):
+		return rejection, false
+	}
+	return rejection, true
+}
+
+// LargeFileCommits finds the files over the size limit reachable from the tip and the commits that introduced them
+// Only the given paths are checked if any were reported by the target, else the files each commit changed
+func LargeFileCommits(localRepo *git.Repository, tip plumbing.Hash, paths []string) ([]string, []string, error) {
+	commits, err := localRepo.Log(&git.LogOptions{From: tip})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer commits.Close()
+
+	// Log walks from newest to oldest, so the last commit seen with a blob introduced it
+	introducedBy := map[plumbing.Hash]string{}
+	pathOf := map[plumbing.Hash]string{}
+	walked := 0
+
+	err = commits.ForEach(func(commit *object.Commit) error {
+		walked++
+		if walked > maxHistoryWalk {
+			return fmt.Errorf("History too long to search for large files")
+		}
+
+		check := func(file *object.File) {
+			if file.Size > MaxFileSize {
+				introducedBy[file.Hash] = commit.Hash.String()
+				pathOf[file.Hash] = file.Name
+			}
+		}
+
+		if len(paths) > 0 {
+			for _, path := range paths {
+				file, err := commit.File(path)
+				if err == nil {
+					check(file)
+				}
+			}
+			return nil
+		}
+
+		changed, err := changedFiles(localRepo, commit)
+		if err != nil {
+			return err
+		}
+		for _, file := range changed {
+			check(file)
+		}
+		return nil
+	})
+
+	var foundPaths, foundCommits []string
+	seenPaths := map[string]bool{}
+	seenCommits := map[string]bool{}
+	for blob, commit := range introducedBy {
+		if !seenPaths[pathOf[blob]] {
+			seenPaths[pathOf[blob]] = true
+			foundPaths = append(foundPaths, pathOf[blob])
+		}
+		if !seenCommits[commit] {
+			seenCommits[commit] = true
+			foundCommits = append(foundCommits, commit)
+		}
+	}
+	sort.Strings(foundPaths)
+	sort.Strings(foundCommits)
+	return foundPaths, foundCommits, err
+}
+
+// changedFiles lists the files the commit added or modified compared to its first parent
+func changedFiles(localRepo *git.Repository, commit *object.Commit) ([]*object.File, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// Root commits are compared to an empty tree
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*object.File
+	for _, change := range changes {
+		if change.To.Name == "" {
+			continue
+		}
+		entry := change.To.TreeEntry
+		blob, err := localRepo.BlobObject(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, object.NewFile(change.To.Name, entry.Mode, blob))
+	}
+	return files, nil
+}
+
+// firstParentChain lists the commits from the root to the tip following first parents
+func firstParentChain(localRepo *git.Repository, tip plumbing.Hash) ([]plumbing.Hash, error) {
+	var chain []plumbing.Hash
+
+	commit, err := localRepo.CommitObject(tip)
+	for err == nil {
+		chain = append(chain, commit.Hash)
+		if commit.NumParents() == 0 {
+			break
+		}
+		commit, err = commit.Parent(0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reverse to go from the root to the tip
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// pushChunked seeds a new target branch by pushing its history a chunk of commits at a time
+func pushChunked(ctx context.Context, localRepo *git.Repository, tip plumbing.Hash, targetBranch string, chunkSize int, targetAuth transportgit.AuthMethod) error {
+	chain, err := firstParentChain(localRepo, tip)
+	if err != nil {
+		return err
+	}
+
+	chunkRefName := plumbing.ReferenceName(chunkRef)
+	defer localRepo.Storer.RemoveReference(chunkRefName)
+
+	for i := chunkSize - 1; ; i += chunkSize {
+		if i >= len(chain) {
+			i = len(chain) - 1
+		}
+
+		err := localRepo.Storer.SetReference(plumbing.NewHashReference(chunkRefName, chain[i]))
+		if err != nil {
+			return err
+		}
+
+		refspec := fmt.Sprintf("%v:refs/heads/%v", chunkRef, targetBranch)
+		_, err = push(ctx, localRepo, refspec, targetAuth)
+		if err != nil {
+			return fmt.Errorf("Chunk ending at commit %v of %v could not be pushed: %w", i+1, len(chain), err)
+		}
+
+		if i == len(chain)-1 {
+			return nil
+		}
+	}
+}
+
+// push pushes one refspec to the target, keeping what the target said about it
+func push(ctx context.Context, localRepo *git.Repository, refspec string, targetAuth transportgit.AuthMethod) (string, error) {
+	return pushRemote(ctx, localRepo, "target", refspec, targetAuth)
+}
+
+// pushRemote pushes the refspec to the named remote, returning what the remote sent while receiving it
+func pushRemote(ctx context.Context, localRepo *git.Repository, remoteName string, refspec string, auth transportgit.AuthMethod) (string, error) {
+	var messages bytes.Buffer
+	po := git.PushOptions{
+		RemoteName: remoteName,
+		Auth:       auth,
+		RefSpecs:   []config.RefSpec{config.RefSpec(refspec)},
+		Progress:   &messages,
+	}
+	po.Validate()
+	err := localRepo.PushContext(ctx, &po)
+	if err == git.NoErrAlreadyUpToDate {
+		err = nil
+	}
+	return messages.String(), err
+}
+
+// classifyPush classifies a failed push of the local ref, finding the commits with large files if that was the reason
+func classifyPush(localRepo *git.Repository, localRef string, ref string, err error, remoteMessages string) (*Rejection, bool) {
+	rejection, ok := ClassifyRejection(ref, err, remoteMessages)
+	if !ok {
+		return nil, false
+	}
+	if rejection.Reason == RejectedLargeFile {
+		tip, err := localRepo.ResolveRevision(plumbing.Revision(localRef))
+		if err == nil {
+			paths, commits, _ := LargeFileCommits(localRepo, *tip, rejection.Paths)
+			if len(rejection.Paths) == 0 {
+				rejection.Paths = paths
+			}
+			rejection.Commits = commits
+		}
+	}
+	return &rejection, true
+}
@@ -0,0 +1,125 @@
+package git_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	memfs "github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	memory "github.com/go-git/go-git/v5/storage/memory"
+
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+)
+
+func TestClassifyRejection(t *testing.T) {
+	largeFileMessages := "remote: error: GH001: Large files detected. You may want to try Git Large File Storage\n" +
+		"remote: error: File assets/video.mp4 is 123.45 MB; this exceeds GitHub's file size limit of 100.00 MB\n" +
+		"remote: error: File data/dump.sql is 250.00 MB; this exceeds GitHub's file size limit of 100.00 MB\n"
+
+	cases := map[string]struct {
+		Err            error
+		RemoteMessages string
+		ExpectedOK     bool
+		ExpectedReason string
+		ExpectedPaths  []string
+	}{
+		"Large files": {
+			errors.New("command error on refs/heads/master: pre-receive hook declined"),
+			largeFileMessages, true, git.RejectedLargeFile, []string{"assets/video.mp4", "data/dump.sql"},
+		},
+		"Pack too large": {
+			errors.New("command error on refs/heads/master: pack exceeds maximum allowed size"),
+			"", true, git.RejectedPushTooLarge, nil,
+		},
+		"Push too large": {
+			errors.New("unexpected EOF"),
+			"remote: fatal: pack exceeds maximum allowed size (2.00 GiB)\n", true, git.RejectedPushTooLarge, nil,
+		},
+		"HTTP 413 from a proxy": {
+			errors.New("unexpected client error: unexpected requesting \"https://github.com/org/repo/git-receive-pack\" status code: 413"),
+			"", false, "", nil,
+		},
+		"RPC failed": {
+			errors.New("RPC failed; HTTP 502 curl 22 The requested URL returned error: 502"),
+			"", false, "", nil,
+		},
+		"Other refusal": {
+			errors.New("command error on refs/heads/master: protected branch hook declined"),
+			"", true, git.RejectedOther, nil,
+		},
+		"Not a refusal": {
+			errors.New("authentication required"),
+			"", false, "", nil,
+		},
+	}
+
+	for tcName, tc := range cases {
+		rejection, ok := git.ClassifyRejection("refs/heads/master", tc.Err, tc.RemoteMessages)
+		if ok != tc.ExpectedOK {
+			t.Errorf("%v - Expected rejection: %v | Actual: %v", tcName, tc.ExpectedOK, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if rejection.Reason != tc.ExpectedReason || !reflect.DeepEqual(rejection.Paths, tc.ExpectedPaths) {
+			t.Errorf("%v - Expected: %v %v | Actual: %v %v", tcName, tc.ExpectedReason, tc.ExpectedPaths, rejection.Reason, rejection.Paths)
+		}
+	}
+}
+
+func TestLargeFileCommits(t *testing.T) {
+	defaultMaxFileSize := git.MaxFileSize
+	git.MaxFileSize = 10
+	defer func() { git.MaxFileSize = defaultMaxFileSize }()
+
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, _ := repo.Worktree()
+
+	commit := func(files map[string]string) string {
+		for name, contents := range files {
+			f, _ := worktree.Filesystem.Create(name)
+			f.Write([]byte(contents))
+			f.Close()
+			worktree.Add(name)
+		}
+		hash, err := worktree.Commit("commit", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+
+	commit(map[string]string{"small.txt": "small"})
+	introduced := commit(map[string]string{"large.bin": "larger than ten bytes"})
+	tip := commit(map[string]string{"small.txt": "tiny"})
+
+	cases := map[string]struct {
+		Paths           []string
+		ExpectedPaths   []string
+		ExpectedCommits []string
+	}{
+		"Reported path":   {[]string{"large.bin"}, []string{"large.bin"}, []string{introduced}},
+		"No paths":        {nil, []string{"large.bin"}, []string{introduced}},
+		"Small file only": {[]string{"small.txt"}, nil, nil},
+	}
+
+	head, _ := repo.Head()
+	if head.Hash().String() != tip {
+		t.Fatal("Unexpected HEAD")
+	}
+	for tcName, tc := range cases {
+		paths, commits, err := git.LargeFileCommits(repo, head.Hash(), tc.Paths)
+		if err != nil || !reflect.DeepEqual(paths, tc.ExpectedPaths) || !reflect.DeepEqual(commits, tc.ExpectedCommits) {
+			t.Errorf("%v - Expected: %v %v | Actual: %v %v %v", tcName, tc.ExpectedPaths, tc.ExpectedCommits, paths, commits, err)
+		}
+	}
+}
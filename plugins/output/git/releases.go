@@ -0,0 +1,110 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// TagReleases makes a release of every annotated tag, titled by the tag name with the tag
+// message as the notes and the tagger as the author. Lightweight tags have no message and are left out
+func TagReleases(localRepo *git.Repository) ([]common.Release, error) {
+	tags, err := localRepo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []common.Release
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		tag, err := localRepo.TagObject(ref.Hash())
+		if err == plumbing.ErrObjectNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		releases = append(releases, common.Release{
+			Tag:     ref.Name().Short(),
+			Title:   ref.Name().Short(),
+			Notes:   strings.TrimSpace(tag.Message),
+			Author:  common.User{Name: tag.Tagger.Name, DisplayName: tag.Tagger.Name, Email: tag.Tagger.Email},
+			Created: tag.Tagger.When.UTC(),
+		})
+		return nil
+	})
+	return releases, err
+}
+
+// MigrateReleases copies the releases of the source repository, with their assets, to the target
+// repository. Releases are only made for tags that were pushed to the target, since GitHub would
+// otherwise tag its default branch. They are created oldest first so the newest is the latest
+func (gitClient Client) MigrateReleases(repo common.Repository, localRepo *git.Repository, failedTags []string) (*common.MigrationSummary, error) {
+
+	target, ok := gitClient.output.(plugins.ReleaseTarget)
+	if !ok {
+		return nil, fmt.Errorf("Target does not support release migration")
+	}
+	source, ok := gitClient.input.(plugins.ReleaseSource)
+	if !ok && !gitClient.options.ReleasesFromTags {
+		return nil, fmt.Errorf("Source does not support release migration")
+	}
+
+	var releases []common.Release
+	if source != nil {
+		sourceReleases, err := source.Releases(gitClient.context(), repo)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, sourceReleases...)
+	}
+
+	if gitClient.options.ReleasesFromTags {
+		tagReleases, err := TagReleases(localRepo)
+		if err != nil {
+			return nil, err
+		}
+		released := map[string]bool{}
+		for _, release := range releases {
+			released[release.Tag] = true
+		}
+		for _, release := range tagReleases {
+			if !released[release.Tag] {
+				releases = append(releases, release)
+			}
+		}
+	}
+
+	failed := map[string]bool{}
+	for _, tag := range failedTags {
+		failed[tag] = true
+	}
+
+	summary := common.MigrationSummary{}
+	var pushed []common.Release
+	for _, release := range releases {
+		if _, err := localRepo.Tag(release.Tag); err != nil || failed[release.Tag] {
+			summary.Skipped++
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"tag":         release.Tag,
+			}).Debugf("Release tag not on target, skipping release")
+			continue
+		}
+		pushed = append(pushed, release)
+	}
+	sort.SliceStable(pushed, func(i, j int) bool {
+		return pushed[i].Created.Before(pushed[j].Created)
+	})
+
+	targetSummary, err := target.MigrateReleases(gitClient.context(), repo, pushed, source)
+	targetSummary.Skipped += summary.Skipped
+	return &targetSummary, err
+}
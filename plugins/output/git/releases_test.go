@@ -0,0 +1,50 @@
+package git_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	memfs "github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+	memory "github.com/go-git/go-git/v5/storage/memory"
+
+	common "github.com/parinithshekar/gitsink/common"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+)
+
+func TestTagReleases(t *testing.T) {
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, _ := repo.Worktree()
+
+	tagged := time.Date(2020, 5, 2, 9, 30, 0, 0, time.UTC)
+	tagger := &object.Signature{Name: "Alice", Email: "alice@example.com", When: tagged}
+	f, _ := worktree.Filesystem.Create("README.md")
+	f.Write([]byte("readme"))
+	f.Close()
+	worktree.Add("README.md")
+	head, err := worktree.Commit("commit", &gogit.CommitOptions{Author: tagger})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.CreateTag("v1.0.0", head, &gogit.CreateTagOptions{Tagger: tagger, Message: "First release\n\n- Adds a readme\n"})
+	repo.CreateTag("lightweight", head, nil)
+
+	expected := []common.Release{{
+		Tag:     "v1.0.0",
+		Title:   "v1.0.0",
+		Notes:   "First release\n\n- Adds a readme",
+		Author:  common.User{Name: "Alice", DisplayName: "Alice", Email: "alice@example.com"},
+		Created: tagged,
+	}}
+
+	releases, err := git.TagReleases(repo)
+	if err != nil || !reflect.DeepEqual(releases, expected) {
+		t.Errorf("Expected: %+v | Actual: %+v %v", expected, releases, err)
+	}
+}
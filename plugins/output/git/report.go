@@ -0,0 +1,58 @@
+package git
+
+import (
+	"time"
+
+	common "github.com/parinithshekar/gitsink/common"
+	lfs "github.com/parinithshekar/gitsink/plugins/output/git/lfs"
+)
+
+// Report has the outcome of syncing the repositories of an integration
+// RunID ties the report to the log lines of the run, Skipped repositories were not started
+// because the run was canceled
+type Report struct {
+	RunID        string             `json:"runId,omitempty"`
+	Integration  string             `json:"integration"`
+	Started      time.Time          `json:"started"`
+	Finished     time.Time          `json:"finished"`
+	Repositories []RepositoryReport `json:"repositories"`
+	Canceled     bool               `json:"canceled,omitempty"`
+	Skipped      []string           `json:"skipped,omitempty"`
+}
+
+// RepositoryReport has the outcome of syncing one repository
+type RepositoryReport struct {
+	Slug           string                   `json:"slug"`
+	FailedTags     []string                 `json:"failedTags,omitempty"`
+	FailedBranches []string                 `json:"failedBranches,omitempty"`
+	Rejections     []Rejection              `json:"rejections,omitempty"`
+	Divergences    []Divergence             `json:"divergences,omitempty"`
+	LFS            *lfs.Summary             `json:"lfs,omitempty"`
+	WikiError      string                   `json:"wikiError,omitempty"`
+	Releases       *common.MigrationSummary `json:"releases,omitempty"`
+	PullRequests   *common.MigrationSummary `json:"pullRequests,omitempty"`
+	Issues         *common.MigrationSummary `json:"issues,omitempty"`
+	Cutover        *Cutover                 `json:"cutover,omitempty"`
+	Error          string                   `json:"error,omitempty"`
+}
+
+// Failed checks if anything in the repository was not synced
+func (report RepositoryReport) Failed() bool {
+	lfsFailed := report.LFS != nil && len(report.LFS.Failed) > 0
+	releasesFailed := report.Releases != nil && report.Releases.Failed > 0
+	pullRequestsFailed := report.PullRequests != nil && report.PullRequests.Failed > 0
+	issuesFailed := report.Issues != nil && report.Issues.Failed > 0
+	cutoverFailed := report.Cutover != nil && !report.Cutover.Locked
+	return report.Error != "" || report.WikiError != "" || len(report.FailedTags) > 0 || len(report.FailedBranches) > 0 || len(report.Divergences) > 0 || lfsFailed || releasesFailed || pullRequestsFailed || issuesFailed || cutoverFailed
+}
+
+// Failed lists the repositories that were not completely synced
+func (report Report) Failed() []RepositoryReport {
+	var failed []RepositoryReport
+	for _, repoReport := range report.Repositories {
+		if repoReport.Failed() {
+			failed = append(failed, repoReport)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,176 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	transportgit "github.com/go-git/go-git/v5/plumbing/transport"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	utils "github.com/parinithshekar/gitsink/common/utils"
+)
+
+// Sides of a two-way sync
+const (
+	SideSource = "source"
+	SideTarget = "target"
+)
+
+// Divergence describes a branch with commits on both sides that neither side has
+// It is left for people to resolve, the authoritative side says whose changes should win
+type Divergence struct {
+	Branch            string `json:"branch"`
+	TargetBranch      string `json:"targetBranch"`
+	SourceCommit      string `json:"sourceCommit"`
+	TargetCommit      string `json:"targetCommit"`
+	AuthoritativeSide string `json:"authoritativeSide,omitempty"`
+}
+
+// Relation says how the tips of a branch on the source and target relate
+type Relation int
+
+// Relations between the source and target tips of a branch
+const (
+	Same Relation = iota
+	SourceAhead
+	TargetAhead
+	Diverged
+)
+
+// Compare finds how the source tip relates to the target tip
+func Compare(localRepo *git.Repository, sourceTip, targetTip plumbing.Hash) (Relation, error) {
+	if sourceTip == targetTip {
+		return Same, nil
+	}
+
+	sourceCommit, err := localRepo.CommitObject(sourceTip)
+	if err != nil {
+		return Diverged, err
+	}
+	targetCommit, err := localRepo.CommitObject(targetTip)
+	if err != nil {
+		return Diverged, err
+	}
+
+	targetBehind, err := targetCommit.IsAncestor(sourceCommit)
+	if err != nil {
+		return Diverged, err
+	}
+	if targetBehind {
+		return SourceAhead, nil
+	}
+	sourceBehind, err := sourceCommit.IsAncestor(targetCommit)
+	if err != nil {
+		return Diverged, err
+	}
+	if sourceBehind {
+		return TargetAhead, nil
+	}
+	return Diverged, nil
+}
+
+// fetchTarget brings the target branches into refs/remotes/target for a two-way sync
+// Refs from earlier runs are dropped first so branches deleted on the target are not compared
+func fetchTarget(ctx context.Context, localRepo *git.Repository, targetAuth transportgit.AuthMethod) error {
+	refs, err := localRepo.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), "refs/remotes/target/") {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	for _, name := range stale {
+		localRepo.Storer.RemoveReference(name)
+	}
+
+	fo := git.FetchOptions{
+		RemoteName: "target",
+		Auth:       targetAuth,
+	}
+	fo.Validate()
+	err = localRepo.FetchContext(ctx, &fo)
+	if err == git.NoErrAlreadyUpToDate || err == transportgit.ErrEmptyRemoteRepository {
+		return nil
+	}
+	return err
+}
+
+// authoritativeSide gives the side whose changes win for the source branch
+func (gitClient Client) authoritativeSide(branch string) string {
+	return utils.AuthoritativeSide(branch, gitClient.options.BranchSides)
+}
+
+// syncTwoWay propagates a fast-forward of the branch on the target back to the source, and finds
+// branches that diverged. Returns false if the branch is left for the one-way push to the target,
+// which is the case when the target does not have it or the source is ahead
+func (gitClient Client) syncTwoWay(repo common.Repository, localRepo *git.Repository, branch, targetBranch string, sourceAuth transportgit.AuthMethod) (bool, *Divergence, error) {
+
+	sourceTip, err := localRepo.ResolveRevision(plumbing.Revision("refs/remotes/origin/" + branch))
+	if err != nil {
+		return true, nil, err
+	}
+	targetTip, err := localRepo.ResolveRevision(plumbing.Revision("refs/remotes/target/" + targetBranch))
+	if err != nil {
+		// The branch is new to the target
+		return false, nil, nil
+	}
+
+	relation, err := Compare(localRepo, *sourceTip, *targetTip)
+	if err != nil {
+		return true, nil, err
+	}
+	side := gitClient.authoritativeSide(branch)
+
+	switch relation {
+	case SourceAhead:
+		if side == SideTarget {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"branch":      branch,
+			}).Debugf("Source ahead of authoritative target, branch not pushed")
+			return true, nil, nil
+		}
+		return false, nil, nil
+
+	case TargetAhead:
+		if side == SideSource {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"branch":      branch,
+			}).Debugf("Target ahead of authoritative source, branch not pulled")
+			return true, nil, nil
+		}
+		refspec := fmt.Sprintf("refs/remotes/target/%v:refs/heads/%v", targetBranch, branch)
+		_, err = pushRemote(gitClient.context(), localRepo, "origin", refspec, sourceAuth)
+		if err == nil {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"branch":      branch,
+			}).Infof("Target changes pushed to source")
+		}
+		return true, nil, err
+
+	case Diverged:
+		return true, &Divergence{
+			Branch:            branch,
+			TargetBranch:      targetBranch,
+			SourceCommit:      sourceTip.String(),
+			TargetCommit:      targetTip.String(),
+			AuthoritativeSide: side,
+		}, nil
+
+	default:
+		return true, nil, nil
+	}
+}
@@ -0,0 +1,143 @@
+package git_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	common "github.com/parinithshekar/gitsink/common"
+	config "github.com/parinithshekar/gitsink/common/config"
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
+)
+
+func TestSyncBranchesTwoWay(t *testing.T) {
+	os.Setenv(envSourceAccountID, "username")
+	os.Setenv(envSourceAccessToken, "token")
+	os.Setenv(envTargetAccountID, "username")
+	os.Setenv(envTargetAccessToken, "token")
+	defer os.Unsetenv(envSourceAccountID)
+	defer os.Unsetenv(envSourceAccessToken)
+	defer os.Unsetenv(envTargetAccountID)
+	defer os.Unsetenv(envTargetAccessToken)
+
+	dir, err := ioutil.TempDir("", "gitsink-two-way")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "source.git")
+	targetPath := filepath.Join(dir, "target.git")
+	sourceRepo, _ := gogit.PlainInit(sourcePath, true)
+	targetRepo, _ := gogit.PlainInit(targetPath, true)
+
+	// A scratch repository makes the commits and pushes them to either side
+	scratch, _ := gogit.PlainInit(filepath.Join(dir, "scratch"), false)
+	scratch.CreateRemote(&gitconfig.RemoteConfig{Name: "source", URLs: []string{sourcePath}})
+	scratch.CreateRemote(&gitconfig.RemoteConfig{Name: "target", URLs: []string{targetPath}})
+	worktree, _ := scratch.Worktree()
+
+	commits := 0
+	commit := func(parent plumbing.Hash) plumbing.Hash {
+		commits++
+		if !parent.IsZero() {
+			worktree.Checkout(&gogit.CheckoutOptions{Hash: parent, Force: true})
+		}
+		name := fmt.Sprintf("file-%v.txt", commits)
+		ioutil.WriteFile(filepath.Join(dir, "scratch", name), []byte(name), 0644)
+		worktree.Add(name)
+		hash, err := worktree.Commit(name, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	pushTo := func(remote, branch string, hash plumbing.Hash) {
+		scratch.Storer.SetReference(plumbing.NewHashReference("refs/heads/scratch", hash))
+		err := scratch.Push(&gogit.PushOptions{
+			RemoteName: remote,
+			RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec("+refs/heads/scratch:refs/heads/" + branch)},
+		})
+		if err != nil && err != gogit.NoErrAlreadyUpToDate {
+			t.Fatal(err)
+		}
+	}
+
+	base := commit(plumbing.ZeroHash)
+	targetAhead := commit(base)
+	sourceAhead := commit(base)
+	divergedSource := commit(base)
+	divergedTarget := commit(base)
+
+	sides := map[string]struct{ Source, Target plumbing.Hash }{
+		"master":       {base, base},
+		"target-ahead": {base, targetAhead},
+		"source-ahead": {sourceAhead, base},
+		"diverged":     {divergedSource, divergedTarget},
+		"locked":       {base, targetAhead},
+		"new":          {sourceAhead, plumbing.ZeroHash},
+	}
+	for branch, tips := range sides {
+		pushTo("source", branch, tips.Source)
+		if !tips.Target.IsZero() {
+			pushTo("target", branch, tips.Target)
+		}
+	}
+
+	localRepo, err := gogit.PlainClone(filepath.Join(dir, "local"), false, &gogit.CloneOptions{URL: sourcePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	localRepo.CreateRemote(&gitconfig.RemoteConfig{Name: "target", URLs: []string{targetPath}})
+
+	input, _ := bbserver.New(source)
+	public, _ := ghpublic.New(target)
+	gitClient := git.New(input, localOutput{Public: public}, "test-integration", git.Options{
+		TwoWay:      true,
+		BranchSides: []config.BranchSide{{Match: "locked", AuthoritativeSide: git.SideSource}},
+	})
+
+	failed, _, divergences, err := gitClient.SyncBranches(common.Repository{Slug: "repo"}, localRepo)
+	if err != nil {
+		t.Errorf("Expected no error | Actual: %v %v", failed, err)
+	}
+
+	expectedDivergences := []git.Divergence{{
+		Branch:       "diverged",
+		TargetBranch: "diverged",
+		SourceCommit: divergedSource.String(),
+		TargetCommit: divergedTarget.String(),
+	}}
+	if !reflect.DeepEqual(divergences, expectedDivergences) {
+		t.Errorf("Expected divergences: %+v | Actual: %+v", expectedDivergences, divergences)
+	}
+
+	expected := map[string]struct{ Source, Target plumbing.Hash }{
+		"master":       {base, base},
+		"target-ahead": {targetAhead, targetAhead},
+		"source-ahead": {sourceAhead, sourceAhead},
+		"diverged":     {divergedSource, divergedTarget},
+		"locked":       {base, targetAhead},
+		"new":          {sourceAhead, sourceAhead},
+	}
+	for branch, tips := range expected {
+		sourceRef, _ := sourceRepo.Reference(plumbing.NewBranchReferenceName(branch), false)
+		targetRef, _ := targetRepo.Reference(plumbing.NewBranchReferenceName(branch), false)
+		if sourceRef == nil || targetRef == nil || sourceRef.Hash() != tips.Source || targetRef.Hash() != tips.Target {
+			t.Errorf("%v - Expected: %v %v | Actual: %v %v", branch, tips.Source, tips.Target, sourceRef, targetRef)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package git
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	config "github.com/go-git/go-git/v5/config"
+	plumbing "github.com/go-git/go-git/v5/plumbing"
+	transportgit "github.com/go-git/go-git/v5/plumbing/transport"
+	memory "github.com/go-git/go-git/v5/storage/memory"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// Kinds of drift between a source ref and the target
+const (
+	// DriftMissing is a synced source ref that is not on the target
+	DriftMissing = "missing"
+	// DriftExtra is a target branch or tag that no source ref is synced to
+	DriftExtra = "extra"
+	// DriftMismatched is a synced source ref whose target copy points to another commit
+	DriftMismatched = "mismatched"
+)
+
+// RefDrift is a ref that differs between the source and the target
+// Ref is empty for extra refs, which only exist on the target
+type RefDrift struct {
+	Drift     string `json:"drift"`
+	Ref       string `json:"ref,omitempty"`
+	TargetRef string `json:"targetRef"`
+	Source    string `json:"source,omitempty"`
+	Target    string `json:"target,omitempty"`
+}
+
+// RepositoryDrift has the refs that differ between a source repository and its target
+type RepositoryDrift struct {
+	Slug  string     `json:"slug"`
+	Drift []RefDrift `json:"drift,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// Drifted checks if the target is not an exact copy of the synced source refs
+func (drift RepositoryDrift) Drifted() bool {
+	return drift.Error != "" || len(drift.Drift) > 0
+}
+
+// VerifyReport has the outcome of verifying the repositories of an integration
+type VerifyReport struct {
+	Integration  string            `json:"integration"`
+	Started      time.Time         `json:"started"`
+	Finished     time.Time         `json:"finished"`
+	Repositories []RepositoryDrift `json:"repositories"`
+	Canceled     bool              `json:"canceled,omitempty"`
+}
+
+// Drifted lists the repositories whose target differs from the source
+func (report VerifyReport) Drifted() []RepositoryDrift {
+	var drifted []RepositoryDrift
+	for _, repoDrift := range report.Repositories {
+		if repoDrift.Drifted() {
+			drifted = append(drifted, repoDrift)
+		}
+	}
+	return drifted
+}
+
+// CompareRefs checks that every source branch and tag is on the target at the same commit, and
+// that the target has no other branches or tags. Branches go through the branch modifiers, and
+// branches that are not synced are left out
+func CompareRefs(sourceRefs, targetRefs map[string]string, targetBranch func(string) (string, bool)) []RefDrift {
+	var drift []RefDrift
+	synced := map[string]bool{}
+
+	for ref, hash := range sourceRefs {
+		name := plumbing.ReferenceName(ref)
+
+		targetRef := ref
+		switch {
+		case name.IsBranch():
+			branch, isSynced := targetBranch(name.Short())
+			if !isSynced {
+				continue
+			}
+			targetRef = plumbing.NewBranchReferenceName(branch).String()
+		case name.IsTag():
+		default:
+			continue
+		}
+		synced[targetRef] = true
+
+		targetHash, exists := targetRefs[targetRef]
+		switch {
+		case !exists:
+			drift = append(drift, RefDrift{Drift: DriftMissing, Ref: ref, TargetRef: targetRef, Source: hash})
+		case targetHash != hash:
+			drift = append(drift, RefDrift{Drift: DriftMismatched, Ref: ref, TargetRef: targetRef, Source: hash, Target: targetHash})
+		}
+	}
+
+	for targetRef, hash := range targetRefs {
+		name := plumbing.ReferenceName(targetRef)
+		if (name.IsBranch() || name.IsTag()) && !synced[targetRef] {
+			drift = append(drift, RefDrift{Drift: DriftExtra, TargetRef: targetRef, Target: hash})
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].TargetRef < drift[j].TargetRef
+	})
+	return drift
+}
+
+// listRefs lists the refs of a remote repository without a local copy
+func listRefs(URL string, auth transportgit.AuthMethod) (map[string]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{URL},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err == transportgit.ErrEmptyRemoteRepository {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]string{}
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Name().String()] = ref.Hash().String()
+		}
+	}
+	return hashes, nil
+}
+
+// VerifyRefs compares the branches and tags of the source repository with the target repository
+func (gitClient Client) VerifyRefs(repo common.Repository) ([]RefDrift, error) {
+
+	sourceAuth, err := gitClient.sourceAuth()
+	if err != nil {
+		return nil, err
+	}
+	targetAuth, err := gitClient.targetAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceRefs, err := listRefs(repo.Source, sourceAuth)
+	if err != nil {
+		return nil, err
+	}
+	targetRefs, err := listRefs(repo.Target, targetAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	return CompareRefs(sourceRefs, targetRefs, gitClient.output.TargetBranch), nil
+}
+
+// Verify compares the refs of every repository with its target without changing either side
+// Repositories without a target URL are reported as missing on the target
+// Once ctx is canceled no more repositories are compared, and the report is marked canceled
+func (gitClient Client) Verify(ctx context.Context, repos []common.Repository) VerifyReport {
+
+	report := VerifyReport{
+		Integration: gitClient.integrationName,
+		Started:     time.Now(),
+	}
+
+	gitClient.installTransport(repos)
+
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			report.Canceled = true
+			break
+		}
+		repoDrift := RepositoryDrift{Slug: repo.Slug}
+
+		if repo.Target == "" {
+			repoDrift.Error = "Repository not found on target"
+		} else {
+			drift, err := gitClient.VerifyRefs(repo)
+			if err != nil {
+				repoDrift.Error = err.Error()
+			}
+			repoDrift.Drift = drift
+		}
+
+		if repoDrift.Drifted() {
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+				"drift":       len(repoDrift.Drift),
+				"error":       repoDrift.Error,
+			}).Warningf("Target differs from source")
+		}
+		report.Repositories = append(report.Repositories, repoDrift)
+	}
+
+	report.Finished = time.Now()
+	return report
+}
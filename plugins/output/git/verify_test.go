@@ -0,0 +1,147 @@
+package git_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	common "github.com/parinithshekar/gitsink/common"
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
+)
+
+func TestCompareRefs(t *testing.T) {
+	// Branches starting with wip are not synced, and release branches get a prefix on the target
+	targetBranch := func(branch string) (string, bool) {
+		if strings.HasPrefix(branch, "wip") {
+			return "", false
+		}
+		if strings.HasPrefix(branch, "release") {
+			return "bb-" + branch, true
+		}
+		return branch, true
+	}
+
+	cases := map[string]struct {
+		Source, Target map[string]string
+		Expected       []git.RefDrift
+	}{
+		"Matching": {
+			Source:   map[string]string{"refs/heads/master": "a1", "refs/heads/release/1.0": "b1", "refs/tags/v1.0": "c1", "HEAD": "a1"},
+			Target:   map[string]string{"refs/heads/master": "a1", "refs/heads/bb-release/1.0": "b1", "refs/tags/v1.0": "c1", "HEAD": "a1"},
+			Expected: nil,
+		},
+		"Unsynced branch": {
+			Source:   map[string]string{"refs/heads/master": "a1", "refs/heads/wip": "e1"},
+			Target:   map[string]string{"refs/heads/master": "a1"},
+			Expected: nil,
+		},
+		"Missing, extra and mismatched refs": {
+			Source: map[string]string{"refs/heads/master": "a2", "refs/heads/release/1.0": "b1", "refs/tags/v1.0": "c1"},
+			Target: map[string]string{"refs/heads/master": "a1", "refs/heads/bb-release/1.0": "b1", "refs/heads/hotfix": "d1", "refs/pull/1/head": "f1"},
+			Expected: []git.RefDrift{
+				{Drift: git.DriftExtra, TargetRef: "refs/heads/hotfix", Target: "d1"},
+				{Drift: git.DriftMismatched, Ref: "refs/heads/master", TargetRef: "refs/heads/master", Source: "a2", Target: "a1"},
+				{Drift: git.DriftMissing, Ref: "refs/tags/v1.0", TargetRef: "refs/tags/v1.0", Source: "c1"},
+			},
+		},
+	}
+
+	for tcName, tc := range cases {
+		actual := git.CompareRefs(tc.Source, tc.Target, targetBranch)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("%v - Expected drift: %+v | Actual: %+v", tcName, tc.Expected, actual)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	os.Setenv(envSourceAccountID, "username")
+	os.Setenv(envSourceAccessToken, "token")
+	os.Setenv(envTargetAccountID, "username")
+	os.Setenv(envTargetAccessToken, "token")
+	defer os.Unsetenv(envSourceAccountID)
+	defer os.Unsetenv(envSourceAccessToken)
+	defer os.Unsetenv(envTargetAccountID)
+	defer os.Unsetenv(envTargetAccessToken)
+
+	dir, err := ioutil.TempDir("", "gitsink-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "source.git")
+	targetPath := filepath.Join(dir, "target.git")
+	gogit.PlainInit(sourcePath, true)
+	gogit.PlainInit(targetPath, true)
+
+	scratch, _ := gogit.PlainInit(filepath.Join(dir, "scratch"), false)
+	scratch.CreateRemote(&gitconfig.RemoteConfig{Name: "source", URLs: []string{sourcePath}})
+	scratch.CreateRemote(&gitconfig.RemoteConfig{Name: "target", URLs: []string{targetPath}})
+	worktree, _ := scratch.Worktree()
+	ioutil.WriteFile(filepath.Join(dir, "scratch", "file.txt"), []byte("file"), 0644)
+	worktree.Add("file.txt")
+	_, err = worktree.Commit("file", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	push := func(remote string, refspecs ...string) {
+		var specs []gitconfig.RefSpec
+		for _, refspec := range refspecs {
+			specs = append(specs, gitconfig.RefSpec(refspec))
+		}
+		err := scratch.Push(&gogit.PushOptions{RemoteName: remote, RefSpecs: specs})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	head, _ := scratch.Head()
+	scratch.CreateTag("v1.0", head.Hash(), nil)
+	push("source", "refs/heads/master:refs/heads/master", "refs/heads/master:refs/heads/develop", "refs/tags/v1.0:refs/tags/v1.0")
+	push("target", "refs/heads/master:refs/heads/master", "refs/heads/master:refs/heads/stale")
+
+	input, _ := bbserver.New(source)
+	public, _ := ghpublic.New(target)
+	gitClient := git.New(input, localOutput{Public: public}, "test-integration", git.Options{})
+
+	repos := []common.Repository{
+		{Slug: "repo", Source: sourcePath, Target: targetPath},
+		{Slug: "unmigrated", Source: sourcePath},
+	}
+	report := gitClient.Verify(context.Background(), repos)
+
+	hash := head.Hash().String()
+	expected := []git.RepositoryDrift{
+		{Slug: "repo", Drift: []git.RefDrift{
+			{Drift: git.DriftMissing, Ref: "refs/heads/develop", TargetRef: "refs/heads/develop", Source: hash},
+			{Drift: git.DriftExtra, TargetRef: "refs/heads/stale", Target: hash},
+			{Drift: git.DriftMissing, Ref: "refs/tags/v1.0", TargetRef: "refs/tags/v1.0", Source: hash},
+		}},
+		{Slug: "unmigrated", Error: "Repository not found on target"},
+	}
+	if !reflect.DeepEqual(report.Repositories, expected) || len(report.Drifted()) != 2 {
+		t.Errorf("Expected drift: %+v | Actual: %+v", expected, report.Repositories)
+	}
+
+	// A canceled run compares no more repositories
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report = gitClient.Verify(ctx, repos)
+	if !report.Canceled || len(report.Repositories) != 0 {
+		t.Errorf("Expected canceled verification | Actual: %+v", report)
+	}
+}
@@ -0,0 +1,101 @@
+package git
+
+import (
+	"fmt"
+	"os"
+
+	git "github.com/go-git/go-git/v5"
+	config "github.com/go-git/go-git/v5/config"
+	transportgit "github.com/go-git/go-git/v5/plumbing/transport"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// wikiSuffix names the local copy of a wiki after its repository
+const wikiSuffix = ".wiki"
+
+// wikiRefspec pushes every wiki branch, overwriting the target since its wiki mirrors the source
+const wikiRefspec = "+refs/remotes/origin/*:refs/heads/*"
+
+// SyncWiki copies the git repository of the source wiki to the wiki of the target repository
+// Repositories without a wiki, or whose wiki has no pages yet, are skipped
+func (gitClient Client) SyncWiki(repo common.Repository) error {
+
+	if repo.Wiki == "" {
+		return nil
+	}
+	target, ok := gitClient.output.(plugins.WikiTarget)
+	if !ok {
+		return fmt.Errorf("Target does not support wikis")
+	}
+
+	sourceAuth, err := gitClient.sourceAuth()
+	if err != nil {
+		return err
+	}
+	targetAuth, err := gitClient.targetAuth()
+	if err != nil {
+		return err
+	}
+
+	// Clone the wiki next to its repository, or fetch if it was cloned before
+	localPath := repo.Slug + wikiSuffix
+	var localRepo *git.Repository
+	if _, err := os.Stat(localPath); os.IsNotExist(err) {
+		co := git.CloneOptions{
+			URL:  repo.Wiki,
+			Auth: sourceAuth,
+		}
+		co.Validate()
+		localRepo, err = git.PlainCloneContext(gitClient.context(), localPath, true, &co)
+		if err == transportgit.ErrRepositoryNotFound || err == transportgit.ErrEmptyRemoteRepository {
+			os.RemoveAll(localPath)
+			gitClient.log.WithFields(logrus.Fields{
+				"integration": gitClient.integrationName,
+				"repository":  repo.Slug,
+			}).Debugf("Source wiki has no pages, skipping")
+			return nil
+		}
+		if err != nil {
+			os.RemoveAll(localPath)
+			return err
+		}
+	} else {
+		localRepo, err = git.PlainOpen(localPath)
+		if err != nil {
+			return err
+		}
+		fo := git.FetchOptions{
+			RemoteName: "origin",
+			Auth:       sourceAuth,
+		}
+		fo.Validate()
+		err = localRepo.FetchContext(gitClient.context(), &fo)
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+	}
+
+	targetWiki, err := target.EnableWiki(gitClient.context(), repo)
+	if err != nil {
+		return err
+	}
+
+	// The target wiki can move between runs, so the remote is set afresh
+	localRepo.DeleteRemote("target")
+	_, err = localRepo.CreateRemote(&config.RemoteConfig{
+		Name: "target",
+		URLs: []string{targetWiki},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = push(gitClient.context(), localRepo, wikiRefspec, targetAuth)
+	if err == transportgit.ErrRepositoryNotFound {
+		return fmt.Errorf("Target wiki not found, create its first page to initialize it")
+	}
+	return err
+}
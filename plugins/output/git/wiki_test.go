@@ -0,0 +1,91 @@
+package git_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	common "github.com/parinithshekar/gitsink/common"
+	bbserver "github.com/parinithshekar/gitsink/plugins/input/bitbucket/server"
+	git "github.com/parinithshekar/gitsink/plugins/output/git"
+	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
+)
+
+func TestSyncWiki(t *testing.T) {
+	os.Setenv(envSourceAccountID, "username")
+	os.Setenv(envSourceAccessToken, "token")
+	os.Setenv(envTargetAccountID, "username")
+	os.Setenv(envTargetAccessToken, "token")
+	defer os.Unsetenv(envSourceAccountID)
+	defer os.Unsetenv(envSourceAccessToken)
+	defer os.Unsetenv(envTargetAccountID)
+	defer os.Unsetenv(envTargetAccessToken)
+
+	dir, err := ioutil.TempDir("", "gitsink-wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+
+	// Source wiki with one page
+	sourceWiki := filepath.Join(dir, "source.wiki")
+	sourceRepo, _ := gogit.PlainInit(sourceWiki, false)
+	worktree, _ := sourceRepo.Worktree()
+	ioutil.WriteFile(filepath.Join(sourceWiki, "Home.md"), []byte("# Home"), 0644)
+	worktree.Add("Home.md")
+	sourceHash, err := worktree.Commit("Add home page", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty target wiki
+	targetWiki := filepath.Join(dir, "target.wiki")
+	gogit.PlainInit(targetWiki, true)
+
+	input, _ := bbserver.New(source)
+	public, _ := ghpublic.New(target)
+	gitClient := git.New(input, localOutput{public, targetWiki}, "test-integration", git.Options{Wiki: true})
+
+	cases := map[string]struct {
+		Repo          common.Repository
+		ExpectedError bool
+	}{
+		"No wiki":      {common.Repository{Slug: "none"}, false},
+		"Missing wiki": {common.Repository{Slug: "missing", Wiki: filepath.Join(dir, "missing.wiki")}, false},
+		"Wiki":         {common.Repository{Slug: "repo", Wiki: sourceWiki}, false},
+		"Wiki again":   {common.Repository{Slug: "repo", Wiki: sourceWiki}, false},
+	}
+
+	for _, tcName := range []string{"No wiki", "Missing wiki", "Wiki", "Wiki again"} {
+		tc := cases[tcName]
+		err := gitClient.SyncWiki(tc.Repo)
+		if (err != nil) != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual: %v", tcName, tc.ExpectedError, err)
+		}
+	}
+
+	targetRepo, err := gogit.PlainOpen(targetWiki)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := targetRepo.Reference("refs/heads/master", false)
+	if err != nil || ref.Hash() != sourceHash {
+		t.Errorf("Expected target master at %v | Actual: %v %v", sourceHash, ref, err)
+	}
+	if _, err := targetRepo.Reference("refs/heads/HEAD", false); err == nil {
+		t.Errorf("Expected no HEAD branch on the target")
+	}
+	if _, err := os.Stat("missing.wiki"); !os.IsNotExist(err) {
+		t.Errorf("Expected no local copy of a missing wiki")
+	}
+}
@@ -0,0 +1,283 @@
+package public
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+const (
+	// issueMarker tags a migrated issue with its source ID so later runs skip it
+	issueMarker = "<!-- gitsink:issue:%v -->"
+	// commentMarker tags a migrated issue or pull request comment with its source ID so later runs skip it
+	commentMarker = "<!-- gitsink:comment:%v -->"
+	// defaultLabelColor is used for labels that have no color on the source
+	defaultLabelColor = "ededed"
+)
+
+var (
+	// issueMarkerPattern finds the source ID in the body of a migrated issue
+	issueMarkerPattern = regexp.MustCompile(`<!-- gitsink:issue:(\d+) -->`)
+	// commentMarkerPattern finds the source ID in the body of a migrated comment
+	commentMarkerPattern = regexp.MustCompile(`<!-- gitsink:comment:(\S+) -->`)
+)
+
+// IssueBody gives the body of the target issue, with the author and date from the source
+// above the description
+func IssueBody(ctx context.Context, issue common.Issue, users plugins.UserMapper) string {
+	var body strings.Builder
+
+	origin := "Migrated issue"
+	if issue.URL != "" {
+		origin = fmt.Sprintf("Migrated from %v", issue.URL)
+	}
+	fmt.Fprintf(&body, "> %v, opened by %v on %v\n", origin, userText(ctx, issue.Author, users), issue.Created.Format(dateFormat))
+	if issue.Description != "" {
+		fmt.Fprintf(&body, "\n%v\n", issue.Description)
+	}
+	fmt.Fprintf(&body, "\n"+issueMarker+"\n", issue.ID)
+	return body.String()
+}
+
+// IssueCommentBody gives the body of a target issue or pull request comment, tagged with its source ID
+func IssueCommentBody(ctx context.Context, comment common.Comment, users plugins.UserMapper) string {
+	return CommentBody(ctx, comment, users) + "\n\n" + fmt.Sprintf(commentMarker, comment.ID)
+}
+
+// ensureLabels creates the labels of the issues missing on the target
+func (public Public) ensureLabels(ctx context.Context, owner, name string, issues []common.Issue) error {
+	existing := map[string]bool{}
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		labels, response, err := public.api.Issues.ListLabels(ctx, owner, name, opt)
+		if err != nil {
+			return err
+		}
+		for _, label := range labels {
+			existing[strings.ToLower(label.GetName())] = true
+		}
+		if response.NextPage == 0 {
+			break
+		}
+		opt.Page = response.NextPage
+	}
+
+	for _, issue := range issues {
+		for _, label := range issue.Labels {
+			if existing[strings.ToLower(label.Name)] {
+				continue
+			}
+			color := label.Color
+			if color == "" {
+				color = defaultLabelColor
+			}
+			newLabel := github.Label{Name: &label.Name, Color: &color}
+			if label.Description != "" {
+				newLabel.Description = &label.Description
+			}
+			_, _, err := public.api.Issues.CreateLabel(ctx, owner, name, &newLabel)
+			if err != nil {
+				return err
+			}
+			existing[strings.ToLower(label.Name)] = true
+		}
+	}
+	return nil
+}
+
+// ensureMilestones creates the milestones of the issues missing on the target, giving
+// the target number of every milestone by its title
+func (public Public) ensureMilestones(ctx context.Context, owner, name string, issues []common.Issue) (map[string]int, error) {
+	numbers := map[string]int{}
+	opt := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, response, err := public.api.Issues.ListMilestones(ctx, owner, name, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, milestone := range milestones {
+			numbers[milestone.GetTitle()] = milestone.GetNumber()
+		}
+		if response.NextPage == 0 {
+			break
+		}
+		opt.Page = response.NextPage
+	}
+
+	for _, issue := range issues {
+		if issue.Milestone == nil {
+			continue
+		}
+		if _, ok := numbers[issue.Milestone.Title]; ok {
+			continue
+		}
+		milestone := issue.Milestone
+		newMilestone := github.Milestone{
+			Title:       &milestone.Title,
+			Description: &milestone.Description,
+			State:       &milestone.State,
+		}
+		if milestone.Due != nil {
+			newMilestone.DueOn = milestone.Due
+		}
+		created, _, err := public.api.Issues.CreateMilestone(ctx, owner, name, &newMilestone)
+		if err != nil {
+			return nil, err
+		}
+		numbers[milestone.Title] = created.GetNumber()
+	}
+	return numbers, nil
+}
+
+// migratedComments gives the source IDs of the comments already migrated to a target issue or pull request
+func (public Public) migratedComments(ctx context.Context, owner, name string, number int) (map[string]bool, error) {
+	migrated := map[string]bool{}
+	opt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, response, err := public.api.Issues.ListComments(ctx, owner, name, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			if match := commentMarkerPattern.FindStringSubmatch(comment.GetBody()); match != nil {
+				migrated[match[1]] = true
+			}
+		}
+		if response.NextPage == 0 {
+			return migrated, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+// MigrateIssues recreates the issues on the target repository with their comments, labels,
+// milestones and state. Migrated issues and comments are found by the source IDs in their
+// bodies, so later runs only append new issues and comments and update the state
+func (public Public) MigrateIssues(ctx context.Context, repo common.Repository, issues []common.Issue) (common.MigrationSummary, error) {
+	summary := common.MigrationSummary{}
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	owner := kindSplit[1]
+
+	migrated, err := public.migrated(ctx, owner, repo.Slug, issueMarkerPattern)
+	if err != nil {
+		return summary, err
+	}
+	err = public.ensureLabels(ctx, owner, repo.Slug, issues)
+	if err != nil {
+		return summary, fmt.Errorf("Labels could not be created: %w", err)
+	}
+	milestones, err := public.ensureMilestones(ctx, owner, repo.Slug, issues)
+	if err != nil {
+		return summary, fmt.Errorf("Milestones could not be created: %w", err)
+	}
+
+	for _, issue := range issues {
+		targetIssue, exists := migrated[issue.ID]
+		if exists {
+			summary.Existing++
+		} else {
+			targetIssue, err = public.createIssue(ctx, owner, repo.Slug, issue, milestones)
+			if err != nil {
+				summary.Failed++
+				public.log.WithFields(logrus.Fields{
+					"repository": repo.Slug,
+					"issue":      issue.ID,
+					"error":      err.Error(),
+				}).Warningf("Issue could not be migrated")
+				continue
+			}
+			summary.Migrated++
+		}
+
+		failedComments, err := public.appendComments(ctx, owner, repo.Slug, targetIssue.GetNumber(), issue.Comments, exists)
+		if err != nil {
+			summary.Failed += failedComments
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"issue":      issue.ID,
+				"error":      err.Error(),
+			}).Warningf("Issue comments could not be migrated")
+		}
+
+		if targetIssue.GetState() != issue.State {
+			_, _, err = public.api.Issues.Edit(ctx, owner, repo.Slug, targetIssue.GetNumber(), &github.IssueRequest{State: &issue.State})
+			if err != nil {
+				public.log.WithFields(logrus.Fields{
+					"repository": repo.Slug,
+					"issue":      issue.ID,
+					"error":      err.Error(),
+				}).Warningf("Issue state could not be updated")
+			}
+		}
+	}
+
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%v issues or issue comments not migrated", summary.Failed)
+	}
+	return summary, nil
+}
+
+// createIssue opens the issue on the target with its labels and milestone
+func (public Public) createIssue(ctx context.Context, owner, name string, issue common.Issue, milestones map[string]int) (*github.Issue, error) {
+	body := IssueBody(ctx, issue, public.users)
+	labels := []string{}
+	for _, label := range issue.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	request := github.IssueRequest{
+		Title:  &issue.Title,
+		Body:   &body,
+		Labels: &labels,
+	}
+	if issue.Milestone != nil {
+		if number, ok := milestones[issue.Milestone.Title]; ok {
+			request.Milestone = &number
+		}
+	}
+
+	created, _, err := public.api.Issues.Create(ctx, owner, name, &request)
+	return created, err
+}
+
+// appendComments adds the comments of the source missing on the target issue or pull request,
+// giving the number of comments not added. Comments of existing issues and pull requests are
+// told apart by their marker, since people may comment on the target too
+func (public Public) appendComments(ctx context.Context, owner, name string, number int, comments []common.Comment, exists bool) (int, error) {
+	if len(comments) == 0 {
+		return 0, nil
+	}
+
+	migrated := map[string]bool{}
+	if exists {
+		var err error
+		migrated, err = public.migratedComments(ctx, owner, name, number)
+		if err != nil {
+			return len(comments), err
+		}
+	}
+
+	failed := []string{}
+	for _, comment := range comments {
+		if migrated[comment.ID] {
+			continue
+		}
+		body := IssueCommentBody(ctx, comment, public.users)
+		_, _, err := public.api.Issues.CreateComment(ctx, owner, name, number, &github.IssueComment{Body: &body})
+		if err != nil {
+			failed = append(failed, comment.ID)
+		}
+	}
+	if len(failed) > 0 {
+		return len(failed), fmt.Errorf("Comments not migrated: %v", strings.Join(failed, ", "))
+	}
+	return 0, nil
+}
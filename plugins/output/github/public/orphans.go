@@ -0,0 +1,162 @@
+package public
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+)
+
+// Actions taken on mirrors whose source repository is gone
+const (
+	OrphanArchive = "archive"
+	OrphanTopic   = "topic"
+)
+
+const (
+	// defaultManagedTopic marks the repositories gitsink syncs to
+	defaultManagedTopic = "gitsink-mirror"
+	// defaultOrphanTopic marks mirrors whose source is gone
+	defaultOrphanTopic = "gitsink-orphan"
+)
+
+// managed checks if the target repository is a mirror synced by this integration
+func (public Public) managed(targetRepo *github.Repository) bool {
+	if public.orphans.ManagedTopic == "" {
+		return false
+	}
+	return hasTopic(targetRepo, public.orphans.ManagedTopic)
+}
+
+// retired checks if the target repository was archived by gitsink as an orphan, rather than
+// by someone on purpose
+func (public Public) retired(targetRepo *github.Repository) bool {
+	return targetRepo.GetArchived() && public.managed(targetRepo) && hasTopic(targetRepo, public.orphans.Topic)
+}
+
+// hasTopic checks if the target repository has the topic
+func hasTopic(targetRepo *github.Repository, topic string) bool {
+	for _, repoTopic := range targetRepo.Topics {
+		if repoTopic == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// targetRepositories lists every repository of the organization or authenticated user
+func (public Public) targetRepositories(ctx context.Context) ([]*github.Repository, error) {
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	kindType := kindSplit[0]
+	kindKey := kindSplit[1]
+
+	var all []*github.Repository
+	listOptions := github.ListOptions{PerPage: 100}
+	for {
+		var repos []*github.Repository
+		var response *github.Response
+		var err error
+
+		switch kindType {
+		case "org":
+			repos, response, err = public.api.Repositories.ListByOrg(ctx, kindKey, &github.RepositoryListByOrgOptions{
+				Type:        "all",
+				ListOptions: listOptions,
+			})
+		case "user":
+			repos, response, err = public.api.Repositories.List(ctx, "", &github.RepositoryListOptions{
+				Affiliation: "owner",
+				ListOptions: listOptions,
+			})
+		default:
+			return nil, fmt.Errorf("Unsupported kind")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, repos...)
+		if response.NextPage == 0 {
+			return all, nil
+		}
+		listOptions.Page = response.NextPage
+	}
+}
+
+// ReconcileOrphans adds the orphan topic to, and archives if config asks to, the mirrors on the
+// target that no source repository matches anymore. Only repositories with the managed topic are touched, and
+// nothing is done for an empty source, which is more likely a broken filter than a deleted project
+func (public Public) ReconcileOrphans(ctx context.Context, repos []common.Repository) ([]string, error) {
+	if public.orphans.Action == "" {
+		return nil, nil
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("No source repositories, orphans not reconciled")
+	}
+
+	sourceSlugs := map[string]bool{}
+	for _, repo := range repos {
+		sourceSlugs[strings.ToLower(repo.Slug)] = true
+	}
+
+	targetRepos, err := public.targetRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	var failed []string
+	for _, targetRepo := range targetRepos {
+		if !public.managed(targetRepo) || sourceSlugs[strings.ToLower(targetRepo.GetName())] || targetRepo.GetArchived() {
+			continue
+		}
+
+		err := public.retire(ctx, targetRepo)
+		if err != nil {
+			failed = append(failed, targetRepo.GetName())
+			public.log.WithFields(logrus.Fields{
+				"repository": targetRepo.GetName(),
+				"action":     public.orphans.Action,
+				"error":      err.Error(),
+			}).Warningf("Failed to retire orphaned repository")
+			continue
+		}
+		orphans = append(orphans, targetRepo.GetName())
+		public.log.WithFields(logrus.Fields{
+			"repository": targetRepo.GetName(),
+			"action":     public.orphans.Action,
+		}).Infof("Orphaned repository retired")
+	}
+
+	if len(failed) > 0 {
+		return orphans, fmt.Errorf("Orphans not retired: %v", strings.Join(failed, ", "))
+	}
+	return orphans, nil
+}
+
+// retire adds the orphan topic to the orphaned mirror and archives it if config asks to
+// The topic is added first, archived repositories cannot change topics, and marks the mirrors
+// gitsink archived so only those are unarchived when their source comes back
+func (public Public) retire(ctx context.Context, targetRepo *github.Repository) error {
+	owner := targetRepo.GetOwner().GetLogin()
+	name := targetRepo.GetName()
+
+	if !hasTopic(targetRepo, public.orphans.Topic) {
+		topics := Topics(append(append([]string{}, targetRepo.Topics...), public.orphans.Topic))
+		_, _, err := public.api.Repositories.ReplaceAllTopics(ctx, owner, name, topics)
+		if err != nil {
+			return err
+		}
+	}
+
+	if public.orphans.Action == OrphanArchive {
+		archived := true
+		_, _, err := public.api.Repositories.Edit(ctx, owner, name, &github.Repository{Name: &name, Archived: &archived})
+		return err
+	}
+	return nil
+}
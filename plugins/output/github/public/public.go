@@ -3,8 +3,12 @@ package public
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
 	github "github.com/google/go-github/v31/github"
@@ -14,35 +18,54 @@ import (
 
 	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
+	utils "github.com/parinithshekar/gitsink/common/utils"
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
 	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
 )
 
 var (
-	log = logger.New()
+	log = logger.Root()
 )
 
 // Public struct defines fields in github-public object
 type Public struct {
-	accountID   string
-	accessToken string
-	kind        string
-	api         *github.Client
-	ctx         context.Context
+	accountID       string
+	accessToken     string
+	kind            string
+	visibility      string
+	branchModifiers []config.BranchModifier
+	orphans         config.Orphans
+	users           plugins.UserMapper
+	api             *github.Client
+	log             pkg.Logger
 }
 
+var (
+	// topicInvalidChars matches characters GitHub does not allow in topics
+	topicInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// maxTopicLength is the longest topic GitHub accepts
+const maxTopicLength = 50
+
 // setAPIClient adds a usable API client to the initiated struct
 func (public *Public) setAPIClient() {
 	ctx := context.Background()
 
 	accessToken := os.Getenv(public.accessToken)
 
+	// The API requests go through the oauth2 client, counted by the metrics transport
+	httpClient := &http.Client{Transport: metrics.Transport("output/github-public", nil)}
+
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: accessToken},
 	)
-	tc := oauth2.NewClient(ctx, ts)
+	tc := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, httpClient), ts)
 
 	public.api = github.NewClient(tc)
-	public.ctx = ctx
 }
 
 // Credentials fetches amd returns the accountID and accessToken from environment variables
@@ -52,7 +75,7 @@ func (public Public) Credentials() (string, string, error) {
 
 	accountID, exists := os.LookupEnv(public.accountID)
 	if !exists {
-		log.WithFields(logrus.Fields{
+		public.log.WithFields(logrus.Fields{
 			"accountID": public.accountID,
 		}).Errorf("Account ID not found")
 		return "", "", fmt.Errorf("Account ID not found")
@@ -60,7 +83,7 @@ func (public Public) Credentials() (string, string, error) {
 
 	accessToken, exists = os.LookupEnv(public.accessToken)
 	if !exists {
-		log.WithFields(logrus.Fields{
+		public.log.WithFields(logrus.Fields{
 			"accessToken": public.accessToken,
 		}).Errorf("Access Token not found")
 		return "", "", fmt.Errorf("Access Token not found")
@@ -69,9 +92,20 @@ func (public Public) Credentials() (string, string, error) {
 	return accountID, accessToken, nil
 }
 
+// SetLogger has the plugin log through the logger of the run it is used in
+func (public *Public) SetLogger(log pkg.Logger) {
+	public.log = log
+}
+
+// Capabilities tells what GitHub targets support. Branch protection is not applied yet
+func (public *Public) Capabilities() v2.Capabilities {
+	return v2.Capabilities{LFS: true, PullRequests: true, Issues: true, Releases: true, Wiki: true}
+}
+
 // New returns a new github-public object
 func New(target config.Target) (*Public, error) {
 	var public *Public = new(Public)
+	public.log = log
 
 	// Check if env variables mentioned in config file exist
 	// check account ID env variable
@@ -95,12 +129,53 @@ func New(target config.Target) (*Public, error) {
 
 	public.kind = target.Kind
 
+	// Repositories are private unless config asks for public or mirroring the source
+	switch target.Visibility {
+	case "", "private":
+		public.visibility = "private"
+	case "public", "source":
+		public.visibility = target.Visibility
+	default:
+		log.WithFields(logrus.Fields{
+			"visibility": target.Visibility,
+		}).Errorf("Unsupported visibility")
+		return nil, fmt.Errorf("Unsupported visibility")
+	}
+
+	public.branchModifiers = target.BranchModifiers
+
+	// Orphaned mirrors are left alone unless config gives an action
+	public.orphans = target.Orphans
+	switch target.Orphans.Action {
+	case "":
+	case OrphanArchive, OrphanTopic:
+		if public.orphans.Topic == "" {
+			public.orphans.Topic = defaultOrphanTopic
+		}
+		if public.orphans.ManagedTopic == "" {
+			public.orphans.ManagedTopic = defaultManagedTopic
+		}
+	default:
+		log.WithFields(logrus.Fields{
+			"action": target.Orphans.Action,
+		}).Errorf("Unsupported orphan action")
+		return nil, fmt.Errorf("Unsupported orphan action")
+	}
+
 	public.setAPIClient()
 	return public, nil
 }
 
 // Authenticate checks the account ID and access tokens' validity for the kind defined
+// The requests are not stopped early, V2 gives the method taking a context
 func (public Public) Authenticate() (bool, error) {
+	err := public.authenticate(context.Background())
+	return err == nil, err
+}
+
+// authenticate checks that the credentials can create repositories of the kind, with the
+// requests stopping when ctx is done
+func (public Public) authenticate(ctx context.Context) error {
 
 	kindSplit := strings.SplitN(public.kind, "/", 2)
 	kindType := kindSplit[0]
@@ -112,63 +187,82 @@ func (public Public) Authenticate() (bool, error) {
 	case "org":
 		// returns true only if the user is a member of the org, can create repositories
 		// returns Membership, Response, error
-		_, _, err := public.api.Organizations.GetOrgMembership(public.ctx, accountID, kindKey)
+		_, response, err := public.api.Organizations.GetOrgMembership(ctx, accountID, kindKey)
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			public.log.WithFields(logrus.Fields{
 				"organization": kindKey,
 			}).Errorf("Organization membership check failed")
-			return false, err
+			return apiError("authenticate target", "", response, err)
 		}
-		return true, nil
+		return nil
 
 	case "user":
 		// return true if the authenticated user from env variables is the same user mentioned in config
-		user, _, err := public.api.Users.Get(context.Background(), "")
+		user, response, err := public.api.Users.Get(ctx, "")
 		if err != nil {
-			return false, err
+			return apiError("authenticate target", "", response, err)
 		} else if kindKey != *user.Login {
-			log.WithFields(logrus.Fields{
+			public.log.WithFields(logrus.Fields{
 				"user": kindKey,
 			}).Errorf("Kind username does not match account ID")
-			return false, fmt.Errorf("Unable to push to target user")
+			return fmt.Errorf("Unable to push to target user")
 		}
-		return true, nil
+		return nil
 
 	default:
 		// Mentioned kind is unsupported
-		log.WithFields(logrus.Fields{
+		public.log.WithFields(logrus.Fields{
 			"kind": kindType,
 		}).Errorf("Unsupported kind")
-		return false, fmt.Errorf("Unsupported kind")
+		return fmt.Errorf("Unsupported kind")
 	}
 }
 
-func (public Public) makeNewRepo(repo common.Repository) (string, error) {
+// makeNewRepo creates the target repository with the metadata of the source and gives its clone URL
+func (public Public) makeNewRepo(ctx context.Context, repo common.Repository) (string, error) {
 
 	kindSplit := strings.SplitN(public.kind, "/", 2)
 	kindType := kindSplit[0]
 	kindKey := kindSplit[1]
 
+	private := public.private(repo)
 	newRepository := github.Repository{
 		Name:        &repo.Slug,
 		Description: &repo.Description,
+		Private:     &private,
+	}
+	if repo.Homepage != "" {
+		newRepository.Homepage = &repo.Homepage
 	}
 
 	// Make new repo
 	var err error
 	var newRepo *github.Repository
+	var response *github.Response
 	switch kindType {
 	case "org":
-		newRepo, _, err = public.api.Repositories.Create(context.Background(), kindKey, &newRepository)
+		newRepo, response, err = public.api.Repositories.Create(ctx, kindKey, &newRepository)
 
 	case "user":
-		newRepo, _, err = public.api.Repositories.Create(context.Background(), "", &newRepository)
+		newRepo, response, err = public.api.Repositories.Create(ctx, "", &newRepository)
 	}
 	if err != nil {
-		log.WithFields(logrus.Fields{
+		public.log.WithFields(logrus.Fields{
 			"repository": repo.Slug,
+			"error":      err.Error(),
 		}).Errorf("Repository creation failed")
-		return "", fmt.Errorf("Repository creation failed")
+		return "", apiError("create target", repo.Slug, response, err)
+	}
+
+	// Topics can only be set once the repository exists
+	if repo.Topics != nil || public.orphans.ManagedTopic != "" {
+		err = public.reconcileTopics(ctx, repo, newRepo)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"error":      err.Error(),
+			}).Warningf("Failed to set topics")
+		}
 	}
 
 	newRepoBytes, _ := json.MarshalIndent(newRepo, "", "  ")
@@ -179,39 +273,297 @@ func (public Public) makeNewRepo(repo common.Repository) (string, error) {
 
 // SyncCheck checks whether the repository is already present at the target
 // If it is, then only a sync is done, else a new repository is created at the target
+// The requests are not stopped early, V2 gives CreateTarget taking a context
 func (public Public) SyncCheck(repos []common.Repository) []common.Repository {
 
+	var processedRepos []common.Repository
+
+	for _, repo := range repos {
+		repo, err := public.CreateTarget(context.Background(), repo)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"error":      err.Error(),
+			}).Warningf("Skipping repository")
+			continue
+		}
+		processedRepos = append(processedRepos, repo)
+	}
+	return processedRepos
+}
+
+// CreateTarget creates the target repository of the source repository, or brings the metadata
+// of the existing one in line with the source, and gives the repository with its target URL
+func (public Public) CreateTarget(ctx context.Context, repo common.Repository) (common.Repository, error) {
+
 	kindSplit := strings.SplitN(public.kind, "/", 2)
-	// kindType := kindSplit[0]
 	kindKey := kindSplit[1]
 
-	var processedRepos []common.Repository
+	targetRepo, response, err := public.api.Repositories.Get(ctx, kindKey, repo.Slug)
+	switch {
+	case err != nil && response != nil && response.StatusCode == http.StatusNotFound:
+		public.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+		}).Infof("Repository not found")
+		targetURL, err := public.makeNewRepo(ctx, repo)
+		if err != nil {
+			return repo, err
+		}
+		repo.Target = targetURL
 
+	case err != nil:
+		return repo, apiError("look up target", repo.Slug, response, err)
+
+	default:
+		// Bring the existing repository's metadata in line with the source
+		public.reconcile(ctx, repo, targetRepo)
+
+		targetRepoBytes, _ := json.MarshalIndent(targetRepo, "", "  ")
+		targetRepoJSON := string(targetRepoBytes)
+		repo.Target = gjson.Get(targetRepoJSON, `clone_url`).String()
+	}
+	return repo, nil
+}
+
+// apiError classifies a failed API request by the status of its response
+func apiError(op, repository string, response *github.Response, err error) error {
+	kind := v2.ErrFailed
+	if response != nil {
+		kind = v2.StatusKind(response.StatusCode)
+	}
+	return v2.Wrap(op, repository, kind, err)
+}
+
+// FindTargets looks up the target repositories without creating or changing them
+// Repositories missing on the target are returned without a target URL
+func (public Public) FindTargets(ctx context.Context, repos []common.Repository) ([]common.Repository, error) {
+	var found []common.Repository
 	for _, repo := range repos {
+		targetRepo, err := public.lookupTarget(ctx, repo)
+		switch {
+		case errors.Is(err, v2.ErrNotFound):
+			repo.Target = ""
+		case err != nil:
+			return nil, err
+		default:
+			repo = targetRepo
+		}
+		found = append(found, repo)
+	}
+	return found, nil
+}
+
+// lookupTarget gives the repository with the clone URL of its target repository, with the
+// request stopping when ctx is done. A missing target repository is an ErrNotFound
+func (public Public) lookupTarget(ctx context.Context, repo common.Repository) (common.Repository, error) {
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	kindKey := kindSplit[1]
+
+	targetRepo, response, err := public.api.Repositories.Get(ctx, kindKey, repo.Slug)
+	if err != nil {
+		return repo, apiError("look up target", repo.Slug, response, err)
+	}
+	repo.Target = targetRepo.GetCloneURL()
+	return repo, nil
+}
 
-		targetRepo, _, err := public.api.Repositories.Get(context.Background(), kindKey, repo.Slug)
+// private decides the visibility of the target repository from config and the source
+func (public Public) private(repo common.Repository) bool {
+	switch public.visibility {
+	case "public":
+		return false
+	case "source":
+		return repo.Private
+	default:
+		return true
+	}
+}
 
+// reconcile updates the description, homepage, visibility and topics of the target
+// repository where they differ from the source
+// The default branch is set by SetDefaultBranch once the branches are pushed
+func (public Public) reconcile(ctx context.Context, repo common.Repository, targetRepo *github.Repository) {
+	owner := targetRepo.GetOwner().GetLogin()
+	name := targetRepo.GetName()
+
+	// Mirrors archived as orphans are brought back when their source reappears, ones
+	// archived by people are left as they are
+	if public.retired(targetRepo) {
+		archived := false
+		_, _, err := public.api.Repositories.Edit(ctx, owner, name, &github.Repository{Name: &name, Archived: &archived})
 		if err != nil {
-			log.WithFields(logrus.Fields{
+			public.log.WithFields(logrus.Fields{
 				"repository": repo.Slug,
-			}).Infof("Repository not found")
-			targetURL, err := public.makeNewRepo(repo)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"repository": repo.Slug,
-				}).Warningf("Skipping repository")
-				continue
-			} else {
-				repo.Target = targetURL
-			}
+				"error":      err.Error(),
+			}).Warningf("Failed to unarchive repository")
+			return
+		}
+		public.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+		}).Infof("Repository unarchived, source is back")
+	}
+
+	edit := github.Repository{Name: &name}
+	var changed []string
+
+	// Sources without a description leave the one set on the target
+	if repo.Description != "" && targetRepo.GetDescription() != repo.Description {
+		edit.Description = &repo.Description
+		changed = append(changed, "description")
+	}
+	if repo.Homepage != "" && targetRepo.GetHomepage() != repo.Homepage {
+		edit.Homepage = &repo.Homepage
+		changed = append(changed, "homepage")
+	}
+	// Existing targets get the visibility of config on every sync, private by default
+	private := public.private(repo)
+	if targetRepo.GetPrivate() != private {
+		edit.Private = &private
+		changed = append(changed, "private")
+	}
+
+	if len(changed) > 0 {
+		_, _, err := public.api.Repositories.Edit(ctx, owner, name, &edit)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"fields":     changed,
+				"error":      err.Error(),
+			}).Warningf("Failed to update repository metadata")
 		} else {
-			targetRepoBytes, _ := json.MarshalIndent(targetRepo, "", "  ")
-			targetRepoJSON := string(targetRepoBytes)
-			repo.Target = gjson.Get(targetRepoJSON, `clone_url`).String()
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"fields":     changed,
+			}).Infof("Repository metadata updated")
 		}
+	}
 
-		processedRepos = append(processedRepos, repo)
+	if repo.Topics != nil || public.orphans.ManagedTopic != "" {
+		err := public.reconcileTopics(ctx, repo, targetRepo)
+		if err != nil {
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"error":      err.Error(),
+			}).Warningf("Failed to update topics")
+		}
+	}
+}
 
+// reconcileTopics replaces the target topics when they differ from the source labels
+// Mirrors get the managed topic, and lose the orphan topic since their source is back
+func (public Public) reconcileTopics(ctx context.Context, repo common.Repository, targetRepo *github.Repository) error {
+	current := append([]string{}, targetRepo.Topics...)
+	sort.Strings(current)
+
+	// Sources without labels leave the target topics as they are
+	labels := repo.Topics
+	if labels == nil {
+		labels = current
 	}
-	return processedRepos
+	if public.orphans.ManagedTopic != "" {
+		labels = append([]string{public.orphans.ManagedTopic}, labels...)
+	}
+	var kept []string
+	for _, label := range labels {
+		if public.orphans.Action == "" || label != public.orphans.Topic {
+			kept = append(kept, label)
+		}
+	}
+	topics := Topics(kept)
+	if strings.Join(topics, ",") == strings.Join(current, ",") {
+		return nil
+	}
+
+	_, _, err := public.api.Repositories.ReplaceAllTopics(ctx, targetRepo.GetOwner().GetLogin(), targetRepo.GetName(), topics)
+	return err
+}
+
+// Topics converts source labels to a sorted list of valid GitHub topics
+// Topics are lowercase letters, numbers and hyphens, so other characters become hyphens
+func Topics(labels []string) []string {
+	topics := []string{}
+	seen := map[string]bool{}
+
+	for _, label := range labels {
+		topic := topicInvalidChars.ReplaceAllString(strings.ToLower(label), "-")
+		topic = strings.Trim(topic, "-")
+		if len(topic) > maxTopicLength {
+			topic = strings.Trim(topic[:maxTopicLength], "-")
+		}
+		if topic == "" || seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// TargetBranch gives the target name of a source branch after the branch modifiers are applied
+// Returns false if the branch is not synced
+func (public Public) TargetBranch(branch string) (string, bool) {
+	return utils.ModifyBranch(branch, public.branchModifiers)
+}
+
+// SetDefaultBranch makes the branch the default of the target repository if it is not already
+// The requests are not stopped early, V2 gives the method taking a context
+func (public Public) SetDefaultBranch(repo common.Repository, branch string) error {
+	return public.setDefaultBranch(context.Background(), repo, branch)
+}
+
+// setDefaultBranch changes the default branch of the target repository, with the requests
+// stopping when ctx is done
+func (public Public) setDefaultBranch(ctx context.Context, repo common.Repository, branch string) error {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	kindKey := kindSplit[1]
+
+	targetRepo, response, err := public.api.Repositories.Get(ctx, kindKey, repo.Slug)
+	if err != nil {
+		return apiError("set default branch", repo.Slug, response, err)
+	}
+	if targetRepo.GetDefaultBranch() == branch {
+		return nil
+	}
+
+	edit := github.Repository{Name: targetRepo.Name, DefaultBranch: &branch}
+	_, response, err = public.api.Repositories.Edit(ctx, targetRepo.GetOwner().GetLogin(), targetRepo.GetName(), &edit)
+	if err != nil {
+		return apiError("set default branch", repo.Slug, response, err)
+	}
+
+	public.log.WithFields(logrus.Fields{
+		"repository":    repo.Slug,
+		"previous":      targetRepo.GetDefaultBranch(),
+		"defaultBranch": branch,
+	}).Infof("Default branch updated")
+	return nil
+}
+
+// EnableWiki turns on the wiki of the target repository if it is off and returns the wiki clone URL
+// GitHub only creates the wiki's git repository once its first page is saved on the web
+func (public Public) EnableWiki(ctx context.Context, repo common.Repository) (string, error) {
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	kindKey := kindSplit[1]
+
+	targetRepo, _, err := public.api.Repositories.Get(ctx, kindKey, repo.Slug)
+	if err != nil {
+		return "", err
+	}
+
+	if !targetRepo.GetHasWiki() {
+		hasWiki := true
+		edit := github.Repository{Name: targetRepo.Name, HasWiki: &hasWiki}
+		_, _, err = public.api.Repositories.Edit(ctx, targetRepo.GetOwner().GetLogin(), targetRepo.GetName(), &edit)
+		if err != nil {
+			return "", err
+		}
+		public.log.WithFields(logrus.Fields{
+			"repository": repo.Slug,
+		}).Infof("Wiki enabled")
+	}
+
+	return strings.TrimSuffix(targetRepo.GetCloneURL(), ".git") + ".wiki.git", nil
 }
@@ -1,10 +1,15 @@
 package public_test
 
 import (
+	"context"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	common "github.com/parinithshekar/gitsink/common"
 	config "github.com/parinithshekar/gitsink/common/config"
+	users "github.com/parinithshekar/gitsink/common/users"
 	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
 	ghpublic "github.com/parinithshekar/gitsink/plugins/output/github/public"
 )
@@ -111,3 +116,267 @@ func TestCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestVisibility(t *testing.T) {
+	cases := map[string]struct {
+		Visibility    string
+		ExpectedError bool
+	}{
+		"Default visibility": {"", false},
+		"Private":            {"private", false},
+		"Public":             {"public", false},
+		"Mirror source":      {"source", false},
+		"Unsupported":        {"internal", true},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		tcTarget := target
+		tcTarget.Visibility = tc.Visibility
+
+		_, err := ghpublic.New(tcTarget)
+
+		actualError := (err != nil)
+		if actualError != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, tc.ExpectedError, actualError)
+		}
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	cases := map[string]struct {
+		Action        string
+		ExpectedError bool
+	}{
+		"Off":         {"", false},
+		"Archive":     {ghpublic.OrphanArchive, false},
+		"Topic":       {ghpublic.OrphanTopic, false},
+		"Unsupported": {"delete", true},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		tcTarget := target
+		tcTarget.Orphans.Action = tc.Action
+
+		output, err := ghpublic.New(tcTarget)
+
+		actualError := (err != nil)
+		if actualError != tc.ExpectedError {
+			t.Errorf("%v - Expected error: %v | Actual Error: %v", tcName, tc.ExpectedError, actualError)
+			continue
+		}
+		if actualError {
+			continue
+		}
+
+		// Nothing is retired when the source has no repositories
+		_, err = output.ReconcileOrphans(context.Background(), nil)
+		if (err != nil) != (tc.Action != "") {
+			t.Errorf("%v - Expected empty source error: %v | Actual: %v", tcName, tc.Action != "", err)
+		}
+	}
+}
+
+func TestTopics(t *testing.T) {
+	cases := map[string]struct {
+		Labels, ExpectedTopics []string
+	}{
+		"No labels":        {[]string{}, []string{}},
+		"Valid labels":     {[]string{"python", "utility"}, []string{"python", "utility"}},
+		"Sorted":           {[]string{"zeta", "alpha"}, []string{"alpha", "zeta"}},
+		"Invalid chars":    {[]string{"Team Alpha", "C++", "_internal_"}, []string{"c", "internal", "team-alpha"}},
+		"Duplicates":       {[]string{"Go", "go", "GO!"}, []string{"go"}},
+		"Only punctuation": {[]string{"!!!", "---"}, []string{}},
+		"Too long":         {[]string{strings.Repeat("a", 49) + "-b"}, []string{strings.Repeat("a", 49)}},
+	}
+
+	for tcName, tc := range cases {
+		actualTopics := ghpublic.Topics(tc.Labels)
+		if strings.Join(actualTopics, ",") != strings.Join(tc.ExpectedTopics, ",") {
+			t.Errorf("%v - Expected topics: %v | Actual topics: %v", tcName, tc.ExpectedTopics, actualTopics)
+		}
+	}
+}
+
+func TestTargetBranch(t *testing.T) {
+	modifiers := []config.BranchModifier{
+		{Name: "rename-branch", Match: "featureX", Rename: "featureY"},
+		{Name: "master-branch", Match: "master", Prefix: "bb-"},
+		{Name: "release-branches", Match: "/^release/.*$/", Prefix: "bb-"},
+	}
+
+	cases := map[string]struct {
+		Modifiers              []config.BranchModifier
+		Branch, ExpectedBranch string
+		ExpectedSynced         bool
+	}{
+		"No modifiers":        {nil, "develop", "develop", true},
+		"Prefixed branch":     {modifiers, "master", "bb-master", true},
+		"Renamed branch":      {modifiers, "featureX", "featureY", true},
+		"Regex match":         {modifiers, "release/1.0", "bb-release/1.0", true},
+		"Unmatched branch":    {modifiers, "develop", "", false},
+		"Partial plain match": {modifiers, "master-old", "", false},
+	}
+
+	os.Setenv(envAccountID, "username")
+	os.Setenv(envAccessToken, "token")
+	defer os.Unsetenv(envAccountID)
+	defer os.Unsetenv(envAccessToken)
+
+	for tcName, tc := range cases {
+		tcTarget := target
+		tcTarget.BranchModifiers = tc.Modifiers
+
+		output, err := ghpublic.New(tcTarget)
+		if err != nil {
+			t.Fatal("Plugin initiation failed")
+		}
+
+		actualBranch, actualSynced := output.TargetBranch(tc.Branch)
+		if actualBranch != tc.ExpectedBranch || actualSynced != tc.ExpectedSynced {
+			t.Errorf("%v - Expected: %v, %v | Actual: %v, %v", tcName, tc.ExpectedBranch, tc.ExpectedSynced, actualBranch, actualSynced)
+		}
+	}
+}
+
+func TestPullRequestBody(t *testing.T) {
+	created := time.Date(2020, 5, 2, 9, 30, 0, 0, time.UTC)
+	alice := common.User{Name: "alice", DisplayName: "Alice"}
+	bob := common.User{Name: "bob", DisplayName: "bob"}
+
+	cases := map[string]struct {
+		PullRequest      common.PullRequest
+		Mapped           bool
+		ExpectedContains []string
+		ExpectedMissing  []string
+	}{
+		"Open pull request": {
+			common.PullRequest{ID: 7, State: common.PullRequestOpen, URL: "https://bitbucket.org/ws/repo/pull-requests/7", Author: alice,
+				Reviewers: []common.User{bob}, SourceBranch: "feature", TargetBranch: "main", Description: "Adds a feature", Created: created},
+			false,
+			[]string{"Migrated from https://bitbucket.org/ws/repo/pull-requests/7", "Alice (`alice`)", "2020-05-02 09:30 UTC", "Reviewers: `bob`", "Adds a feature", "<!-- gitsink:pull-request:7 -->"},
+			[]string{"State on source"},
+		},
+		"Merged pull request": {
+			common.PullRequest{ID: 8, State: common.PullRequestMerged, Author: alice, Created: created},
+			false,
+			[]string{"Migrated pull request", "State on source: merged", "<!-- gitsink:pull-request:8 -->"},
+			[]string{"Reviewers"},
+		},
+		"Mapped users": {
+			common.PullRequest{ID: 9, State: common.PullRequestOpen, Author: alice, Reviewers: []common.User{bob}, Created: created},
+			true,
+			[]string{"opened by @alice-gh", "Reviewers: `bob`"},
+			[]string{"Alice (`alice`)"},
+		},
+	}
+
+	mapper, _ := users.New("")
+	mapper.Add("alice", "alice-gh")
+
+	for tcName, tc := range cases {
+		var userMapper plugins.UserMapper
+		if tc.Mapped {
+			userMapper = mapper
+		}
+		body := ghpublic.PullRequestBody(context.Background(), tc.PullRequest, userMapper)
+		for _, expected := range tc.ExpectedContains {
+			if !strings.Contains(body, expected) {
+				t.Errorf("%v - Expected body to contain %q | Actual: %v", tcName, expected, body)
+			}
+		}
+		for _, missing := range tc.ExpectedMissing {
+			if strings.Contains(body, missing) {
+				t.Errorf("%v - Expected body without %q | Actual: %v", tcName, missing, body)
+			}
+		}
+	}
+}
+
+func TestCommentBody(t *testing.T) {
+	created := time.Date(2020, 5, 2, 9, 30, 0, 0, time.UTC)
+	bob := common.User{Name: "bob", DisplayName: "Bob"}
+
+	cases := map[string]struct {
+		Comment      common.Comment
+		ExpectedBody string
+	}{
+		"General comment": {common.Comment{Author: bob, Body: "Looks good", Created: created}, "> Bob (`bob`) commented on 2020-05-02 09:30 UTC\n\nLooks good"},
+		"Inline comment":  {common.Comment{Author: bob, Body: "Typo", Created: created, Path: "main.go", Line: 3}, "> Bob (`bob`) commented on 2020-05-02 09:30 UTC on `main.go` line 3\n\nTypo"},
+		"File comment":    {common.Comment{Author: bob, Body: "Split this", Created: created, Path: "main.go"}, "> Bob (`bob`) commented on 2020-05-02 09:30 UTC on `main.go`\n\nSplit this"},
+	}
+
+	for tcName, tc := range cases {
+		if actualBody := ghpublic.CommentBody(context.Background(), tc.Comment, nil); actualBody != tc.ExpectedBody {
+			t.Errorf("%v - Expected body: %q | Actual body: %q", tcName, tc.ExpectedBody, actualBody)
+		}
+	}
+}
+
+func TestIssueBody(t *testing.T) {
+	created := time.Date(2020, 5, 2, 9, 30, 0, 0, time.UTC)
+	alice := common.User{Name: "alice", DisplayName: "Alice"}
+
+	cases := map[string]struct {
+		Issue        common.Issue
+		ExpectedBody string
+	}{
+		"Issue with description": {
+			common.Issue{ID: 3, URL: "https://gitlab.com/team/app/-/issues/3", Author: alice, Description: "Crashes on start", Created: created},
+			"> Migrated from https://gitlab.com/team/app/-/issues/3, opened by Alice (`alice`) on 2020-05-02 09:30 UTC\n\nCrashes on start\n\n<!-- gitsink:issue:3 -->\n",
+		},
+		"Issue without URL or description": {
+			common.Issue{ID: 4, Author: alice, Created: created},
+			"> Migrated issue, opened by Alice (`alice`) on 2020-05-02 09:30 UTC\n\n<!-- gitsink:issue:4 -->\n",
+		},
+	}
+
+	for tcName, tc := range cases {
+		if actualBody := ghpublic.IssueBody(context.Background(), tc.Issue, nil); actualBody != tc.ExpectedBody {
+			t.Errorf("%v - Expected body: %q | Actual body: %q", tcName, tc.ExpectedBody, actualBody)
+		}
+	}
+}
+
+func TestIssueCommentBody(t *testing.T) {
+	created := time.Date(2020, 5, 2, 9, 30, 0, 0, time.UTC)
+	comment := common.Comment{ID: "101", Author: common.User{Name: "bob"}, Body: "Fixed in main", Created: created}
+
+	expectedBody := "> `bob` commented on 2020-05-02 09:30 UTC\n\nFixed in main\n\n<!-- gitsink:comment:101 -->"
+	if actualBody := ghpublic.IssueCommentBody(context.Background(), comment, nil); actualBody != expectedBody {
+		t.Errorf("Expected body: %q | Actual body: %q", expectedBody, actualBody)
+	}
+}
+
+func TestReleaseBody(t *testing.T) {
+	created := time.Date(2020, 5, 2, 9, 30, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		Release      common.Release
+		ExpectedBody string
+	}{
+		"Source release": {
+			common.Release{Tag: "v1.0.0", URL: "https://gitlab.com/team/app/-/releases/v1.0.0", Author: common.User{Name: "alice", DisplayName: "Alice"}, Notes: "First release", Created: created},
+			"> Migrated from https://gitlab.com/team/app/-/releases/v1.0.0, published by Alice (`alice`) on 2020-05-02 09:30 UTC\n\nFirst release\n",
+		},
+		"Release without author or notes": {
+			common.Release{Tag: "v1.0.1", Created: created},
+			"> Migrated release, published on 2020-05-02 09:30 UTC\n",
+		},
+	}
+
+	for tcName, tc := range cases {
+		if actualBody := ghpublic.ReleaseBody(context.Background(), tc.Release, nil); actualBody != tc.ExpectedBody {
+			t.Errorf("%v - Expected body: %q | Actual body: %q", tcName, tc.ExpectedBody, actualBody)
+		}
+	}
+}
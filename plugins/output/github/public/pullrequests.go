@@ -0,0 +1,258 @@
+package public
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+const (
+	// pullRequestMarker tags a migrated pull request with its source ID so later runs only add its new comments
+	pullRequestMarker = "<!-- gitsink:pull-request:%v -->"
+	// dateFormat is how source dates are shown in attribution text
+	dateFormat = "2006-01-02 15:04 UTC"
+)
+
+var (
+	// pullRequestMarkerPattern finds the source ID in the body of a migrated pull request
+	pullRequestMarkerPattern = regexp.MustCompile(`<!-- gitsink:pull-request:(\d+) -->`)
+)
+
+// userText shows a source user as an @mention if they are mapped to a target login,
+// else by their source name for attribution. Lookups of the mapping stop when ctx is done
+func userText(ctx context.Context, user common.User, users plugins.UserMapper) string {
+	if users != nil {
+		if login, ok := users.TargetUser(ctx, user); ok {
+			return "@" + login
+		}
+	}
+	if user.DisplayName == "" || user.DisplayName == user.Name {
+		return fmt.Sprintf("`%v`", user.Name)
+	}
+	return fmt.Sprintf("%v (`%v`)", user.DisplayName, user.Name)
+}
+
+// PullRequestBody gives the body of the target pull request, with the author, dates,
+// state and reviewers from the source above the description
+func PullRequestBody(ctx context.Context, pullRequest common.PullRequest, users plugins.UserMapper) string {
+	var body strings.Builder
+
+	origin := "Migrated pull request"
+	if pullRequest.URL != "" {
+		origin = fmt.Sprintf("Migrated from %v", pullRequest.URL)
+	}
+	fmt.Fprintf(&body, "> %v, opened by %v on %v\n", origin, userText(ctx, pullRequest.Author, users), pullRequest.Created.Format(dateFormat))
+	if pullRequest.State != common.PullRequestOpen {
+		fmt.Fprintf(&body, "> State on source: %v\n", pullRequest.State)
+	}
+	if len(pullRequest.Reviewers) > 0 {
+		reviewers := []string{}
+		for _, reviewer := range pullRequest.Reviewers {
+			reviewers = append(reviewers, userText(ctx, reviewer, users))
+		}
+		fmt.Fprintf(&body, "> Reviewers: %v\n", strings.Join(reviewers, ", "))
+	}
+	if pullRequest.SourceBranch != "" {
+		fmt.Fprintf(&body, "> Branches: `%v` into `%v`\n", pullRequest.SourceBranch, pullRequest.TargetBranch)
+	}
+
+	if pullRequest.Description != "" {
+		fmt.Fprintf(&body, "\n%v\n", pullRequest.Description)
+	}
+	fmt.Fprintf(&body, "\n"+pullRequestMarker+"\n", pullRequest.ID)
+	return body.String()
+}
+
+// CommentBody gives the body of a target comment, with the author, date and anchor from the source
+func CommentBody(ctx context.Context, comment common.Comment, users plugins.UserMapper) string {
+	anchor := ""
+	if comment.Path != "" {
+		anchor = fmt.Sprintf(" on `%v`", comment.Path)
+		if comment.Line > 0 {
+			anchor = fmt.Sprintf(" on `%v` line %v", comment.Path, comment.Line)
+		}
+	}
+	return fmt.Sprintf("> %v commented on %v%v\n\n%v", userText(ctx, comment.Author, users), comment.Created.Format(dateFormat), anchor, comment.Body)
+}
+
+// migrated finds the issues and pull requests on the target that have a marker, by the
+// source ID in the marker. The issues API lists pull requests too, so this finds pull
+// requests migrated as issues
+func (public Public) migrated(ctx context.Context, owner, name string, marker *regexp.Regexp) (map[int64]*github.Issue, error) {
+	migrated := map[int64]*github.Issue{}
+
+	opt := &github.IssueListByRepoOptions{
+		State:       "all",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, response, err := public.api.Issues.ListByRepo(ctx, owner, name, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			match := marker.FindStringSubmatch(issue.GetBody())
+			if match != nil {
+				id, _ := strconv.ParseInt(match[1], 10, 64)
+				migrated[id] = issue
+			}
+		}
+		if response.NextPage == 0 {
+			return migrated, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+// MigratePullRequests recreates the pull requests on the target repository with their comments,
+// and adds the comments missing on the pull requests migrated by earlier runs
+// Pull requests that are no longer open are closed after they are created. If their
+// branches are gone they are kept as closed issues, so the review history is not lost
+func (public Public) MigratePullRequests(ctx context.Context, repo common.Repository, pullRequests []common.PullRequest) (common.MigrationSummary, error) {
+	summary := common.MigrationSummary{}
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	owner := kindSplit[1]
+
+	migrated, err := public.migrated(ctx, owner, repo.Slug, pullRequestMarkerPattern)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, pullRequest := range pullRequests {
+		targetIssue, exists := migrated[pullRequest.ID]
+		number := targetIssue.GetNumber()
+		if exists {
+			summary.Existing++
+		} else {
+			number, err = public.createPullRequest(ctx, owner, repo.Slug, pullRequest)
+			if err != nil {
+				summary.Failed++
+				public.log.WithFields(logrus.Fields{
+					"repository":  repo.Slug,
+					"pullRequest": pullRequest.ID,
+					"error":       err.Error(),
+				}).Warningf("Pull request could not be migrated")
+				continue
+			}
+		}
+
+		// Comments made on the source since the last run are added to existing pull requests too
+		failedComments, err := public.appendComments(ctx, owner, repo.Slug, number, pullRequest.Comments, exists)
+		if err != nil {
+			summary.Failed += failedComments
+			public.log.WithFields(logrus.Fields{
+				"repository":  repo.Slug,
+				"pullRequest": pullRequest.ID,
+				"error":       err.Error(),
+			}).Warningf("Pull request comments could not be migrated")
+		}
+
+		if exists {
+			continue
+		}
+		if pullRequest.State != common.PullRequestOpen {
+			closed := "closed"
+			_, _, err = public.api.Issues.Edit(ctx, owner, repo.Slug, number, &github.IssueRequest{State: &closed})
+			if err != nil {
+				public.log.WithFields(logrus.Fields{
+					"repository":  repo.Slug,
+					"pullRequest": pullRequest.ID,
+					"error":       err.Error(),
+				}).Warningf("Migrated pull request could not be closed")
+			}
+		}
+		summary.Migrated++
+	}
+
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%v pull requests or pull request comments not migrated", summary.Failed)
+	}
+	return summary, nil
+}
+
+// createPullRequest opens the pull request on the target, returning its number
+// Closed pull requests whose branches are not on the target become issues instead
+func (public Public) createPullRequest(ctx context.Context, owner, name string, pullRequest common.PullRequest) (int, error) {
+	body := PullRequestBody(ctx, pullRequest, public.users)
+
+	created, _, err := public.api.PullRequests.Create(ctx, owner, name, &github.NewPullRequest{
+		Title: &pullRequest.Title,
+		Head:  &pullRequest.SourceBranch,
+		Base:  &pullRequest.TargetBranch,
+		Body:  &body,
+	})
+	if err == nil {
+		public.requestReviewers(ctx, owner, name, created.GetNumber(), pullRequest)
+		return created.GetNumber(), nil
+	}
+	if pullRequest.State == common.PullRequestOpen {
+		return 0, err
+	}
+
+	issue, _, err := public.api.Issues.Create(ctx, owner, name, &github.IssueRequest{
+		Title: &pullRequest.Title,
+		Body:  &body,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return issue.GetNumber(), nil
+}
+
+// requestReviewers asks the mapped reviewers of an open pull request for a review on the target
+func (public Public) requestReviewers(ctx context.Context, owner, name string, number int, pullRequest common.PullRequest) {
+	if public.users == nil || pullRequest.State != common.PullRequestOpen {
+		return
+	}
+
+	logins := []string{}
+	for _, reviewer := range pullRequest.Reviewers {
+		if login, ok := public.users.TargetUser(ctx, reviewer); ok {
+			logins = append(logins, login)
+		}
+	}
+	if len(logins) == 0 {
+		return
+	}
+
+	_, _, err := public.api.PullRequests.RequestReviewers(ctx, owner, name, number, github.ReviewersRequest{Reviewers: logins})
+	if err != nil {
+		public.log.WithFields(logrus.Fields{
+			"repository":  name,
+			"pullRequest": pullRequest.ID,
+			"reviewers":   logins,
+			"error":       err.Error(),
+		}).Warningf("Failed to request reviewers")
+	}
+}
+
+// SetUserMapper sets how source users are attributed on the target
+func (public *Public) SetUserMapper(users plugins.UserMapper) {
+	public.users = users
+}
+
+// LoginForEmail finds the login of the GitHub user with the email address
+// Only users who made their email address public can be found
+func (public Public) LoginForEmail(ctx context.Context, email string) (string, error) {
+	result, _, err := public.api.Search.Users(ctx, fmt.Sprintf("%v in:email", email), nil)
+	if err != nil {
+		return "", err
+	}
+	switch result.GetTotal() {
+	case 0:
+		return "", fmt.Errorf("No user with the email address")
+	case 1:
+		return result.Users[0].GetLogin(), nil
+	default:
+		return "", fmt.Errorf("More than one user with the email address")
+	}
+}
@@ -0,0 +1,178 @@
+package public
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	github "github.com/google/go-github/v31/github"
+	logrus "github.com/sirupsen/logrus"
+
+	common "github.com/parinithshekar/gitsink/common"
+	plugins "github.com/parinithshekar/gitsink/plugins/interfaces"
+)
+
+// ReleaseBody gives the notes of the target release, with the author and date from the source
+// above the source notes
+func ReleaseBody(ctx context.Context, release common.Release, users plugins.UserMapper) string {
+	var body strings.Builder
+
+	origin := "Migrated release"
+	if release.URL != "" {
+		origin = fmt.Sprintf("Migrated from %v", release.URL)
+	}
+	if release.Author.Name != "" {
+		fmt.Fprintf(&body, "> %v, published by %v on %v\n", origin, userText(ctx, release.Author, users), release.Created.Format(dateFormat))
+	} else {
+		fmt.Fprintf(&body, "> %v, published on %v\n", origin, release.Created.Format(dateFormat))
+	}
+	if release.Notes != "" {
+		fmt.Fprintf(&body, "\n%v\n", release.Notes)
+	}
+	return body.String()
+}
+
+// targetReleases lists the releases on the target by their tag
+func (public Public) targetReleases(ctx context.Context, owner, name string) (map[string]*github.RepositoryRelease, error) {
+	releases := map[string]*github.RepositoryRelease{}
+
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, response, err := public.api.Repositories.ListReleases(ctx, owner, name, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range page {
+			releases[release.GetTagName()] = release
+		}
+		if response.NextPage == 0 {
+			return releases, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+// MigrateReleases recreates the releases on the target, matched to their tags, and uploads
+// their assets. Releases already on the target are left as they are apart from missing assets
+func (public Public) MigrateReleases(ctx context.Context, repo common.Repository, releases []common.Release, source plugins.ReleaseSource) (common.MigrationSummary, error) {
+	summary := common.MigrationSummary{}
+
+	kindSplit := strings.SplitN(public.kind, "/", 2)
+	owner := kindSplit[1]
+
+	existing, err := public.targetReleases(ctx, owner, repo.Slug)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, release := range releases {
+		targetRelease, exists := existing[release.Tag]
+		if exists {
+			summary.Existing++
+		} else {
+			body := ReleaseBody(ctx, release, public.users)
+			targetRelease, _, err = public.api.Repositories.CreateRelease(ctx, owner, repo.Slug, &github.RepositoryRelease{
+				TagName:    &release.Tag,
+				Name:       &release.Title,
+				Body:       &body,
+				Draft:      &release.Draft,
+				Prerelease: &release.Prerelease,
+			})
+			if err != nil {
+				summary.Failed++
+				public.log.WithFields(logrus.Fields{
+					"repository": repo.Slug,
+					"tag":        release.Tag,
+					"error":      err.Error(),
+				}).Warningf("Release could not be migrated")
+				continue
+			}
+			summary.Migrated++
+		}
+
+		if source == nil || len(release.Assets) == 0 {
+			continue
+		}
+		failedAssets, err := public.uploadAssets(ctx, owner, repo, targetRelease, release, source)
+		if err != nil {
+			// Every asset not uploaded counts as a failure, so the release is reported incomplete
+			summary.Failed += failedAssets
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"tag":        release.Tag,
+				"error":      err.Error(),
+			}).Warningf("Release assets could not be migrated")
+		}
+	}
+
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%v releases or release assets not migrated", summary.Failed)
+	}
+	return summary, nil
+}
+
+// uploadAssets uploads the assets of the release that the target release does not have
+// Assets are matched by name, which is unique within a GitHub release
+// Returns the number of assets that could not be uploaded
+func (public Public) uploadAssets(ctx context.Context, owner string, repo common.Repository, targetRelease *github.RepositoryRelease, release common.Release, source plugins.ReleaseSource) (int, error) {
+	uploaded := map[string]bool{}
+	for _, asset := range targetRelease.Assets {
+		uploaded[asset.GetName()] = true
+	}
+
+	var failed []string
+	for _, asset := range release.Assets {
+		if uploaded[asset.Name] {
+			continue
+		}
+		err := public.uploadAsset(ctx, owner, repo, targetRelease.GetID(), asset, source)
+		if err != nil {
+			failed = append(failed, asset.Name)
+			public.log.WithFields(logrus.Fields{
+				"repository": repo.Slug,
+				"tag":        release.Tag,
+				"asset":      asset.Name,
+				"error":      err.Error(),
+			}).Debugf("Release asset could not be migrated")
+		}
+	}
+
+	if len(failed) > 0 {
+		return len(failed), fmt.Errorf("Assets not migrated: %v", strings.Join(failed, ", "))
+	}
+	return 0, nil
+}
+
+// uploadAsset copies one asset through a temporary file, since uploads need the size up front
+func (public Public) uploadAsset(ctx context.Context, owner string, repo common.Repository, releaseID int64, asset common.Asset, source plugins.ReleaseSource) error {
+	contents, err := source.ReleaseAsset(ctx, repo, asset)
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	// The extension is kept so the media type can be guessed if the source has none
+	file, err := ioutil.TempFile("", "gitsink-asset-*"+filepath.Ext(asset.Name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err = io.Copy(file, contents); err != nil {
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, _, err = public.api.Repositories.UploadReleaseAsset(ctx, owner, repo.Slug, releaseID, &github.UploadOptions{
+		Name:      asset.Name,
+		MediaType: asset.ContentType,
+	}, file)
+	return err
+}
@@ -0,0 +1,60 @@
+package public
+
+import (
+	"context"
+
+	transport "github.com/go-git/go-git/v5/plumbing/transport"
+
+	common "github.com/parinithshekar/gitsink/common"
+	v2 "github.com/parinithshekar/gitsink/plugins/interfaces/v2"
+)
+
+// native is the GitHub target with the methods of the second plugin interfaces
+// Each call makes its requests with its own context
+type native struct {
+	public *Public
+}
+
+// V2 gives the target with the methods of the second plugin interfaces
+func (public *Public) V2() v2.Output {
+	return native{public: public}
+}
+
+// Authenticate checks that the credentials can create repositories of the kind
+func (target native) Authenticate(ctx context.Context) error {
+	if _, _, err := target.public.Credentials(); err != nil {
+		return v2.Wrap("authenticate target", "", v2.ErrCredentials, err)
+	}
+	return v2.Wrap("authenticate target", "", v2.ErrFailed, target.public.authenticate(ctx))
+}
+
+// LookupTarget gives the repository with the clone URL of its target repository
+func (target native) LookupTarget(ctx context.Context, repo common.Repository) (common.Repository, error) {
+	return target.public.lookupTarget(ctx, repo)
+}
+
+// CreateTarget creates the target repository, or reconciles the metadata of the existing one
+func (target native) CreateTarget(ctx context.Context, repo common.Repository) (common.Repository, error) {
+	created, err := target.public.CreateTarget(ctx, repo)
+	return created, v2.Wrap("create target", repo.Slug, v2.ErrFailed, err)
+}
+
+// Auth gives the credentials of the target as git basic auth
+func (target native) Auth() (transport.AuthMethod, error) {
+	return v2.BasicAuth("target credentials", target.public.Credentials)
+}
+
+// Capabilities tells what GitHub targets support
+func (target native) Capabilities() v2.Capabilities {
+	return target.public.Capabilities()
+}
+
+// TargetBranch gives the target name of a source branch after the branch modifiers are applied
+func (target native) TargetBranch(branch string) (string, bool) {
+	return target.public.TargetBranch(branch)
+}
+
+// SetDefaultBranch makes the branch the default of the target repository if it is not already
+func (target native) SetDefaultBranch(ctx context.Context, repo common.Repository, branch string) error {
+	return target.public.setDefaultBranch(ctx, repo, branch)
+}
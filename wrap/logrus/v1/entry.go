@@ -0,0 +1,146 @@
+// Copyright 2019 Cisco Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+
+	logrus "github.com/sirupsen/logrus"
+
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+)
+
+// root is the logger every Entry writes to, so its level and output apply everywhere
+var root = newRoot()
+
+// newRoot makes the root logger with JSON formatted logs, like New
+func newRoot() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetReportCaller(true)
+	logger.SetFormatter(&logrus.JSONFormatter{
+		CallerPrettyfier: prettyfier,
+	})
+	return logger
+}
+
+// Entry is an immutable logger with fields, safe to share between goroutines.
+// WithField(s) and WithError give a new Entry and leave the fields of this one alone.
+type Entry struct {
+	entry *logrus.Entry
+}
+
+// Root Logger without fields, writing to the root logger.
+func Root() *Entry {
+	return &Entry{entry: logrus.NewEntry(root)}
+}
+
+// contextKey is the key of the Entry in a context
+type contextKey struct{}
+
+// NewContext Context carrying the logger, for the code it is passed to.
+func NewContext(ctx context.Context, log pkg.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// FromContext Logger carried by the context, the root logger when it has none.
+func FromContext(ctx context.Context) pkg.Logger {
+	if log, ok := ctx.Value(contextKey{}).(pkg.Logger); ok {
+		return log
+	}
+	return Root()
+}
+
+// NewRunID Random ID that ties together the log lines and report of one run.
+func NewRunID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Errorf Log at error level.
+func (e *Entry) Errorf(msg string, args ...interface{}) {
+	e.entry.Logf(logrus.ErrorLevel, msg, args...)
+}
+
+// Infof Log at info level.
+func (e *Entry) Infof(msg string, args ...interface{}) {
+	e.entry.Logf(logrus.InfoLevel, msg, args...)
+}
+
+// Fatalf Log at fatal level.
+func (e *Entry) Fatalf(msg string, args ...interface{}) {
+	if root.IsLevelEnabled(logrus.FatalLevel) {
+		e.entry.Logf(logrus.FatalLevel, msg, args...)
+		root.Exit(1)
+	}
+}
+
+// Panicf Log at panic level, then panic.
+func (e *Entry) Panicf(msg string, args ...interface{}) {
+	e.entry.Logf(logrus.PanicLevel, msg, args...)
+}
+
+// Debugf Log at debug level.
+func (e *Entry) Debugf(msg string, args ...interface{}) {
+	e.entry.Logf(logrus.DebugLevel, msg, args...)
+}
+
+// Tracef Log at trace level.
+func (e *Entry) Tracef(msg string, args ...interface{}) {
+	e.entry.Logf(logrus.TraceLevel, msg, args...)
+}
+
+// Warningf Log at warning level.
+func (e *Entry) Warningf(msg string, args ...interface{}) {
+	e.entry.Logf(logrus.WarnLevel, msg, args...)
+}
+
+// WithError Logger with the given error as a seperate field.
+func (e *Entry) WithError(err error) pkg.Logger {
+	return &Entry{entry: e.entry.WithError(err)}
+}
+
+// WithField Logger with the given key, value as custom field.
+func (e *Entry) WithField(k string, v interface{}) pkg.Logger {
+	return &Entry{entry: e.entry.WithField(k, v)}
+}
+
+// WithFields Logger with the given key, value pairs as custom fields.
+func (e *Entry) WithFields(kv map[string]interface{}) pkg.Logger {
+	return &Entry{entry: e.entry.WithFields(logrus.Fields(kv))}
+}
+
+// AutoClearFields Noop, fields of an Entry are never changed by logging.
+func (e *Entry) AutoClearFields(enabled bool) {}
+
+// SetLevel Set log level of the root logger.
+func (e *Entry) SetLevel(levelStr string) {
+	if level, err := logrus.ParseLevel(levelStr); err == nil {
+		root.SetLevel(level)
+	}
+}
+
+// LogLevel Current log level of the root logger.
+func (e *Entry) LogLevel() string {
+	return root.GetLevel().String()
+}
+
+// SetOutput Change output of the root logger. Default output is os.Stderr.
+func (e *Entry) SetOutput(w io.Writer) {
+	root.SetOutput(w)
+}
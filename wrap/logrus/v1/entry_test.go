@@ -0,0 +1,121 @@
+// Copyright 2019 Cisco Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	require "github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is a buffer that can be written from many goroutines
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// Root logger that writes to a buffer for testing, instead of os.Stderr
+func rootLogger() (*logger.Entry, *lockedBuffer) {
+	buf := &lockedBuffer{}
+	root := logger.Root()
+	root.SetOutput(buf)
+	root.SetLevel("info")
+	return root, buf
+}
+
+// Enforce interface implementation.
+func TestEntryInterface(t *testing.T) {
+	var _ pkg.Logger = &logger.Entry{}
+}
+
+// Test fields of an entry are not changed by the entries made from it.
+func TestEntryImmutable(t *testing.T) {
+	root, buf := rootLogger()
+	defer root.SetOutput(os.Stderr)
+
+	run := root.WithField("runId", "abc")
+	run.WithField("repository", "app").Errorf("Repository failed.")
+	run.Errorf("Run failed.")
+	expectedLog := fmt.Sprintf("{\"file\":\"%s:%d\",\"func\":\"%s\",\"level\":\"%s\",\"msg\":\"%s\",\"runId\":\"abc\",\"time\":\"", "entry_test.go", getLineNumber()-1, getFuncName(), "error", "Run failed.")
+	require.Contains(t, buf.String(), expectedLog)
+	require.Contains(t, buf.String(), "\"repository\":\"app\"")
+	require.Equal(t, 1, strings.Count(buf.String(), "\"repository\""))
+}
+
+// Test concurrent entries keep their own fields.
+func TestEntryConcurrent(t *testing.T) {
+	root, buf := rootLogger()
+	defer root.SetOutput(os.Stderr)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			root.WithField("runId", i).WithField("repository", i).Infof("Synced.")
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 100)
+	for _, line := range lines {
+		var runID, repository int
+		fmt.Sscanf(line[strings.Index(line, "\"repository\":"):], "\"repository\":%d", &repository)
+		fmt.Sscanf(line[strings.Index(line, "\"runId\":"):], "\"runId\":%d", &runID)
+		require.Equal(t, runID, repository, line)
+	}
+}
+
+// Test the logger carried by a context, and the root level applying to every logger.
+func TestContext(t *testing.T) {
+	root, buf := rootLogger()
+	defer root.SetOutput(os.Stderr)
+
+	require.NotNil(t, logger.FromContext(context.Background()))
+
+	runID := logger.NewRunID()
+	require.Len(t, runID, 16)
+	require.NotEqual(t, runID, logger.NewRunID())
+
+	ctx := logger.NewContext(context.Background(), root.WithField("runId", runID))
+	logger.FromContext(ctx).Debugf("Hidden.")
+	root.SetLevel("debug")
+	logger.FromContext(ctx).Debugf("Shown.")
+
+	require.NotContains(t, buf.String(), "Hidden.")
+	require.Contains(t, buf.String(), fmt.Sprintf("\"msg\":\"Shown.\",\"runId\":\"%s\"", runID))
+	require.Equal(t, "debug", logger.FromContext(ctx).LogLevel())
+}
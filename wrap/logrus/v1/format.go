@@ -0,0 +1,86 @@
+// Copyright 2019 Cisco Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	logrus "github.com/sirupsen/logrus"
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log formats of the root logger
+const (
+	FormatJSON   = "json"
+	FormatText   = "text"
+	FormatLogfmt = "logfmt"
+)
+
+// Formats Log formats that SetFormat takes.
+var Formats = []string{FormatJSON, FormatText, FormatLogfmt}
+
+// Rotation Size of a log file before it is rotated, and the age and count of rotated files kept.
+// Files are rotated at 100 megabytes when no size is given, a zero age or count keeps every file.
+type Rotation struct {
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+}
+
+// SetFormat Set format of the root logger.
+// text is for reading in a terminal, logfmt is key=value pairs for log shippers.
+func SetFormat(format string) error {
+	switch format {
+	case FormatJSON:
+		root.SetFormatter(&logrus.JSONFormatter{
+			CallerPrettyfier: prettyfier,
+		})
+	case FormatText:
+		root.SetFormatter(&logrus.TextFormatter{
+			CallerPrettyfier:       prettyfier,
+			DisableLevelTruncation: true,
+			FullTimestamp:          true,
+			TimestampFormat:        "15:04:05",
+		})
+	case FormatLogfmt:
+		root.SetFormatter(&logrus.TextFormatter{
+			CallerPrettyfier: prettyfier,
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  time.RFC3339,
+			QuoteEmptyFields: true,
+		})
+	default:
+		return fmt.Errorf("Log format %v is not json, text or logfmt", format)
+	}
+	return nil
+}
+
+// SetFile Write the root logger to the file, rotated once it reaches the size limit.
+// Rotated files are named after the time of rotation and removed by age and count.
+// Close the returned file to flush it when the program exits.
+func SetFile(path string, rotation Rotation) io.Closer {
+	file := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    rotation.MaxSizeMB,
+		MaxAge:     rotation.MaxAgeDays,
+		MaxBackups: rotation.MaxBackups,
+		LocalTime:  true,
+	}
+	root.SetOutput(file)
+	return file
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Cisco Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+	require "github.com/stretchr/testify/require"
+)
+
+// Test every log format of the root logger.
+func TestSetFormat(t *testing.T) {
+	root, buf := rootLogger()
+	defer root.SetOutput(os.Stderr)
+	defer logger.SetFormat(logger.FormatJSON)
+
+	cases := map[string]struct {
+		Expected      string
+		ExpectedError bool
+	}{
+		logger.FormatJSON:   {"\"level\":\"info\",\"msg\":\"Synced.\",\"repository\":\"app\"", false},
+		logger.FormatText:   {"level=info msg=Synced. func=TestSetFormat file=\"format_test.go:", false},
+		logger.FormatLogfmt: {"level=info msg=Synced. func=TestSetFormat file=\"format_test.go:", false},
+		"xml":               {"", true},
+	}
+
+	for format, tc := range cases {
+		err := logger.SetFormat(format)
+		require.Equal(t, tc.ExpectedError, err != nil, format)
+		if err != nil {
+			continue
+		}
+		root.WithField("repository", "app").Infof("Synced.")
+		require.Contains(t, buf.String(), tc.Expected, format)
+		if format != logger.FormatJSON {
+			require.Contains(t, buf.String(), "repository=app", format)
+		}
+	}
+}
+
+// Test the log file is rotated once it reaches its size.
+func TestSetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	root := logger.Root()
+	file := logger.SetFile(filepath.Join(dir, "gitsink.log"), logger.Rotation{MaxSizeMB: 1, MaxBackups: 1})
+	defer root.SetOutput(os.Stderr)
+
+	message := strings.Repeat("x", 1000)
+	for i := 0; i < 3000; i++ {
+		root.WithField("line", i).Infof(message)
+	}
+	require.NoError(t, file.Close())
+
+	// Rotated files past the count are removed in the background
+	var names []string
+	for i := 0; i < 50; i++ {
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		names = nil
+		for _, f := range files {
+			names = append(names, f.Name())
+			require.True(t, f.Size() <= 1<<20, fmt.Sprintf("%v is %v bytes", f.Name(), f.Size()))
+		}
+		if len(names) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, names, 2, names)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "gitsink.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "\"line\":2999")
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Cisco Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
+	logrus "github.com/sirupsen/logrus"
+
+	pkg "github.com/parinithshekar/gitsink/pkg/v1"
+	logger "github.com/parinithshekar/gitsink/wrap/logrus/v1"
+)
+
+var (
+	log = logger.Root()
+
+	// registry holds only the gitsink metrics, without the Go runtime ones
+	registry = prometheus.NewRegistry()
+
+	repositoriesSynced = counterVec(prometheus.CounterOpts{
+		Name: "gitsink_repositories_synced_total",
+		Help: "Repositories synced, by integration and result.",
+	}, "integration", "result")
+
+	refsPushed = counterVec(prometheus.CounterOpts{
+		Name: "gitsink_refs_pushed_total",
+		Help: "Branches and tags pushed to the target, by integration and kind.",
+	}, "integration", "kind")
+
+	refsFailed = counterVec(prometheus.CounterOpts{
+		Name: "gitsink_refs_failed_total",
+		Help: "Branches and tags that failed to push to the target, by integration and kind.",
+	}, "integration", "kind")
+
+	apiRequests = counterVec(prometheus.CounterOpts{
+		Name: "gitsink_api_requests_total",
+		Help: "Requests to the APIs of the sources and targets, by plugin and status code.",
+	}, "plugin", "code")
+
+	apiErrors = counterVec(prometheus.CounterOpts{
+		Name: "gitsink_api_errors_total",
+		Help: "Requests to the APIs of the sources and targets that failed or got an error status, by plugin.",
+	}, "plugin")
+
+	gitDuration = histogramVec(prometheus.HistogramOpts{
+		Name:    "gitsink_git_operation_duration_seconds",
+		Help:    "Duration of git clones, fetches and pushes, by integration and operation.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, "integration", "operation")
+
+	lastSuccess = gaugeVec(prometheus.GaugeOpts{
+		Name: "gitsink_last_success_timestamp_seconds",
+		Help: "Unix time of the last sync without failures, by integration and repository.",
+	}, "integration", "repository")
+)
+
+// Git operations timed by ObserveGit
+const (
+	OperationClone = "clone"
+	OperationFetch = "fetch"
+	OperationPush  = "push"
+)
+
+// Kinds of refs counted by RecordRefs
+const (
+	KindBranch = "branch"
+	KindTag    = "tag"
+)
+
+// counterVec registers a counter vector, a failed registration is logged and the counter still counts
+func counterVec(opts prometheus.CounterOpts, labels ...string) *prometheus.CounterVec {
+	vec := prometheus.NewCounterVec(opts, labels)
+	if err := registry.Register(vec); err != nil {
+		log.WithFields(logrus.Fields{
+			"metric": opts.Name,
+			"error":  err.Error(),
+		}).Errorf(pkg.ErrCounterVecCreateFailed.Error())
+	}
+	return vec
+}
+
+// histogramVec registers a histogram vector, a failed registration is logged
+func histogramVec(opts prometheus.HistogramOpts, labels ...string) *prometheus.HistogramVec {
+	vec := prometheus.NewHistogramVec(opts, labels)
+	if err := registry.Register(vec); err != nil {
+		log.WithFields(logrus.Fields{
+			"metric": opts.Name,
+			"error":  err.Error(),
+		}).Errorf(pkg.ErrHistogramVecCreateFailed.Error())
+	}
+	return vec
+}
+
+// gaugeVec registers a gauge vector, a failed registration is logged
+func gaugeVec(opts prometheus.GaugeOpts, labels ...string) *prometheus.GaugeVec {
+	vec := prometheus.NewGaugeVec(opts, labels)
+	if err := registry.Register(vec); err != nil {
+		log.WithFields(logrus.Fields{
+			"metric": opts.Name,
+			"error":  err.Error(),
+		}).Errorf(pkg.ErrGaugeVecCreateFailed.Error())
+	}
+	return vec
+}
+
+// RecordRepository counts a synced repository, and marks its last success when it did not fail
+func RecordRepository(integration, slug string, failed bool) {
+	if failed {
+		repositoriesSynced.WithLabelValues(integration, "failed").Inc()
+		return
+	}
+	repositoriesSynced.WithLabelValues(integration, "synced").Inc()
+	lastSuccess.WithLabelValues(integration, slug).SetToCurrentTime()
+}
+
+// RecordRefs counts the branches or tags of a repository pushed to the target and failed to push
+func RecordRefs(integration, kind string, pushed, failed int) {
+	refsPushed.WithLabelValues(integration, kind).Add(float64(pushed))
+	refsFailed.WithLabelValues(integration, kind).Add(float64(failed))
+}
+
+// ObserveGit records the duration of a git operation that began at start
+func ObserveGit(integration, operation string, start time.Time) {
+	gitDuration.WithLabelValues(integration, operation).Observe(time.Since(start).Seconds())
+}
+
+// transport counts the requests that go through it
+type transport struct {
+	plugin string
+	next   http.RoundTripper
+}
+
+// RoundTrip counts the request by its status code, and as an error when it fails or gets a 4xx or 5xx
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		apiRequests.WithLabelValues(t.plugin, "error").Inc()
+		apiErrors.WithLabelValues(t.plugin).Inc()
+		return resp, err
+	}
+	apiRequests.WithLabelValues(t.plugin, strconv.Itoa(resp.StatusCode)).Inc()
+	if resp.StatusCode >= 400 {
+		apiErrors.WithLabelValues(t.plugin).Inc()
+	}
+	return resp, nil
+}
+
+// Transport counts the API requests of the plugin made through next, the default transport when nil
+func Transport(plugin string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{plugin: plugin, next: next}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// WriteTextfile writes the metrics to the file for the textfile collector of the node exporter
+// The file is written next to the path and renamed, so it is never read half written
+func WriteTextfile(path string) error {
+	return prometheus.WriteToTextfile(path, registry)
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Cisco Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	require "github.com/stretchr/testify/require"
+
+	metrics "github.com/parinithshekar/gitsink/wrap/prometheus/v1"
+)
+
+// scrape gives the metrics served by the handler
+func scrape(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+// Test API requests counted by the transport
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: metrics.Transport("input/test", nil)}
+	for _, path := range []string{"/repos", "/repos", "/missing"} {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err := client.Get("http://127.0.0.1:0/unreachable")
+	require.Error(t, err)
+
+	body := scrape(t)
+	for _, expected := range []string{
+		`gitsink_api_requests_total{code="200",plugin="input/test"} 2`,
+		`gitsink_api_requests_total{code="404",plugin="input/test"} 1`,
+		`gitsink_api_requests_total{code="error",plugin="input/test"} 1`,
+		`gitsink_api_errors_total{plugin="input/test"} 2`,
+	} {
+		require.Contains(t, body, expected)
+	}
+}
+
+// Test sync metrics exported to a textfile
+func TestWriteTextfile(t *testing.T) {
+	metrics.RecordRepository("textfile", "app", false)
+	metrics.RecordRepository("textfile", "broken", true)
+	metrics.RecordRefs("textfile", metrics.KindBranch, 3, 1)
+	metrics.ObserveGit("textfile", metrics.OperationClone, time.Now().Add(-2*time.Second))
+
+	dir, err := ioutil.TempDir("", "metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gitsink.prom")
+	require.NoError(t, metrics.WriteTextfile(path))
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	body := string(content)
+	for _, expected := range []string{
+		`gitsink_repositories_synced_total{integration="textfile",result="synced"} 1`,
+		`gitsink_repositories_synced_total{integration="textfile",result="failed"} 1`,
+		`gitsink_refs_pushed_total{integration="textfile",kind="branch"} 3`,
+		`gitsink_refs_failed_total{integration="textfile",kind="branch"} 1`,
+		`gitsink_git_operation_duration_seconds_count{integration="textfile",operation="clone"} 1`,
+		`gitsink_last_success_timestamp_seconds{integration="textfile",repository="app"}`,
+	} {
+		require.Contains(t, body, expected)
+	}
+	require.False(t, strings.Contains(body, `repository="broken"`))
+}